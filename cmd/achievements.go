@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/waldgaenger/go-acht/internal/achievements"
+	"github.com/waldgaenger/go-acht/internal/cheats"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+	"github.com/waldgaenger/go-acht/internal/romdb"
+)
+
+// startAchievements returns a tracker of the achievements of the ROM which shows a toast on r when
+// one is unlocked, or nil if the ROM has no achievements. Achievements can not be earned while enabled
+// cheats or the debugger can change the memory.
+func startAchievements(cfg *config.Config, rom []byte, r *renderer.SDLRenderer, engine *cheats.Engine, debug bool) (*achievements.Tracker, error) {
+	if len(cfg.Achievements) == 0 {
+		return nil, nil
+	}
+	if debug {
+		return nil, errors.New("the debugger can change the memory")
+	}
+	if engine != nil && engine.Active() {
+		return nil, errors.New("cheats are enabled")
+	}
+	store, err := openAchievements(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := achievements.NewTracker(romdb.Hash(rom), cfg.Achievements, store)
+	if err != nil {
+		return nil, err
+	}
+	tracker.Unlocked = func(a achievements.Achievement) {
+		r.ShowToast("Achievement unlocked: " + a.Title)
+		fmt.Printf("achievement unlocked: %s\n", a.Title)
+	}
+	fmt.Printf("achievements: %d of %d unlocked\n", len(cfg.Achievements)-tracker.Remaining(), len(cfg.Achievements))
+	return tracker, nil
+}
+
+// openAchievements opens the file of the unlocked achievements given in the configuration or the
+// default one.
+func openAchievements(cfg *config.Config) (*achievements.Store, error) {
+	path := ""
+	if cfg.Paths != nil {
+		path = cfg.Paths.Achievements
+	}
+	if path == "" {
+		var err error
+		if path, err = achievements.DefaultStorePath(); err != nil {
+			return nil, err
+		}
+	}
+	return achievements.OpenStore(path)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/waldgaenger/go-acht/internal/analyzer"
+)
+
+// analyze prints the control-flow graph, subroutines, jump tables, sprite regions and
+// self-modifying code of a ROM as Graphviz DOT or JSON.
+func analyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	format := fs.String("format", "dot", "Set this flag to choose the output format: dot or json.")
+	output := fs.String("o", "", "Set this flag to write the output to a file instead of the standard output.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht analyze [-format dot|json] [-o file] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	rom, _, err := readRom(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var write func(io.Writer, *analyzer.Analysis) error
+	switch *format {
+	case "dot":
+		write = analyzer.WriteDOT
+	case "json":
+		write = analyzer.WriteJSON
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	return write(out, analyzer.Analyze(rom))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/audio"
+	"github.com/waldgaenger/go-acht/internal/capture"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// record runs a ROM without a window and saves its screen after a number of frames as PNG or records
+// the frames as animated GIF or YUV4MPEG2 video, depending on the extension of the output file.
+// The sound of a video can be recorded as WAV file as well.
+func record(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	output := fs.String("o", "", "Set this flag to provide the path of the PNG, GIF or Y4M file.")
+	wavPath := fs.String("wav", "", "Set this flag to provide the path of a WAV file for the sound of a Y4M video.")
+	frames := fs.Int("frames", 300, "Set this flag to provide the number of frames to run or to record.")
+	skip := fs.Int("skip", 0, "Set this flag to provide the number of frames to run before the recording starts.")
+	path := fs.String("config", "", "Set this flag to provide the path of the configuration file.")
+	quirkProfile := fs.String("quirks", "", "Set this flag to provide a quirk profile (legacy, chip8, schip, xochip) or auto to detect it.")
+	tickrate := fs.Int("tickrate", 0, "Set this flag to provide the number of instructions per frame.")
+	colorProfile := fs.String("colorprofile", "", "Set this flag to provide a color profile.")
+	scale := fs.Int("scale", 0, "Set this flag to provide the size of a pixel in the file.")
+	filters := fs.String("filters", "", "Set this flag to provide post-processing filters applied in order, e.g. ghosting:0.6,scale2x,scanlines,crt.")
+	patches := fs.String("patch", "", "Set this flag to provide IPS or BPS patches applied in order to the ROM, e.g. fix.ips,translation.bps.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht capture -o <file.png|file.gif|file.y4m> [-wav file.wav] [flags] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *output == "" {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file and an output file")
+	}
+	ext := strings.ToLower(filepath.Ext(*output))
+	if ext != ".png" && ext != ".gif" && ext != ".y4m" {
+		return fmt.Errorf("unsupported output format: %s", ext)
+	}
+	if *wavPath != "" && ext != ".y4m" {
+		return fmt.Errorf("the sound can only be recorded with a Y4M video")
+	}
+
+	flags := &config.Config{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quirks":
+			flags.Quirks = &config.Quirks{Profile: *quirkProfile}
+		case "tickrate":
+			flags.Tickrate = *tickrate
+		case "colorprofile":
+			flags.ColorProfile = *colorProfile
+		case "scale":
+			flags.Scale = *scale
+		case "filters":
+			flags.Filters = strings.Split(*filters, ",")
+		}
+	})
+
+	file, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+	rom, cart, err := readRom(romPath(fs.Arg(0), file, flags))
+	if err != nil {
+		return err
+	}
+	if rom, err = applyPatches(rom, *patches); err != nil {
+		return err
+	}
+	cfg, _, err := effectiveConfig(file, rom, cart, flags)
+	if err != nil {
+		return err
+	}
+	applyPalette(cfg)
+
+	pipeline, err := renderer.ParsePipeline(cfg.Filters)
+	if err != nil {
+		return err
+	}
+	rec := &capture.Recorder{Scale: cfg.Scale, Filters: pipeline}
+
+	c8 := &chip8.Chip8{Renderer: rec, Quirks: resolveQuirks(cfg, rom, os.Stdout), Tickrate: cfg.Tickrate}
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+
+	rec.Clock = c8.Frame
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", *output, err)
+	}
+	defer f.Close()
+
+	var wav *os.File
+	if *wavPath != "" {
+		wav, err = os.Create(*wavPath)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %w", *wavPath, err)
+		}
+		defer wav.Close()
+	}
+
+	for frame := range *skip + *frames {
+		if frame == *skip {
+			switch ext {
+			case ".gif":
+				rec.StartGIF(f)
+			case ".y4m":
+				if err := startVideo(rec, cfg, f, wav); err != nil {
+					return err
+				}
+			}
+		}
+		c8.StepFrame()
+		rec.Draw(c8.Display())
+		rec.Beep(c8.SoundTimer() > 0)
+	}
+
+	switch ext {
+	case ".gif":
+		return rec.StopGIF()
+	case ".y4m":
+		return rec.StopVideo()
+	}
+	return rec.Screenshot(f)
+}
+
+// startVideo starts recording video and, if wav is not nil, the sound with the audio settings of cfg.
+func startVideo(rec *capture.Recorder, cfg *config.Config, video, wav *os.File) error {
+	tone := audio.Tone{Frequency: cfg.Audio.Frequency, Volume: cfg.Audio.Volume}
+	if wav == nil {
+		return rec.StartVideo(video, nil, tone)
+	}
+	return rec.StartVideo(video, wav, tone)
+}
+
+// hotkeyCapture saves screenshots and recordings of the running ROM when the hotkeys are pressed.
+type hotkeyCapture struct {
+	rec   *capture.Recorder
+	cfg   *config.Config
+	dir   string   // Directory the files are saved to
+	rom   string   // Path of the ROM, whose name is the start of the file names
+	gif   *os.File // File of the running GIF recording
+	video *os.File // Files of the running video recording
+	wav   *os.File
+}
+
+// screenshot saves the current screen as PNG.
+func (h *hotkeyCapture) screenshot() {
+	f, err := os.Create(h.path(".png"))
+	if err != nil {
+		fmt.Println("could not save screenshot: ", err)
+		return
+	}
+	defer f.Close()
+
+	if err := h.rec.Screenshot(f); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("screenshot saved: %s\n", f.Name())
+}
+
+// toggleGIF starts a recording or saves the running one.
+func (h *hotkeyCapture) toggleGIF() {
+	if h.gif == nil {
+		f, err := os.Create(h.path(".gif"))
+		if err != nil {
+			fmt.Println("could not start recording: ", err)
+			return
+		}
+		h.gif = f
+		h.rec.StartGIF(f)
+		fmt.Printf("recording to %s\n", f.Name())
+		return
+	}
+
+	if err := h.rec.StopGIF(); err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("recording saved: %s\n", h.gif.Name())
+	}
+	h.gif.Close()
+	h.gif = nil
+}
+
+// toggleVideo starts recording video and sound or saves the running recording.
+func (h *hotkeyCapture) toggleVideo() {
+	if h.video == nil {
+		name := h.path("")
+		video, err := os.Create(name + ".y4m")
+		if err != nil {
+			fmt.Println("could not start recording: ", err)
+			return
+		}
+		wav, err := os.Create(name + ".wav")
+		if err != nil {
+			video.Close()
+			fmt.Println("could not start recording: ", err)
+			return
+		}
+		if err := startVideo(h.rec, h.cfg, video, wav); err != nil {
+			video.Close()
+			wav.Close()
+			fmt.Println("could not start recording: ", err)
+			return
+		}
+		h.video, h.wav = video, wav
+		fmt.Printf("recording to %s and %s\n", video.Name(), wav.Name())
+		return
+	}
+
+	if err := h.rec.StopVideo(); err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("recording saved: %s and %s\n", h.video.Name(), h.wav.Name())
+	}
+	h.video.Close()
+	h.wav.Close()
+	h.video, h.wav = nil, nil
+}
+
+// path returns a new file name made of the name of the ROM and the current time.
+func (h *hotkeyCapture) path(ext string) string {
+	name := strings.TrimSuffix(filepath.Base(h.rom), filepath.Ext(h.rom))
+	return filepath.Join(h.dir, name+"-"+time.Now().Format("20060102-150405.000")+ext)
+}
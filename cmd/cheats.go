@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/waldgaenger/go-acht/internal/cheats"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/romdb"
+)
+
+// loadCheats returns the cheats in the cheat file of the ROM and the path of the file, which the
+// debugger saves new cheats to.
+func loadCheats(cfg *config.Config, rom []byte) (*cheats.Engine, string, error) {
+	dir := ""
+	if cfg.Paths != nil {
+		dir = cfg.Paths.Cheats
+	}
+	if dir == "" {
+		var err error
+		if dir, err = cheats.DefaultDir(); err != nil {
+			return nil, "", err
+		}
+	}
+	path := cheats.Path(dir, romdb.Hash(rom))
+	list, err := cheats.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, c := range list {
+		if !c.Disabled {
+			fmt.Printf("cheat enabled: %s\n", c)
+		}
+	}
+	return &cheats.Engine{Cheats: list}, path, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/waldgaenger/go-acht/internal/cartridge"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/detect"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+	"github.com/waldgaenger/go-acht/internal/romdb"
+)
+
+// configure handles the configuration subcommands. config dump prints the effective configuration,
+// optionally for a ROM.
+func configure(args []string) error {
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Println("usage: go-acht config dump [-config file] [rom]")
+		return fmt.Errorf("expected a config subcommand")
+	}
+
+	fs := flag.NewFlagSet("config dump", flag.ExitOnError)
+	path := fs.String("config", "", "Set this flag to provide the path of the configuration file.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht config dump [-config file] [rom]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one ROM file")
+	}
+
+	file, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	var rom []byte
+	var cart *cartridge.Cartridge
+	if fs.NArg() == 1 {
+		rom, cart, err = readRom(romPath(fs.Arg(0), file, nil))
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg, _, err := effectiveConfig(file, rom, cart, nil)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg)
+}
+
+// loadConfig reads the configuration file at path or the default configuration file, if it exists,
+// when path is empty.
+func loadConfig(path string) (*config.Config, error) {
+	if path != "" {
+		return config.Load(path, false)
+	}
+
+	path, err := config.DefaultPath()
+	if err != nil {
+		return &config.Config{}, nil
+	}
+	return config.Load(path, true)
+}
+
+// effectiveConfig merges the defaults, the global settings of the configuration file, the recommended
+// settings for the ROM, the per-ROM settings of the configuration file and the flags, in that order of
+// precedence. Without a ROM only the defaults, the global settings and the flags are merged.
+// The entry of the ROM database is returned if the ROM is known. If the database can not be read, the
+// error is returned together with the settings merged without the database.
+func effectiveConfig(file *config.Config, rom []byte, cart *cartridge.Cartridge, flags *config.Config) (*config.Config, *romdb.Entry, error) {
+	cfg := config.Default()
+	cfg.Merge(file)
+	if rom == nil {
+		cfg.Merge(flags)
+		return cfg, nil, nil
+	}
+
+	// The database is looked up with the path given by the global settings or the flags.
+	paths := config.Default()
+	paths.Merge(file)
+	paths.Merge(flags)
+	entry, known, err := lookupRom(rom, paths.Paths.Database)
+	if err != nil || !known {
+		entry = nil
+	}
+
+	cfg.Merge(recommendedConfig(entry, cart))
+	cfg.Merge(file.ForROM(romdb.Hash(rom)))
+	cfg.Merge(flags)
+
+	return cfg, entry, err
+}
+
+// recommendedConfig returns the settings recommended by the cartridge or, for other ROMs, by the ROM database.
+func recommendedConfig(entry *romdb.Entry, cart *cartridge.Cartridge) *config.Config {
+	cfg := &config.Config{}
+
+	switch {
+	case cart != nil:
+		cfg.Quirks = config.QuirksOf(cart.Options.Quirks())
+		cfg.Tickrate = cart.Options.Tickrate
+		cfg.Buttons = cart.Options.Keys
+		cfg.Palette = cart.Options.Colors()
+	case entry != nil:
+		if quirks, ok := entry.Quirks(); ok {
+			cfg.Quirks = config.QuirksOf(quirks)
+		}
+		cfg.Tickrate = entry.Tickrate()
+		cfg.Buttons = entry.ROM.Keys
+		if entry.ROM.Colors != nil {
+			cfg.Palette = entry.ROM.Colors.Pixels
+		}
+	}
+	if entry != nil {
+		cfg.Achievements = entry.ROM.Achievements
+	}
+
+	return cfg
+}
+
+// romPath returns the path of the ROM. A relative path which does not exist is searched in the
+// ROM directory of the configuration.
+func romPath(path string, file, flags *config.Config) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	cfg := config.Default()
+	cfg.Merge(file)
+	cfg.Merge(flags)
+	if cfg.Paths.ROMs == "" {
+		return path
+	}
+	return filepath.Join(cfg.Paths.ROMs, path)
+}
+
+// applyPalette loads the palette files of the configuration and selects its palette or color profile.
+// Invalid settings fall back to the black-white profile.
+func applyPalette(cfg *config.Config) {
+	if cfg.Paths.Palettes != "" {
+		if err := renderer.LoadPalettes(cfg.Paths.Palettes); err != nil {
+			fmt.Println("could not load the palettes: ", err)
+		}
+	}
+
+	if len(cfg.Palette) > 0 {
+		palette, err := cfg.Colors()
+		if err != nil {
+			renderer.Use("black-white")
+			fmt.Printf("invalid palette: %v - fallback: default profile black-white will be used \n", err)
+		} else {
+			renderer.SetProfile(palette)
+		}
+	} else if !renderer.Use(cfg.ColorProfile) {
+		renderer.Use("black-white")
+		fmt.Printf("no such color profile: %s - fallback: default profile black-white will be used \n", cfg.ColorProfile)
+	}
+}
+
+// resolveQuirks returns the quirks selected by the configuration, detecting them if the profile is auto.
+// If they cannot be resolved, the legacy profile is used. The detection report and errors are written to w.
+func resolveQuirks(cfg *config.Config, rom []byte, w io.Writer) chip8.Quirks {
+	quirks, err := cfg.Quirks.Resolve(func() (chip8.Quirks, error) {
+		report, err := detect.Quirks(rom)
+		if err != nil {
+			return chip8.Quirks{}, fmt.Errorf("could not detect the quirks of the ROM: %w", err)
+		}
+		fmt.Fprint(w, report)
+		return report.Quirks, nil
+	})
+	if err != nil {
+		fmt.Fprintf(w, "%v - fallback: default profile legacy will be used \n", err)
+		return chip8.Quirks{}
+	}
+	return quirks
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/gamestate"
+	"github.com/waldgaenger/go-acht/internal/gym"
+)
+
+// runGym runs a ROM as reinforcement learning environment, driven by JSON requests on the standard
+// input which are answered on the standard output. See gym.Serve for the protocol.
+func runGym(args []string) error {
+	fs := flag.NewFlagSet("gym", flag.ExitOnError)
+	path := fs.String("config", "", "Set this flag to provide the path of the configuration file.")
+	quirkProfile := fs.String("quirks", "", "Set this flag to provide a quirk profile (legacy, chip8, schip, xochip) or auto to detect it.")
+	tickrate := fs.Int("tickrate", 0, "Set this flag to provide the number of instructions per frame.")
+	frameSkip := fs.Int("frameskip", gym.DefaultFrameSkip, "Set this flag to provide the number of frames a step lasts.")
+	maxFrames := fs.Int("maxframes", 0, "Set this flag to provide the number of frames after which an episode ends, 0 for no limit.")
+	reward := fs.String("reward", "", "Set this flag to provide the reward, e.g. score, lives*-10, memory:0x2F0 for the change of a byte or frame:0.01 for every frame.")
+	done := fs.String("done", "", "Set this flag to provide the condition ending an episode, e.g. lives=0 or memory:0x2F1=0.")
+	seed := fs.Uint64("seed", 0, "Set this flag to provide the seed of the random numbers of every episode, 0 for a new one per episode.")
+	patches := fs.String("patch", "", "Set this flag to provide IPS or BPS patches applied in order to the ROM, e.g. fix.ips,translation.bps.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht gym [-reward spec] [-done spec] [flags] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	flags := &config.Config{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quirks":
+			flags.Quirks = &config.Quirks{Profile: *quirkProfile}
+		case "tickrate":
+			flags.Tickrate = *tickrate
+		}
+	})
+
+	file, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+	rom, cart, err := readRom(romPath(fs.Arg(0), file, flags))
+	if err != nil {
+		return err
+	}
+	if rom, err = applyPatches(rom, *patches); err != nil {
+		return err
+	}
+	cfg, entry, err := effectiveConfig(file, rom, cart, flags)
+	if err != nil {
+		return err
+	}
+
+	// The ROM database declares where known ROMs keep the score and the lives.
+	var variables map[string]gamestate.Variable
+	if entry != nil {
+		variables = entry.ROM.Variables
+	}
+	state, err := gamestate.NewReader(variables)
+	if err != nil {
+		return err
+	}
+
+	// The standard output carries the responses, messages go to the standard error instead.
+	opts := gym.Options{
+		Quirks:    resolveQuirks(cfg, rom, os.Stderr),
+		Tickrate:  cfg.Tickrate,
+		FrameSkip: *frameSkip,
+		MaxFrames: *maxFrames,
+		Log:       os.Stderr,
+	}
+	if *reward != "" {
+		r, err := gym.ParseReward(*reward, state)
+		if err != nil {
+			return err
+		}
+		opts.Reward = r
+	}
+	if *done != "" {
+		condition, err := gym.ParseCondition(*done, state)
+		if err != nil {
+			return err
+		}
+		opts.Done = condition
+	}
+
+	env, err := gym.NewEnv(rom, opts)
+	if err != nil {
+		return err
+	}
+	env.Seed(*seed)
+	return gym.Serve(env, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/achievements"
+	"github.com/waldgaenger/go-acht/internal/romdb"
+)
+
+// info prints what the ROM database knows about a ROM.
+func info(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	dbPath := fs.String("romdb", "", "Set this flag to provide a directory with the community CHIP-8 database instead of the bundled one.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht info [-romdb dir] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	rom, _, err := readRom(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(*dbPath)
+	if err != nil {
+		return err
+	}
+
+	entry, found := db.Lookup(rom)
+	if !found {
+		fmt.Printf("SHA-1:     %s\n", romdb.Hash(rom))
+		fmt.Println("the ROM is not in the database")
+		return nil
+	}
+
+	fmt.Printf("SHA-1:     %s\n", entry.Hash)
+	fmt.Printf("Title:     %s\n", entry.Program.Title)
+	if entry.ROM.EmbeddedTitle != "" {
+		fmt.Printf("Embedded:  %s\n", entry.ROM.EmbeddedTitle)
+	}
+	if len(entry.Program.Authors) > 0 {
+		fmt.Printf("Authors:   %s\n", strings.Join(entry.Program.Authors, ", "))
+	}
+	if entry.Program.Release != "" {
+		fmt.Printf("Release:   %s\n", entry.Program.Release)
+	}
+	if entry.Program.Description != "" {
+		fmt.Printf("About:     %s\n", entry.Program.Description)
+	}
+	if entry.Platform != nil {
+		fmt.Printf("Platform:  %s (%s)\n", entry.Platform.Name, entry.Platform.ID)
+	} else {
+		fmt.Printf("Platform:  unknown (%s)\n", strings.Join(entry.ROM.Platforms, ", "))
+	}
+	if quirks, ok := entry.Quirks(); ok {
+		fmt.Printf("Quirks:    %+v\n", quirks)
+	}
+	if tickrate := entry.Tickrate(); tickrate > 0 {
+		fmt.Printf("Tickrate:  %d\n", tickrate)
+	}
+	if len(entry.ROM.Keys) > 0 {
+		buttons := make([]string, 0, len(entry.ROM.Keys))
+		for button, key := range entry.ROM.Keys {
+			buttons = append(buttons, fmt.Sprintf("%s=%X", button, key))
+		}
+		slices.Sort(buttons)
+		fmt.Printf("Keys:      %s\n", strings.Join(buttons, " "))
+	}
+	if entry.ROM.Colors != nil && len(entry.ROM.Colors.Pixels) > 0 {
+		fmt.Printf("Colors:    %s\n", strings.Join(entry.ROM.Colors.Pixels, " "))
+	}
+	if len(entry.ROM.Variables) > 0 {
+		variables := make([]string, 0, len(entry.ROM.Variables))
+		for name, v := range entry.ROM.Variables {
+			variables = append(variables, fmt.Sprintf("%s=%s", name, v))
+		}
+		slices.Sort(variables)
+		fmt.Printf("Variables: %s\n", strings.Join(variables, " "))
+	}
+	if len(entry.ROM.Achievements) > 0 {
+		var unlocked map[string]time.Time
+		if path, err := achievements.DefaultStorePath(); err == nil {
+			if store, err := achievements.OpenStore(path); err == nil {
+				unlocked = store.Unlocked(entry.Hash)
+			}
+		}
+		fmt.Println("Achievements:")
+		for _, a := range entry.ROM.Achievements {
+			status := "locked"
+			if at, found := unlocked[a.ID]; found {
+				status = "unlocked " + at.Local().Format(time.DateTime)
+			}
+			fmt.Printf("  %-20s %-24s %s\n", a.Title, status, a.Description)
+		}
+	}
+
+	return nil
+}
+
+// openDatabase returns the database stored in the directory dir or the bundled one if dir is empty.
+func openDatabase(dir string) (*romdb.Database, error) {
+	if dir == "" {
+		return romdb.Default()
+	}
+	return romdb.Load(os.DirFS(dir))
+}
+
+// lookupRom searches the database stored in the directory dbPath, or the bundled one, for the ROM.
+func lookupRom(rom []byte, dbPath string) (*romdb.Entry, bool, error) {
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry, found := db.Lookup(rom)
+	return entry, found, nil
+}
@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/waldgaenger/go-acht/internal/audio"
+	"github.com/waldgaenger/go-acht/internal/capture"
+	"github.com/waldgaenger/go-acht/internal/cheats"
 	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/debugger"
 	"github.com/waldgaenger/go-acht/internal/input"
+	"github.com/waldgaenger/go-acht/internal/netplay"
 	"github.com/waldgaenger/go-acht/internal/renderer"
 )
 
@@ -16,9 +23,47 @@ var (
 	flagRom          = flag.String("rom", "", "Set this flag to provide a path to a ROM file.")
 	flagColorProfile = flag.String("colorprofile", "black-white", "Set this flag to provide a color hprofile.")
 	flagScale        = flag.Int("scale", 20, "Set this flag to provide a screen scale factor.")
+	flagDebug        = flag.Bool("debug", false, "Set this flag to read debugger commands from the standard input.")
+	flagHistory      = flag.Int("history", 100000, "Set this flag to provide the number of instructions the debugger can step backwards.")
+	flagQuirks       = flag.String("quirks", "legacy", "Set this flag to provide a quirk profile (legacy, chip8, schip, xochip) or auto to detect it.")
+	flagRomDB        = flag.String("romdb", "", "Set this flag to provide a directory with the community CHIP-8 database instead of the bundled one.")
+	flagTickrate     = flag.Int("tickrate", chip8.DefaultTickrate, "Set this flag to provide the number of instructions per frame.")
+	flagScaling      = flag.String("scaling", "aspect", "Set this flag to scale the display by whole multiples (integer) or to fill the window (aspect).")
+	flagFilters      = flag.String("filters", "", "Set this flag to provide post-processing filters applied in order, e.g. ghosting:0.6,scale2x,scanlines,crt.")
+	flagKeypad       = flag.Bool("keypad", false, "Set this flag to show a clickable hex keypad next to the display.")
+	flagConfig       = flag.String("config", "", "Set this flag to provide the path of the configuration file.")
+	flagNetHost      = flag.String("host", "", "Set this flag to provide the address to wait for a second player on, e.g. :7000.")
+	flagNetJoin      = flag.String("join", "", "Set this flag to provide the address of the host to play with, e.g. 192.168.0.2:7000.")
+	flagNetDelay     = flag.Int("delay", 0, fmt.Sprintf("Set this flag to provide the input delay of netplay in frames, %d if neither it nor -rollback is given.", netplay.DefaultDelay))
+	flagNetRollback  = flag.Int("rollback", 0, fmt.Sprintf("Set this flag to provide the number of frames netplay may roll back instead of waiting for the second player, at most %d. 0 plays in lockstep.", netplay.MaxRollback))
+	flagPatch        = flag.String("patch", "", "Set this flag to provide IPS or BPS patches applied in order to the ROM, e.g. fix.ips,translation.bps.")
 )
 
+// commands holds the subcommands, which are selected by the first argument.
+// Without a subcommand the emulator is started.
+var commands = map[string]func(args []string) error{
+	"analyze": analyze,
+	"capture": record,
+	"config":  configure,
+	"gym":     runGym,
+	"info":    info,
+	"pack":    pack,
+	"patch":   createPatch,
+	"quirks":  quirks,
+	"serve":   serve,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if *flagRom == "" {
@@ -27,26 +72,142 @@ func main() {
 		return
 	}
 
-	if *flagColorProfile != "" {
-		profile, found := renderer.Profiles[*flagColorProfile]
+	file, err := loadConfig(*flagConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	flags := flagSettings()
 
-		if !found {
-			profile = renderer.Profiles["black-white"]
-			fmt.Printf("no such color profile: %s - fallback: default profile black-white will be used \n", *flagColorProfile)
-		}
-		renderer.Profile = profile
+	rom, cart, err := readRom(romPath(*flagRom, file, flags))
+	if err == nil {
+		rom, err = applyPatches(rom, *flagPatch)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	cfg, entry, err := effectiveConfig(file, rom, cart, flags)
+	if err != nil {
+		fmt.Println("could not look up the ROM in the database: ", err)
+	} else if entry != nil {
+		fmt.Printf("recognized ROM: %s\n", entry.Program.Title)
 	}
 
-	r, err := renderer.NewSDLRenderer()
+	applyPalette(cfg)
+
+	if cfg.Scaling != "aspect" && cfg.Scaling != "integer" {
+		fmt.Printf("no such scaling mode: %s - fallback: aspect will be used \n", cfg.Scaling)
+	}
+	r, err := renderer.NewSDLRenderer(cfg.Scale, cfg.Scaling == "integer")
 
 	if err != nil {
 		fmt.Println("an error occurred while trying to create a new SDLRenderer: ", err)
 		os.Exit(-1)
 	}
 
-	c8 := chip8.Chip8{Input: &input.SDLInput{}, Renderer: r}
+	in := &input.SDLInput{}
+	in.BindButtons(cfg.Buttons)
+	if err := in.BindKeys(cfg.Keyboard); err != nil {
+		fmt.Println("invalid key bindings: ", err)
+	}
+	if err := in.BindController(cfg.Controller); err != nil {
+		fmt.Println("invalid controller bindings: ", err)
+	}
+	defer in.Cleanup()
+	filters, err := renderer.ParsePipeline(cfg.Filters)
+	if err != nil {
+		fmt.Printf("%v - no filters will be used \n", err)
+	}
+	r.SetFilters(filters)
+	if cfg.Keypad != nil && *cfg.Keypad {
+		r.ShowKeypad()
+		in.Keypad(r.KeypadKey)
+	}
+	in.Hotkey(sdl.K_F2, func() { fmt.Printf("color profile: %s\n", renderer.Cycle()) })
+	in.Hotkey(sdl.K_F11, func() {
+		if err := r.ToggleFullscreen(); err != nil {
+			fmt.Println(err)
+		}
+	})
+	// The recorder has its own copy of the filters, since filters like ghosting keep the previous frame.
+	rec := &capture.Recorder{Renderer: r, Scale: cfg.Scale, Filters: filters.Clone()}
+	captures := &hotkeyCapture{rec: rec, cfg: cfg, dir: cfg.Paths.Screenshots, rom: *flagRom}
+	in.Hotkey(sdl.K_F12, captures.screenshot)
+	in.Hotkey(sdl.K_F10, captures.toggleGIF)
+	in.Hotkey(sdl.K_F9, captures.toggleVideo)
+	c8 := chip8.Chip8{Input: in, Renderer: rec, Audio: rec, Tickrate: cfg.Tickrate}
+	rec.Clock = c8.Frame
+
+	c8.Quirks = resolveQuirks(cfg, rom, os.Stdout)
+
+	// Cheats change the memory of one side only, which would break the synchronization of netplay.
+	var engine *cheats.Engine
+	var cheatFile string
+	if *flagNetHost == "" && *flagNetJoin == "" {
+		engine, cheatFile, err = loadCheats(cfg, rom)
+		if err != nil {
+			fmt.Printf("cheats are disabled: %v\n", err)
+		}
+	}
 
-	if err := c8.Run(*flagRom); err != nil {
+	tracker, err := startAchievements(cfg, rom, r, engine, *flagDebug)
+	if err != nil {
+		fmt.Printf("achievements are disabled: %v\n", err)
+	} else if tracker != nil {
+		tracker.Renderer = rec
+		tracker.Machine = &c8
+		c8.Renderer = tracker
+		defer func() {
+			if err := tracker.Err(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
+	if engine != nil {
+		engine.Renderer = c8.Renderer
+		engine.Machine = &c8
+		c8.Renderer = engine
+	}
+
+	if cfg.Audio.Enabled != nil && *cfg.Audio.Enabled {
+		beeper, err := audio.NewSDLBeeper(cfg.Audio.Frequency, cfg.Audio.Volume)
+		if err != nil {
+			fmt.Println("sound is disabled: ", err)
+		} else {
+			defer beeper.Cleanup()
+			rec.Audio = beeper
+		}
+	}
+
+	if *flagDebug {
+		d := debugger.New(os.Stdout)
+		d.Console(os.Stdin)
+		d.Cheats, d.CheatFile = engine, cheatFile
+		c8.Debugger = d
+		c8.EnableHistory(*flagHistory)
+		fmt.Println("debugger attached, type help for a list of commands")
+	}
+
+	if *flagNetHost != "" || *flagNetJoin != "" {
+		session, err := startNetplay(&c8, rom, in)
+		if err != nil {
+			fmt.Println(err)
+			r.Cleanup()
+			os.Exit(-1)
+		}
+		defer func() {
+			if err := session.Err(); err != nil {
+				fmt.Println(err)
+			}
+			session.Close()
+		}()
+		c8.Input = session
+	}
+
+	if err := c8.RunROM(rom); err != nil {
 		slog.Error("an error occurred while trying to run the emulator: " + err.Error())
 		r.Cleanup()
 		os.Exit(-1)
@@ -54,3 +215,30 @@ func main() {
 
 	sdl.Quit()
 }
+
+// flagSettings returns the settings given on the command line. Flags which are not given do not
+// override the configuration.
+func flagSettings() *config.Config {
+	cfg := &config.Config{}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quirks":
+			cfg.Quirks = &config.Quirks{Profile: *flagQuirks}
+		case "tickrate":
+			cfg.Tickrate = *flagTickrate
+		case "colorprofile":
+			cfg.ColorProfile = *flagColorProfile
+		case "scale":
+			cfg.Scale = *flagScale
+		case "scaling":
+			cfg.Scaling = *flagScaling
+		case "romdb":
+			cfg.Paths = &config.Paths{Database: *flagRomDB}
+		case "keypad":
+			cfg.Keypad = flagKeypad
+		case "filters":
+			cfg.Filters = strings.Split(*flagFilters, ",")
+		}
+	})
+	return cfg
+}
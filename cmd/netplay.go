@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/input"
+	"github.com/waldgaenger/go-acht/internal/netplay"
+)
+
+// startNetplay waits for a guest on the address given by -host or connects to the host given by
+// -join. The returned session reads the keys of the local player from local.
+func startNetplay(c8 *chip8.Chip8, rom []byte, local input.InputHandler) (*netplay.Session, error) {
+	if *flagNetHost != "" {
+		listener, err := net.Listen("tcp", *flagNetHost)
+		if err != nil {
+			return nil, fmt.Errorf("could not listen on %s: %w", *flagNetHost, err)
+		}
+		defer listener.Close()
+
+		fmt.Printf("waiting for the second player on %s\n", listener.Addr())
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil, fmt.Errorf("could not accept the second player: %w", err)
+		}
+		session, err := netplay.Host(conn, c8, rom, local, netplay.Options{Delay: *flagNetDelay, Rollback: *flagNetRollback})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		fmt.Printf("%s joined\n", conn.RemoteAddr())
+		return session, nil
+	}
+
+	conn, err := net.Dial("tcp", *flagNetJoin)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %w", *flagNetJoin, err)
+	}
+	session, err := netplay.Join(conn, c8, rom, local)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fmt.Printf("joined %s\n", *flagNetJoin)
+	return session, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/cartridge"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// labelFrames is the number of frames the ROM runs before its screen is taken as the label of the cartridge.
+const labelFrames = 120
+
+// pack writes a ROM together with its settings into an Octo cartridge.
+// Settings which are not given are taken from the ROM database.
+func pack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	output := fs.String("o", "", "Set this flag to provide the path of the cartridge, the ROM path with .gif by default.")
+	quirkProfile := fs.String("quirks", "", "Set this flag to provide a quirk profile (legacy, chip8, schip, xochip).")
+	tickrate := fs.Int("tickrate", 0, "Set this flag to provide the number of instructions per frame.")
+	colorProfile := fs.String("colorprofile", "", "Set this flag to provide a color profile.")
+	keys := fs.String("keys", "", "Set this flag to bind buttons to CHIP-8 keys, e.g. up=5,down=8,a=6.")
+	dbPath := fs.String("romdb", "", "Set this flag to provide a directory with the community CHIP-8 database instead of the bundled one.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht pack [flags] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	rom, _, err := readRom(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cart := &cartridge.Cartridge{Options: cartridge.DefaultOptions()}
+	cart.SetROM(rom)
+	opts := &cart.Options
+
+	entry, known, err := lookupRom(rom, *dbPath)
+	if err != nil {
+		return err
+	}
+	if known {
+		if quirks, ok := entry.Quirks(); ok {
+			opts.SetQuirks(quirks)
+		}
+		if t := entry.Tickrate(); t > 0 {
+			opts.Tickrate = t
+		}
+		opts.Keys = entry.ROM.Keys
+		if palette, err := entry.Palette(); err == nil && palette != nil {
+			setColors(opts, *palette)
+		}
+	}
+
+	if *quirkProfile != "" {
+		quirks, found := chip8.QuirkProfiles[*quirkProfile]
+		if !found {
+			return fmt.Errorf("no such quirk profile: %s", *quirkProfile)
+		}
+		opts.SetQuirks(quirks)
+	}
+	if *tickrate > 0 {
+		opts.Tickrate = *tickrate
+	}
+	if *colorProfile != "" {
+		profile, found := renderer.Profiles[*colorProfile]
+		if !found {
+			return fmt.Errorf("no such color profile: %s", *colorProfile)
+		}
+		setColors(opts, profile)
+	}
+	if *keys != "" {
+		opts.Keys, err = parseButtons(*keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The label shows the screen of the ROM after it ran for a while.
+	c8 := &chip8.Chip8{Quirks: opts.Quirks(), Tickrate: opts.Tickrate}
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+	for range labelFrames {
+		c8.StepFrame()
+	}
+
+	path := *output
+	if path == "" {
+		path = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".gif"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create cartridge: %w", err)
+	}
+	defer f.Close()
+
+	return cartridge.Encode(f, cart, c8.Display())
+}
+
+// readRom reads the ROM stored at path. If the file is an Octo cartridge, the program is extracted and
+// the cartridge is returned as well.
+func readRom(path string) ([]byte, *cartridge.Cartridge, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open ROM file: %w", err)
+	}
+	if !cartridge.IsCartridge(data) {
+		return data, nil, nil
+	}
+
+	cart, err := cartridge.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	rom, err := cart.ROM()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rom, cart, nil
+}
+
+// parseButtons parses button bindings given as a comma separated list of button=key pairs.
+func parseButtons(s string) (map[string]uint8, error) {
+	buttons := map[string]uint8{}
+	for _, binding := range strings.Split(s, ",") {
+		button, key, found := strings.Cut(binding, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid key binding: %s", binding)
+		}
+		k, err := strconv.ParseUint(key, 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHIP-8 key in binding: %s", binding)
+		}
+		buttons[strings.TrimSpace(button)] = uint8(k)
+	}
+	return buttons, nil
+}
+
+// setColors sets the colors of the options to the palette.
+func setColors(opts *cartridge.Options, p renderer.Palette) {
+	opts.BackgroundColor = renderer.FormatColor(p.Background)
+	opts.FillColor = renderer.FormatColor(p.Foreground)
+	opts.FillColor2 = renderer.FormatColor(p.Foreground2)
+	opts.BlendColor = renderer.FormatColor(p.Blend)
+}
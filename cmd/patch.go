@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/patch"
+)
+
+// createPatch writes a patch which turns the original ROM into the modified one. The format is
+// given by -format or the extension of the patch file, BPS by default.
+func createPatch(args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Println("usage: go-acht patch create [-format ips|bps] <original> <modified> <patch>")
+		return fmt.Errorf("expected a patch subcommand")
+	}
+
+	fs := flag.NewFlagSet("patch create", flag.ExitOnError)
+	format := fs.String("format", "", "Set this flag to provide the format of the patch (ips, bps), by default the extension of the patch file or bps.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht patch create [-format ips|bps] <original> <modified> <patch>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return fmt.Errorf("expected the original ROM, the modified ROM and the patch file")
+	}
+
+	f, ok := patch.FormatOf(fs.Arg(2))
+	if !ok {
+		f = patch.BPS
+	}
+	if *format != "" {
+		var err error
+		if f, err = patch.ParseFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	original, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not open ROM file: %w", err)
+	}
+	modified, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("could not open ROM file: %w", err)
+	}
+
+	p, err := patch.Create(f, original, modified)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.Arg(2), p, 0o644); err != nil {
+		return fmt.Errorf("could not save the patch: %w", err)
+	}
+	fmt.Printf("saved %s patch of %d bytes to %s\n", strings.ToUpper(f.String()), len(p), fs.Arg(2))
+	return nil
+}
+
+// applyPatches applies the patch files given as comma separated list in order to the ROM. The commands
+// which run a ROM call it right after readRom, so that the database lookup, the quirk detection and
+// everything else working with the ROM sees the patched bytes.
+func applyPatches(rom []byte, list string) ([]byte, error) {
+	if list == "" {
+		return rom, nil
+	}
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		p, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open patch file: %w", err)
+		}
+		if rom, err = patch.Apply(rom, p); err != nil {
+			return nil, fmt.Errorf("could not apply patch %s: %w", path, err)
+		}
+	}
+	return rom, nil
+}
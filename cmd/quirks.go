@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/waldgaenger/go-acht/internal/detect"
+)
+
+// quirks detects the quirks a ROM needs and prints the suggested profile with its reasoning.
+func quirks(args []string) error {
+	fs := flag.NewFlagSet("quirks", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht quirks <rom>")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	rom, _, err := readRom(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	report, err := detect.Quirks(rom)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/stream"
+)
+
+// serve runs a ROM without a window and streams it to browsers, which open the web client at the
+// given address. The first browser to connect plays, the others watch.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Set this flag to provide the address to listen on, e.g. :8080 to accept remote clients.")
+	allowOrigin := fs.String("allow-origin", "", "Set this flag to allow web pages of other hosts to connect, e.g. example.com,https://example.org:8000 or * for every page.")
+	path := fs.String("config", "", "Set this flag to provide the path of the configuration file.")
+	quirkProfile := fs.String("quirks", "", "Set this flag to provide a quirk profile (legacy, chip8, schip, xochip) or auto to detect it.")
+	tickrate := fs.Int("tickrate", 0, "Set this flag to provide the number of instructions per frame.")
+	colorProfile := fs.String("colorprofile", "", "Set this flag to provide a color profile.")
+	patches := fs.String("patch", "", "Set this flag to provide IPS or BPS patches applied in order to the ROM, e.g. fix.ips,translation.bps.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-acht serve [-addr host:port] [flags] <rom>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one ROM file")
+	}
+
+	flags := &config.Config{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quirks":
+			flags.Quirks = &config.Quirks{Profile: *quirkProfile}
+		case "tickrate":
+			flags.Tickrate = *tickrate
+		case "colorprofile":
+			flags.ColorProfile = *colorProfile
+		}
+	})
+
+	file, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+	rom, cart, err := readRom(romPath(fs.Arg(0), file, flags))
+	if err != nil {
+		return err
+	}
+	if rom, err = applyPatches(rom, *patches); err != nil {
+		return err
+	}
+	cfg, entry, err := effectiveConfig(file, rom, cart, flags)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		fmt.Printf("recognized ROM: %s\n", entry.Program.Title)
+	}
+	applyPalette(cfg)
+
+	volume := cfg.Audio.Volume
+	if cfg.Audio.Enabled != nil && !*cfg.Audio.Enabled {
+		volume = 0
+	}
+	server := stream.NewServer(cfg.Audio.Frequency, volume)
+	if *allowOrigin != "" {
+		server.AllowedOrigins = strings.Split(*allowOrigin, ",")
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", *addr, err)
+	}
+	httpServer := &http.Server{Handler: server}
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println(err)
+		}
+	}()
+	defer httpServer.Close()
+	fmt.Printf("serving on http://%s\n", listener.Addr())
+
+	// Interrupting stops the emulator at its next frame.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			server.Close()
+		}
+	}()
+
+	c8 := chip8.Chip8{Input: server, Renderer: server, Audio: server, Tickrate: cfg.Tickrate}
+	c8.Quirks = resolveQuirks(cfg, rom, os.Stdout)
+	return c8.RunROM(rom)
+}
@@ -0,0 +1,191 @@
+//go:build js && wasm
+
+// Command wasm runs the emulator in the browser. It draws on a canvas, reads the keyboard and an
+// on-screen keypad of the page and beeps with Web Audio. Build it and copy the loader of the Go
+// distribution next to index.html:
+//
+//	GOOS=js GOARCH=wasm go build -o cmd/wasm/go-acht.wasm ./cmd/wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" cmd/wasm/
+//
+// and serve the directory with any static web server. The page starts a ROM by calling loadROM with
+// its bytes as Uint8Array.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"syscall/js"
+
+	"github.com/waldgaenger/go-acht/internal/audio"
+	"github.com/waldgaenger/go-acht/internal/cartridge"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/config"
+	"github.com/waldgaenger/go-acht/internal/detect"
+	"github.com/waldgaenger/go-acht/internal/input"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+	"github.com/waldgaenger/go-acht/internal/romdb"
+)
+
+const (
+	frameTime     = 1000.0 / 60 // Duration of a frame in milliseconds
+	maxFrameSteps = 4           // Limits the frames caught up in one animation frame, e.g. after the tab was hidden
+)
+
+// emulator runs a ROM in the animation frames of the browser instead of the ticker of Run.
+type emulator struct {
+	c8      *chip8.Chip8
+	in      *input.BrowserInput
+	r       *renderer.CanvasRenderer
+	loop    js.Func
+	last    float64 // Timestamp of the previous animation frame
+	lag     float64 // Time not emulated yet
+	running bool
+}
+
+func main() {
+	document := js.Global().Get("document")
+
+	r, err := renderer.NewCanvasRenderer(document.Call("getElementById", "screen"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	e := &emulator{in: input.NewBrowserInput(document), r: r}
+	e.c8 = &chip8.Chip8{Input: e.in, Renderer: r}
+
+	cfg := config.Default()
+	if beeper, err := audio.NewWebAudioBeeper(document, cfg.Audio.Frequency, cfg.Audio.Volume); err != nil {
+		fmt.Println("sound disabled: ", err)
+	} else {
+		e.c8.Audio = beeper
+	}
+
+	e.loop = js.FuncOf(func(this js.Value, args []js.Value) any {
+		e.frame(args[0].Float())
+		return nil
+	})
+
+	js.Global().Set("loadROM", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) != 1 {
+			return "expected the ROM as Uint8Array"
+		}
+		data := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(data, args[0])
+		if err := e.load(data); err != nil {
+			fmt.Println(err)
+			return err.Error()
+		}
+		return nil
+	}))
+
+	select {}
+}
+
+// load starts the ROM or cartridge with the settings recommended for it.
+func (e *emulator) load(data []byte) error {
+	rom := data
+	var cart *cartridge.Cartridge
+	if cartridge.IsCartridge(data) {
+		var err error
+		if cart, err = cartridge.Decode(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		if rom, err = cart.ROM(); err != nil {
+			return err
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Merge(recommendedConfig(rom, cart))
+
+	if len(cfg.Palette) > 0 {
+		if palette, err := cfg.Colors(); err == nil {
+			renderer.SetProfile(palette)
+		}
+	} else if !renderer.Use(cfg.ColorProfile) {
+		renderer.Use("black-white")
+	}
+	if pipeline, err := renderer.ParsePipeline(cfg.Filters); err == nil {
+		e.r.SetFilters(pipeline)
+	}
+	e.in.BindButtons(cfg.Buttons)
+
+	quirks, err := cfg.Quirks.Resolve(func() (chip8.Quirks, error) {
+		report, err := detect.Quirks(rom)
+		if err != nil {
+			return chip8.Quirks{}, err
+		}
+		return report.Quirks, nil
+	})
+	if err != nil {
+		quirks = chip8.Quirks{}
+	}
+	e.c8.Quirks = quirks
+	e.c8.Tickrate = cfg.Tickrate
+
+	if err := e.c8.Load(rom); err != nil {
+		return fmt.Errorf("failed to load ROM: %w", err)
+	}
+
+	if !e.running {
+		e.running = true
+		e.last = 0
+		js.Global().Call("requestAnimationFrame", e.loop)
+	}
+	return nil
+}
+
+// frame runs as many 60 Hz ticks as the time since the previous animation frame covers, so that the
+// speed does not depend on the refresh rate of the display.
+func (e *emulator) frame(now float64) {
+	if e.last != 0 {
+		e.lag += now - e.last
+	} else {
+		e.lag = frameTime
+	}
+	e.last = now
+
+	for steps := 0; e.lag >= frameTime; steps++ {
+		if steps == maxFrameSteps {
+			e.lag = 0
+			break
+		}
+		e.c8.Tick()
+		e.lag -= frameTime
+	}
+
+	js.Global().Call("requestAnimationFrame", e.loop)
+}
+
+// recommendedConfig returns the settings recommended by the cartridge or, for other ROMs, by the
+// bundled ROM database.
+func recommendedConfig(rom []byte, cart *cartridge.Cartridge) *config.Config {
+	cfg := &config.Config{}
+
+	if cart != nil {
+		cfg.Quirks = config.QuirksOf(cart.Options.Quirks())
+		cfg.Tickrate = cart.Options.Tickrate
+		cfg.Buttons = cart.Options.Keys
+		cfg.Palette = cart.Options.Colors()
+		return cfg
+	}
+
+	db, err := romdb.Default()
+	if err != nil {
+		return cfg
+	}
+	entry, found := db.Lookup(rom)
+	if !found {
+		return cfg
+	}
+	if quirks, ok := entry.Quirks(); ok {
+		cfg.Quirks = config.QuirksOf(quirks)
+	}
+	cfg.Tickrate = entry.Tickrate()
+	cfg.Buttons = entry.ROM.Keys
+	if entry.ROM.Colors != nil {
+		cfg.Palette = entry.ROM.Colors.Pixels
+	}
+	return cfg
+}
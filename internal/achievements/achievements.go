@@ -0,0 +1,120 @@
+// Package achievements unlocks achievements while a ROM is played, like RetroAchievements but without
+// a server. An achievement is unlocked once its condition over the memory and the registers holds at
+// the end of a frame, e.g. when the score byte at 0x2F5 reaches 10. The conditions are written in the
+// expression language of the debugger. Unlocked achievements are kept in a local file together with
+// the time they were unlocked, so that they stay unlocked.
+package achievements
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/debugger"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// Achievement is defined per ROM, in the ROM database or in the configuration file.
+type Achievement struct {
+	ID          string `json:"id"` // Unique among the achievements of the ROM, kept in the file of the unlocked ones
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Condition   string `json:"condition"` // Expression of the debugger, e.g. mem[0x2F5] >= 10
+}
+
+// Validate reports an error if the achievement has no ID or its condition is invalid.
+func (a Achievement) Validate() error {
+	if a.ID == "" {
+		return fmt.Errorf("achievement %q has no id", a.Title)
+	}
+	if _, err := debugger.Compile(a.Condition); err != nil {
+		return fmt.Errorf("invalid condition of achievement %s: %w", a.ID, err)
+	}
+	return nil
+}
+
+// Tracker checks the achievements of a ROM after every frame. It is a renderer which passes the
+// frames on to Renderer, so that it sees every frame the emulator draws.
+type Tracker struct {
+	Renderer renderer.Renderer // Optional renderer which shows the frames
+	// Machine is the emulator the conditions are evaluated against, e.g. the Chip8 running the ROM.
+	Machine debugger.Machine
+	// Unlocked is called when an achievement is unlocked, e.g. to show a toast.
+	Unlocked func(a Achievement)
+
+	rom     string // SHA-1 hash of the ROM
+	pending []pending
+	store   *Store
+	err     error
+}
+
+// pending is an achievement which is not unlocked yet.
+type pending struct {
+	Achievement
+	condition *debugger.Expr
+}
+
+// NewTracker returns a tracker of the achievements of the ROM with the given SHA-1 hash. The
+// achievements which the store holds as unlocked are not checked again.
+func NewTracker(rom string, list []Achievement, store *Store) (*Tracker, error) {
+	t := &Tracker{rom: rom, store: store}
+	ids := map[string]bool{}
+	for _, a := range list {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+		if ids[a.ID] {
+			return nil, fmt.Errorf("achievement %s is defined twice", a.ID)
+		}
+		ids[a.ID] = true
+		if _, unlocked := store.Unlocked(rom)[a.ID]; unlocked {
+			continue
+		}
+		condition, _ := debugger.Compile(a.Condition)
+		t.pending = append(t.pending, pending{Achievement: a, condition: condition})
+	}
+	return t, nil
+}
+
+// Draw checks the achievements and passes the display on to the renderer.
+func (t *Tracker) Draw(display [32][64]bool) {
+	if t.Machine != nil {
+		t.Check(t.Machine)
+	}
+	if t.Renderer != nil {
+		t.Renderer.Draw(display)
+	}
+}
+
+// SetKeypad implements renderer.KeypadRenderer.
+func (t *Tracker) SetKeypad(pressed, polled [16]bool) {
+	renderer.ForwardKeypad(t.Renderer, pressed, polled)
+}
+
+// Check unlocks the achievements whose condition holds for m. A condition which cannot be evaluated,
+// e.g. because of a division by zero, does not hold.
+func (t *Tracker) Check(m debugger.Machine) {
+	remaining := t.pending[:0]
+	for _, p := range t.pending {
+		if value, err := p.condition.Eval(m); err != nil || value == 0 {
+			remaining = append(remaining, p)
+			continue
+		}
+		if err := t.store.Unlock(t.rom, p.ID, time.Now()); err != nil && t.err == nil {
+			t.err = err
+		}
+		if t.Unlocked != nil {
+			t.Unlocked(p.Achievement)
+		}
+	}
+	t.pending = remaining
+}
+
+// Remaining returns the number of achievements which are not unlocked yet.
+func (t *Tracker) Remaining() int {
+	return len(t.pending)
+}
+
+// Err returns the first error which occurred while saving an unlocked achievement.
+func (t *Tracker) Err() error {
+	return t.err
+}
@@ -0,0 +1,148 @@
+package achievements
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// counter is a ROM which adds 1 to V0 in a loop, once per frame with a tickrate of 2.
+var counter = []byte{
+	0x70, 0x01, // ADD V0, 1
+	0x12, 0x00, // JP 0x200
+}
+
+const rom = "b232ef880bd6060fb45fa6effed7edf0ae95670e"
+
+var list = []Achievement{
+	{ID: "three", Title: "Three", Condition: "V0 >= 3"},
+	{ID: "five", Title: "Five", Condition: "V0 == 5"},
+	{ID: "never", Title: "Never", Condition: "V0 / (V0 - V0)"},
+}
+
+// play runs the counter ROM for the given number of frames with the tracker as renderer and returns
+// the titles of the achievements unlocked on the way.
+func play(t *testing.T, tracker *Tracker, frames int) []string {
+	t.Helper()
+	c8 := &chip8.Chip8{Tickrate: 2}
+	if err := c8.Load(counter); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var unlocked []string
+	tracker.Unlocked = func(a Achievement) { unlocked = append(unlocked, a.Title) }
+	for range frames {
+		c8.StepFrame()
+		tracker.Check(c8)
+	}
+	return unlocked
+}
+
+func TestTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-acht", "achievements.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tracker, err := NewTracker(rom, list, store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("Unlocks once", func(t *testing.T) {
+		unlocked := play(t, tracker, 10)
+		if strings.Join(unlocked, ",") != "Three,Five" {
+			t.Errorf("Expected Three,Five to be unlocked but got %v", unlocked)
+		}
+		if tracker.Remaining() != 1 {
+			t.Errorf("Expected 1 remaining achievement but got %d", tracker.Remaining())
+		}
+		if tracker.Err() != nil {
+			t.Errorf("unexpected error: %v", tracker.Err())
+		}
+	})
+
+	t.Run("Persisted", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(string(data), `"three": "`) || !strings.Contains(string(data), `"five": "`) {
+			t.Errorf("Expected both achievements in the file but got %s", data)
+		}
+
+		store, err := OpenStore(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if at := store.Unlocked(rom)["three"]; at.IsZero() || at.Location().String() != "UTC" {
+			t.Errorf("Expected the UTC time three was unlocked at but got %v", at)
+		}
+		tracker, err := NewTracker(rom, list, store)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if unlocked := play(t, tracker, 10); len(unlocked) != 0 {
+			t.Errorf("Expected nothing to be unlocked again but got %v", unlocked)
+		}
+	})
+
+	t.Run("Other ROM", func(t *testing.T) {
+		tracker, err := NewTracker("other", list, store)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tracker.Remaining() != len(list) {
+			t.Errorf("Expected %d remaining achievements but got %d", len(list), tracker.Remaining())
+		}
+	})
+}
+
+func TestNewTracker(t *testing.T) {
+	tests := []struct {
+		testName string
+		list     []Achievement
+		err      string
+	}{
+		{
+			testName: "Missing ID",
+			list:     []Achievement{{Title: "Three", Condition: "V0 >= 3"}},
+			err:      `achievement "Three" has no id`,
+		},
+		{
+			testName: "Invalid condition",
+			list:     []Achievement{{ID: "three", Condition: "V0 >="}},
+			err:      "invalid condition of achievement three",
+		},
+		{
+			testName: "Duplicate ID",
+			list:     []Achievement{list[0], list[0]},
+			err:      "achievement three is defined twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			store, err := OpenStore(filepath.Join(t.TempDir(), "achievements.json"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = NewTracker(rom, tt.list, store)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error %q but got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestOpenStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Errorf("Expected an error for an invalid file but got none")
+	}
+}
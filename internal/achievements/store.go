@@ -0,0 +1,84 @@
+package achievements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store keeps the unlocked achievements in a JSON file, which maps the SHA-1 hashes of the ROMs to
+// the IDs of their unlocked achievements and the times they were unlocked:
+//
+//	{"b232ef880bd6060fb45fa6effed7edf0ae95670e": {"first-point": "2024-05-01T18:30:00Z"}}
+type Store struct {
+	path     string
+	unlocked map[string]map[string]time.Time
+}
+
+// DefaultStorePath returns the path of the file the unlocked achievements are kept in if no other
+// one is configured.
+func DefaultStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-acht", "achievements.json"), nil
+}
+
+// OpenStore reads the unlocked achievements from the file at path. A missing file holds none, it is
+// created once the first achievement is unlocked.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, unlocked: map[string]map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the achievements: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.unlocked); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Unlocked returns the IDs of the unlocked achievements of the ROM with the times they were unlocked.
+func (s *Store) Unlocked(rom string) map[string]time.Time {
+	return s.unlocked[rom]
+}
+
+// Unlock records the achievement of the ROM as unlocked at the given time and saves the file. An
+// achievement which is already unlocked keeps its time.
+func (s *Store) Unlock(rom, id string, at time.Time) error {
+	if _, found := s.unlocked[rom][id]; found {
+		return nil
+	}
+	if s.unlocked[rom] == nil {
+		s.unlocked[rom] = map[string]time.Time{}
+	}
+	s.unlocked[rom][id] = at.UTC().Truncate(time.Second)
+	return s.save()
+}
+
+// save writes the file, replacing the previous one only once it is written completely.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.unlocked, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("could not save the achievements: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not save the achievements: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("could not save the achievements: %w", err)
+	}
+	return nil
+}
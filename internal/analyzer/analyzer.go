@@ -0,0 +1,450 @@
+// Package analyzer statically analyzes CHIP-8 ROMs without executing them.
+//
+// The code is discovered by a recursive traversal starting at the entry point, which follows jumps,
+// subroutine calls, skips and jump tables (BNNN). The discovered instructions are split into basic
+// blocks, which form the control-flow graph of the ROM. A constant propagation of the index register
+// over this graph detects the sprite data drawn by DXYN and FX55 instructions writing into code.
+package analyzer
+
+import (
+	"slices"
+)
+
+// startAddress is the address the ROM is loaded to and the entry point of every program.
+const startAddress = 0x200
+
+// maxJumpTableEntries is the number of jumps a BNNN jump table can address with V0.
+const maxJumpTableEntries = 128
+
+// Instruction is a single disassembled instruction.
+type Instruction struct {
+	Address uint16 `json:"address"`
+	Opcode  uint16 `json:"opcode"`
+	Text    string `json:"text"`
+}
+
+// Block is a basic block: a sequence of instructions which is only entered at the first
+// and only left after the last instruction.
+type Block struct {
+	Start        uint16        `json:"start"`
+	End          uint16        `json:"end"` // Address after the last instruction
+	Instructions []Instruction `json:"instructions"`
+	Successors   []uint16      `json:"successors"`      // Start addresses of the blocks executed next
+	Calls        []uint16      `json:"calls,omitempty"` // Subroutine called by the last instruction
+}
+
+// Subroutine is a piece of code called by 2NNN and left by 00EE.
+type Subroutine struct {
+	Entry   uint16   `json:"entry"`
+	Blocks  []uint16 `json:"blocks"`  // Start addresses of the blocks reachable from the entry without following calls
+	Returns []uint16 `json:"returns"` // Addresses of the 00EE instructions
+	Callers []uint16 `json:"callers"` // Addresses of the 2NNN instructions calling the subroutine
+}
+
+// JumpTable is a computed jump (BNNN) together with the targets found at its base address.
+type JumpTable struct {
+	Address uint16   `json:"address"` // Address of the BNNN instruction
+	Base    uint16   `json:"base"`
+	Targets []uint16 `json:"targets"`
+}
+
+// SelfModification is an FX55 instruction which stores registers into code.
+type SelfModification struct {
+	Address uint16 `json:"address"` // Address of the FX55 instruction
+	Start   uint16 `json:"start"`   // First address that is written
+	Length  int    `json:"length"`
+}
+
+// SpriteRegion is sprite data referenced by the index register of DXYN instructions.
+type SpriteRegion struct {
+	Start   uint16   `json:"start"`
+	Length  int      `json:"length"`
+	DrawnBy []uint16 `json:"drawnBy"` // Addresses of the DXYN instructions
+}
+
+// Analysis is the result of analyzing a ROM.
+type Analysis struct {
+	Size          int                `json:"size"`
+	Blocks        []*Block           `json:"blocks"`
+	Subroutines   []*Subroutine      `json:"subroutines"`
+	JumpTables    []*JumpTable       `json:"jumpTables"`
+	SelfModifying []SelfModification `json:"selfModifying"`
+	Sprites       []*SpriteRegion    `json:"sprites"`
+}
+
+// Block returns the basic block starting at addr or nil.
+func (a *Analysis) Block(addr uint16) *Block {
+	i, found := slices.BinarySearchFunc(a.Blocks, addr, func(b *Block, addr uint16) int {
+		return int(b.Start) - int(addr)
+	})
+	if !found {
+		return nil
+	}
+	return a.Blocks[i]
+}
+
+// IsCode reports whether the byte at addr belongs to a discovered instruction.
+func (a *Analysis) IsCode(addr uint16) bool {
+	return a.containing(addr) != nil
+}
+
+// containing returns the block containing the byte at addr or nil.
+func (a *Analysis) containing(addr uint16) *Block {
+	i, _ := slices.BinarySearchFunc(a.Blocks, addr, func(b *Block, addr uint16) int {
+		return int(b.Start) - int(addr)
+	})
+	// The containing block is either the one starting at addr or its predecessor.
+	for _, j := range []int{i, i - 1} {
+		if j >= 0 && j < len(a.Blocks) && a.Blocks[j].Start <= addr && addr < a.Blocks[j].End {
+			return a.Blocks[j]
+		}
+	}
+	return nil
+}
+
+// program gives access to the instructions of a ROM loaded at the start address.
+type program []byte
+
+// fetch returns the instruction at addr and whether addr lies within the ROM.
+func (p program) fetch(addr uint16) (uint16, bool) {
+	if addr < startAddress || int(addr)+1 >= startAddress+len(p) {
+		return 0, false
+	}
+	offset := int(addr) - startAddress
+	return uint16(p[offset])<<8 | uint16(p[offset+1]), true
+}
+
+// Analyze discovers the code of the ROM and builds its control-flow graph.
+func Analyze(rom []byte) *Analysis {
+	p := program(rom)
+	a := &Analysis{
+		Size:          len(rom),
+		Blocks:        []*Block{},
+		Subroutines:   []*Subroutine{},
+		JumpTables:    []*JumpTable{},
+		SelfModifying: []SelfModification{},
+		Sprites:       []*SpriteRegion{},
+	}
+
+	code := map[uint16]uint16{} // Address -> opcode of every discovered instruction
+	leaders := map[uint16]bool{startAddress: true}
+	callers := map[uint16][]uint16{}
+	tables := map[uint16]*JumpTable{}
+
+	work := []uint16{startAddress}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if _, seen := code[addr]; seen {
+			continue
+		}
+		opcode, ok := p.fetch(addr)
+		if !ok || !valid(opcode) {
+			continue
+		}
+		code[addr] = opcode
+
+		nnn := opcode & 0x0FFF
+		switch {
+		case opcode&0xF000 == 0x2000:
+			callers[nnn] = append(callers[nnn], addr)
+		case opcode&0xF000 == 0xB000:
+			tables[addr] = p.jumpTable(addr, nnn)
+		}
+
+		next := successors(addr, opcode, tables[addr])
+		for _, s := range next {
+			if terminates(opcode) {
+				leaders[s] = true
+			}
+		}
+		if opcode&0xF000 == 0x2000 {
+			leaders[nnn] = true
+		}
+		work = append(work, next...)
+	}
+
+	a.buildBlocks(code, leaders, tables)
+
+	for entry, sites := range callers {
+		if a.Block(entry) == nil {
+			continue
+		}
+		slices.Sort(sites)
+		a.Subroutines = append(a.Subroutines, a.subroutine(entry, sites))
+	}
+	slices.SortFunc(a.Subroutines, func(x, y *Subroutine) int { return int(x.Entry) - int(y.Entry) })
+
+	for _, addr := range sortedKeys(tables) {
+		a.JumpTables = append(a.JumpTables, tables[addr])
+	}
+
+	a.propagateIndex()
+
+	return a
+}
+
+// jumpTable collects the consecutive jumps at base, which a BNNN instruction at addr can select with V0.
+// If there is no jump at base, the base itself is the only known target.
+func (p program) jumpTable(addr, base uint16) *JumpTable {
+	t := &JumpTable{Address: addr, Base: base}
+
+	for i := range uint16(maxJumpTableEntries) {
+		opcode, ok := p.fetch(base + 2*i)
+		if !ok || opcode&0xF000 != 0x1000 {
+			break
+		}
+		t.Targets = append(t.Targets, base+2*i)
+	}
+	if len(t.Targets) == 0 {
+		t.Targets = []uint16{base}
+	}
+
+	return t
+}
+
+// successors returns the addresses executed after the instruction at addr, including called subroutines.
+func successors(addr, opcode uint16, table *JumpTable) []uint16 {
+	switch {
+	case opcode == 0x00EE:
+		return nil
+	case opcode&0xF000 == 0x1000:
+		return []uint16{opcode & 0x0FFF}
+	case opcode&0xF000 == 0x2000:
+		return []uint16{opcode & 0x0FFF, addr + 2}
+	case opcode&0xF000 == 0xB000:
+		return table.Targets
+	case skips(opcode):
+		return []uint16{addr + 2, addr + 4}
+	default:
+		return []uint16{addr + 2}
+	}
+}
+
+// skips reports whether the instruction conditionally skips the next instruction.
+func skips(opcode uint16) bool {
+	switch opcode & 0xF000 {
+	case 0x3000, 0x4000, 0x5000, 0x9000, 0xE000:
+		return true
+	}
+	return false
+}
+
+// terminates reports whether the instruction ends a basic block.
+func terminates(opcode uint16) bool {
+	switch opcode & 0xF000 {
+	case 0x1000, 0x2000, 0xB000:
+		return true
+	}
+	return opcode == 0x00EE || skips(opcode)
+}
+
+func (a *Analysis) buildBlocks(code map[uint16]uint16, leaders map[uint16]bool, tables map[uint16]*JumpTable) {
+	var block *Block
+
+	for _, addr := range sortedKeys(code) {
+		opcode := code[addr]
+
+		if block == nil || leaders[addr] || addr != block.End {
+			if block != nil && len(block.Successors) == 0 {
+				// The block ended because the next instruction is a leader, so it falls through.
+				a.closeBlock(block, code, tables)
+			}
+			block = &Block{Start: addr, End: addr}
+			a.Blocks = append(a.Blocks, block)
+		}
+
+		block.Instructions = append(block.Instructions, Instruction{Address: addr, Opcode: opcode, Text: Disassemble(opcode)})
+		block.End = addr + 2
+
+		if terminates(opcode) {
+			a.closeBlock(block, code, tables)
+			block = nil
+		}
+	}
+	if block != nil {
+		a.closeBlock(block, code, tables)
+	}
+}
+
+// closeBlock sets the successors of a block from its last instruction.
+func (a *Analysis) closeBlock(b *Block, code map[uint16]uint16, tables map[uint16]*JumpTable) {
+	last := b.Instructions[len(b.Instructions)-1]
+
+	var next []uint16
+	if last.Opcode&0xF000 == 0x2000 {
+		b.Calls = []uint16{last.Opcode & 0x0FFF}
+		next = []uint16{last.Address + 2}
+	} else {
+		next = successors(last.Address, last.Opcode, tables[last.Address])
+	}
+
+	b.Successors = []uint16{}
+	for _, s := range next {
+		if _, ok := code[s]; ok && !slices.Contains(b.Successors, s) {
+			b.Successors = append(b.Successors, s)
+		}
+	}
+}
+
+func (a *Analysis) subroutine(entry uint16, sites []uint16) *Subroutine {
+	s := &Subroutine{Entry: entry, Callers: sites, Blocks: []uint16{}, Returns: []uint16{}}
+
+	visited := map[uint16]bool{}
+	work := []uint16{entry}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		b := a.Block(addr)
+		if b == nil || visited[addr] {
+			continue
+		}
+		visited[addr] = true
+		s.Blocks = append(s.Blocks, addr)
+
+		if last := b.Instructions[len(b.Instructions)-1]; last.Opcode == 0x00EE {
+			s.Returns = append(s.Returns, last.Address)
+		}
+		work = append(work, b.Successors...)
+	}
+
+	slices.Sort(s.Blocks)
+	slices.Sort(s.Returns)
+
+	return s
+}
+
+// index is the value of the index register known by the constant propagation.
+type index struct {
+	state int // unvisited, constant or unknown
+	value uint16
+}
+
+const (
+	indexUnvisited = iota
+	indexConstant
+	indexUnknown
+)
+
+// meet combines the index register values of two paths.
+func (i index) meet(o index) index {
+	switch {
+	case i.state == indexUnvisited:
+		return o
+	case o.state == indexUnvisited:
+		return i
+	case i == o:
+		return i
+	default:
+		return index{state: indexUnknown}
+	}
+}
+
+// transfer returns the index register after executing the instruction.
+func (i index) transfer(opcode uint16) index {
+	switch {
+	case opcode&0xF000 == 0xA000:
+		return index{state: indexConstant, value: opcode & 0x0FFF}
+	case opcode&0xF0FF == 0xF01E, opcode&0xF0FF == 0xF029, opcode&0xF0FF == 0xF055, opcode&0xF0FF == 0xF065:
+		// Depending on the quirks FX55 and FX65 modify I as well.
+		return index{state: indexUnknown}
+	}
+	return i
+}
+
+// propagateIndex computes the value of the index register at every instruction where it is known
+// on all paths and records the sprites and self-modifications found with it.
+func (a *Analysis) propagateIndex() {
+	in := map[uint16]index{}
+	if len(a.Blocks) > 0 && a.Blocks[0].Start == startAddress {
+		in[startAddress] = index{state: indexUnknown}
+	}
+
+	work := []uint16{startAddress}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		b := a.Block(addr)
+		if b == nil {
+			continue
+		}
+
+		out := in[addr]
+		for _, inst := range b.Instructions {
+			out = out.transfer(inst.Opcode)
+		}
+
+		// Return sites are entered with an unknown index register because the callee may change it.
+		edges := map[uint16]index{}
+		for _, s := range b.Successors {
+			edges[s] = out
+		}
+		for _, c := range b.Calls {
+			edges[c] = out
+			for _, s := range b.Successors {
+				edges[s] = index{state: indexUnknown}
+			}
+		}
+
+		for _, s := range sortedKeys(edges) {
+			if merged := in[s].meet(edges[s]); merged != in[s] {
+				in[s] = merged
+				work = append(work, s)
+			}
+		}
+	}
+
+	sprites := map[uint16]*SpriteRegion{}
+	for _, b := range a.Blocks {
+		i := in[b.Start]
+		for _, inst := range b.Instructions {
+			if i.state == indexConstant {
+				a.inspect(inst, i.value, sprites)
+			}
+			i = i.transfer(inst.Opcode)
+		}
+	}
+
+	for _, addr := range sortedKeys(sprites) {
+		a.Sprites = append(a.Sprites, sprites[addr])
+	}
+}
+
+// inspect records sprite regions and self-modifications of an instruction executed with a known index register.
+func (a *Analysis) inspect(inst Instruction, i uint16, sprites map[uint16]*SpriteRegion) {
+	switch {
+	case inst.Opcode&0xF000 == 0xD000:
+		length := int(inst.Opcode & 0x000F)
+		if length == 0 {
+			length = 32 // 16x16 sprite of the SUPER-CHIP
+		}
+		r, ok := sprites[i]
+		if !ok {
+			r = &SpriteRegion{Start: i}
+			sprites[i] = r
+		}
+		r.Length = max(r.Length, length)
+		r.DrawnBy = append(r.DrawnBy, inst.Address)
+
+	case inst.Opcode&0xF0FF == 0xF055:
+		length := int((inst.Opcode&0x0F00)>>8) + 1
+		for addr := i; addr < i+uint16(length); addr++ {
+			if a.IsCode(addr) {
+				a.SelfModifying = append(a.SelfModifying, SelfModification{Address: inst.Address, Start: i, Length: length})
+				break
+			}
+		}
+	}
+}
+
+func sortedKeys[V any](m map[uint16]V) []uint16 {
+	keys := make([]uint16, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
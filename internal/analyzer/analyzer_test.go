@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testRom exercises all constructs the analyzer detects.
+var testRom = []byte{
+	0xA2, 0x20, // 0x200: LD I, 0x220
+	0xD0, 0x15, // 0x202: DRW V0, V1, 5
+	0x22, 0x10, // 0x204: CALL 0x210
+	0x30, 0x00, // 0x206: SE V0, 0x00
+	0xB2, 0x18, // 0x208: JP V0, 0x218
+	0x12, 0x00, // 0x20A: JP 0x200
+	0x00, 0x00, // 0x20C: data
+	0x00, 0x00, // 0x20E: data
+	0xA2, 0x0A, // 0x210: LD I, 0x20A
+	0xF1, 0x55, // 0x212: LD [I], V1 (writes into code)
+	0x00, 0xEE, // 0x214: RET
+	0x00, 0x00, // 0x216: data
+	0x12, 0x1C, // 0x218: JP 0x21C (jump table entry 0)
+	0x12, 0x1E, // 0x21A: JP 0x21E (jump table entry 1)
+	0x12, 0x1C, // 0x21C: JP 0x21C
+	0x12, 0x1E, // 0x21E: JP 0x21E
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 0x220: sprite
+}
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze(testRom)
+
+	t.Run("Basic blocks", func(t *testing.T) {
+		var starts []uint16
+		for _, b := range a.Blocks {
+			starts = append(starts, b.Start)
+		}
+		want := []uint16{0x200, 0x206, 0x208, 0x20A, 0x210, 0x218, 0x21A, 0x21C, 0x21E}
+		if !slices.Equal(starts, want) {
+			t.Errorf("block starts: got %#x, want %#x", starts, want)
+		}
+		if b := a.Block(0x200); b == nil || !slices.Equal(b.Calls, []uint16{0x210}) || !slices.Equal(b.Successors, []uint16{0x206}) {
+			t.Errorf("unexpected block at 0x200: %+v", b)
+		}
+		if b := a.Block(0x206); b == nil || !slices.Equal(b.Successors, []uint16{0x208, 0x20A}) {
+			t.Errorf("unexpected successors of the skip at 0x206: %+v", b)
+		}
+	})
+
+	t.Run("Data is not code", func(t *testing.T) {
+		for _, addr := range []uint16{0x20C, 0x216, 0x220} {
+			if a.IsCode(addr) {
+				t.Errorf("expected %#x not to be code", addr)
+			}
+		}
+		if !a.IsCode(0x213) {
+			t.Errorf("expected %#x to be code", 0x213)
+		}
+	})
+
+	t.Run("Subroutines", func(t *testing.T) {
+		if len(a.Subroutines) != 1 {
+			t.Fatalf("expected 1 subroutine, got %d", len(a.Subroutines))
+		}
+		s := a.Subroutines[0]
+		if s.Entry != 0x210 || !slices.Equal(s.Returns, []uint16{0x214}) || !slices.Equal(s.Callers, []uint16{0x204}) {
+			t.Errorf("unexpected subroutine: %+v", s)
+		}
+	})
+
+	t.Run("Jump tables", func(t *testing.T) {
+		if len(a.JumpTables) != 1 {
+			t.Fatalf("expected 1 jump table, got %d", len(a.JumpTables))
+		}
+		if targets := a.JumpTables[0].Targets; !slices.Equal(targets, []uint16{0x218, 0x21A, 0x21C, 0x21E}) {
+			t.Errorf("jump table targets: got %#x", targets)
+		}
+	})
+
+	t.Run("Self-modifying code", func(t *testing.T) {
+		want := []SelfModification{{Address: 0x212, Start: 0x20A, Length: 2}}
+		if !slices.Equal(a.SelfModifying, want) {
+			t.Errorf("got %+v, want %+v", a.SelfModifying, want)
+		}
+	})
+
+	t.Run("Sprites", func(t *testing.T) {
+		if len(a.Sprites) != 1 {
+			t.Fatalf("expected 1 sprite region, got %d", len(a.Sprites))
+		}
+		if r := a.Sprites[0]; r.Start != 0x220 || r.Length != 5 || !slices.Equal(r.DrawnBy, []uint16{0x202}) {
+			t.Errorf("unexpected sprite region: %+v", r)
+		}
+	})
+}
+
+func TestUnknownIndexAfterMerge(t *testing.T) {
+	rom := []byte{
+		0x30, 0x00, // 0x200: SE V0, 0x00
+		0xA3, 0x00, // 0x202: LD I, 0x300
+		0xD0, 0x15, // 0x204: DRW V0, V1, 5 (I is 0x300 or unknown)
+	}
+
+	if a := Analyze(rom); len(a.Sprites) != 0 {
+		t.Errorf("expected no sprite regions, got %+v", a.Sprites[0])
+	}
+}
+
+func TestExport(t *testing.T) {
+	a := Analyze(testRom)
+
+	t.Run("DOT", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := WriteDOT(&out, a); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []string{
+			"digraph rom {",
+			"block_200 -> block_210 [style=dashed label=\"call\"];",
+			"block_208 -> block_21a [style=dotted label=\"table\"];",
+			"sprite_220 [shape=note label=\"sprite 220-224\"];",
+			"writes into code",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected the DOT output to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := WriteJSON(&out, a); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded Analysis
+		if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+			t.Fatalf("could not decode the JSON output: %v", err)
+		}
+		if len(decoded.Blocks) != len(a.Blocks) || decoded.Blocks[0].Instructions[0].Text != "LD I, 0x220" {
+			t.Errorf("unexpected decoded analysis: %+v", decoded)
+		}
+	})
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := map[uint16]string{
+		0x00E0: "CLS",
+		0x00EE: "RET",
+		0x0123: "DW 0x0123",
+		0x1ABC: "JP 0xabc",
+		0x6310: "LD V3, 0x10",
+		0x8AB6: "SHR VA, VB",
+		0x8AB8: "DW 0x8ab8",
+		0xB300: "JP V0, 0x300",
+		0xD125: "DRW V1, V2, 5",
+		0xE5A1: "SKNP V5",
+		0xF455: "LD [I], V4",
+		0xF4FF: "DW 0xf4ff",
+	}
+
+	for opcode, want := range tests {
+		if got := Disassemble(opcode); got != want {
+			t.Errorf("Disassemble(%#04x): got %q, want %q", opcode, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the analysis as indented JSON to w.
+func WriteJSON(w io.Writer, a *Analysis) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// WriteDOT writes the control-flow graph of the analysis in the Graphviz DOT language to w.
+//
+// Every basic block is a node listing its instructions. Subroutine entries are highlighted,
+// calls are drawn dashed and the targets of jump tables dotted. Sprite regions are drawn as
+// separate nodes connected to the blocks drawing them.
+func WriteDOT(w io.Writer, a *Analysis) error {
+	var sb strings.Builder
+
+	entries := map[uint16]bool{}
+	for _, s := range a.Subroutines {
+		entries[s.Entry] = true
+	}
+	tables := map[uint16]bool{}
+	for _, t := range a.JumpTables {
+		tables[t.Address] = true
+	}
+	modified := map[uint16]bool{}
+	for _, m := range a.SelfModifying {
+		modified[m.Address] = true
+	}
+
+	sb.WriteString("digraph rom {\n")
+	sb.WriteString("\tnode [shape=box fontname=\"monospace\"];\n")
+
+	for _, b := range a.Blocks {
+		var label strings.Builder
+		if entries[b.Start] {
+			fmt.Fprintf(&label, "sub_%03x:\\l", b.Start)
+		}
+		for _, inst := range b.Instructions {
+			fmt.Fprintf(&label, "%03x  %04x  %s", inst.Address, inst.Opcode, inst.Text)
+			if modified[inst.Address] {
+				label.WriteString("  ; writes into code")
+			}
+			label.WriteString("\\l")
+		}
+
+		attributes := ""
+		if entries[b.Start] {
+			attributes = " style=filled fillcolor=lightblue"
+		}
+		fmt.Fprintf(&sb, "\t%s [label=\"%s\"%s];\n", node(b.Start), label.String(), attributes)
+	}
+
+	for _, b := range a.Blocks {
+		last := b.Instructions[len(b.Instructions)-1]
+		for _, s := range b.Successors {
+			style := ""
+			if tables[last.Address] {
+				style = " [style=dotted label=\"table\"]"
+			}
+			fmt.Fprintf(&sb, "\t%s -> %s%s;\n", node(b.Start), node(s), style)
+		}
+		for _, c := range b.Calls {
+			fmt.Fprintf(&sb, "\t%s -> %s [style=dashed label=\"call\"];\n", node(b.Start), node(c))
+		}
+	}
+
+	for _, r := range a.Sprites {
+		fmt.Fprintf(&sb, "\tsprite_%03x [shape=note label=\"sprite %03x-%03x\"];\n", r.Start, r.Start, int(r.Start)+r.Length-1)
+		drawn := map[uint16]bool{}
+		for _, addr := range r.DrawnBy {
+			b := a.containing(addr)
+			if b == nil || drawn[b.Start] {
+				continue
+			}
+			drawn[b.Start] = true
+			fmt.Fprintf(&sb, "\t%s -> sprite_%03x [style=dotted arrowhead=none];\n", node(b.Start), r.Start)
+		}
+	}
+
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func node(addr uint16) string {
+	return fmt.Sprintf("block_%03x", addr)
+}
@@ -0,0 +1,38 @@
+package audio
+
+// Beeper abstracts the sound output of the CHIP-8 emulator. The only sound of a CHIP-8 is a tone
+// which plays as long as the sound timer is non-zero. Beep is called once per 60 Hz frame with the
+// state of the tone during that frame.
+type Beeper interface {
+	Beep(on bool)
+}
+
+const (
+	SampleRate      = 44100
+	SamplesPerFrame = SampleRate / 60
+)
+
+// Tone generates the tone as a square wave of 16 bit samples at SampleRate.
+type Tone struct {
+	Frequency int     // Frequency in Hz
+	Volume    float64 // From 0 to 1
+	phase     int     // Position within the period of the square wave, keeps consecutive frames continuous
+}
+
+// Frame fills samples with the samples of the next 60 Hz frame, which usually holds SamplesPerFrame
+// samples. The samples are silent if the tone is off.
+func (t *Tone) Frame(on bool, samples []int16) {
+	period := max(SampleRate/max(t.Frequency, 1), 2)
+	amplitude := int16(min(max(t.Volume, 0), 1) * 32767)
+	for i := range samples {
+		var sample int16
+		if on {
+			sample = amplitude
+			if t.phase >= period/2 {
+				sample = -amplitude
+			}
+			t.phase = (t.phase + 1) % period
+		}
+		samples[i] = sample
+	}
+}
@@ -0,0 +1,55 @@
+//go:build !js
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const maxQueuedFrames = 3 // Limits the latency if the emulation runs ahead of the audio device
+
+// SDLBeeper plays the tone as a square wave on the default audio device.
+type SDLBeeper struct {
+	device  sdl.AudioDeviceID
+	tone    Tone
+	samples []int16
+	buffer  []byte
+}
+
+// NewSDLBeeper opens the default audio device. The frequency of the tone is given in Hz,
+// the volume ranges from 0 to 1.
+func NewSDLBeeper(frequency int, volume float64) (*SDLBeeper, error) {
+	spec := &sdl.AudioSpec{Freq: SampleRate, Format: sdl.AUDIO_S16SYS, Channels: 1, Samples: 1024}
+	device, err := sdl.OpenAudioDevice("", false, spec, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audio device: %w", err)
+	}
+	sdl.PauseAudioDevice(device, false)
+
+	return &SDLBeeper{
+		device:  device,
+		tone:    Tone{Frequency: frequency, Volume: volume},
+		samples: make([]int16, SamplesPerFrame),
+		buffer:  make([]byte, 2*SamplesPerFrame),
+	}, nil
+}
+
+func (b *SDLBeeper) Beep(on bool) {
+	if sdl.GetQueuedAudioSize(b.device) > uint32(maxQueuedFrames*len(b.buffer)) {
+		return
+	}
+
+	b.tone.Frame(on, b.samples)
+	for i, sample := range b.samples {
+		binary.NativeEndian.PutUint16(b.buffer[2*i:], uint16(sample))
+	}
+	sdl.QueueAudio(b.device, b.buffer)
+}
+
+// Cleanup closes the audio device.
+func (b *SDLBeeper) Cleanup() {
+	sdl.CloseAudioDevice(b.device)
+}
@@ -0,0 +1,77 @@
+//go:build js && wasm
+
+package audio
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// WebAudioBeeper plays the tone as a square wave with the Web Audio API. The oscillator runs all the
+// time, Beep only switches its gain.
+type WebAudioBeeper struct {
+	context  js.Value
+	document js.Value
+	gain     js.Value
+	volume   float64
+	on       bool
+	resume   js.Func
+}
+
+// NewWebAudioBeeper creates the audio graph. The frequency of the tone is given in Hz, the volume
+// ranges from 0 to 1. Browsers only start playing audio after the user interacted with the page, so
+// the audio context is resumed by the first key press or click on document.
+func NewWebAudioBeeper(document js.Value, frequency int, volume float64) (*WebAudioBeeper, error) {
+	constructor := js.Global().Get("AudioContext")
+	if constructor.IsUndefined() {
+		constructor = js.Global().Get("webkitAudioContext")
+	}
+	if constructor.IsUndefined() {
+		return nil, fmt.Errorf("the browser does not support Web Audio")
+	}
+	context := constructor.New()
+
+	oscillator := context.Call("createOscillator")
+	oscillator.Set("type", "square")
+	oscillator.Get("frequency").Set("value", frequency)
+	gain := context.Call("createGain")
+	gain.Get("gain").Set("value", 0)
+	oscillator.Call("connect", gain)
+	gain.Call("connect", context.Get("destination"))
+	oscillator.Call("start")
+
+	b := &WebAudioBeeper{context: context, document: document, gain: gain, volume: volume}
+	b.resume = js.FuncOf(func(this js.Value, args []js.Value) any {
+		if context.Get("state").String() == "suspended" {
+			context.Call("resume")
+		}
+		return nil
+	})
+	document.Call("addEventListener", "keydown", b.resume)
+	document.Call("addEventListener", "pointerdown", b.resume)
+	return b, nil
+}
+
+func (b *WebAudioBeeper) Beep(on bool) {
+	if on == b.on {
+		return
+	}
+	b.on = on
+
+	// The gain is ramped over a few milliseconds, a sudden change clicks.
+	value := 0.0
+	if on {
+		// Scaled down, a square wave at full gain is much louder than most sounds of a page.
+		value = b.volume * 0.25
+	}
+	now := b.context.Get("currentTime").Float()
+	b.gain.Get("gain").Call("setTargetAtTime", value, now, 0.005)
+}
+
+// Cleanup closes the audio context.
+func (b *WebAudioBeeper) Cleanup() {
+	b.document.Call("removeEventListener", "keydown", b.resume)
+	b.document.Call("removeEventListener", "pointerdown", b.resume)
+	b.resume.Release()
+	b.context.Call("close")
+}
@@ -0,0 +1,136 @@
+// Package capture records the output of the emulator to image, video and audio files. It wraps the
+// renderer and the sound output, so that it works with the SDL front-end as well as without a window.
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/waldgaenger/go-acht/internal/audio"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// Recorder is a renderer and a beeper which keeps the frames and the sound it is given for screenshots
+// and recordings and passes them on to Renderer and Audio, if they are set. The frames are drawn in the
+// colors of the current palette, passed through Filters and enlarged by Scale.
+type Recorder struct {
+	Renderer renderer.Renderer // Optional renderer which shows the frames
+	Audio    audio.Beeper      // Optional sound output which plays the tone
+	Scale    int               // Size of a pixel of the filtered image in the files, 1 if zero
+	Filters  renderer.Pipeline // Filters applied to the frames, separate from the filters of Renderer
+	// Clock returns the number of emulated frames, e.g. Chip8.Frame. GIFs, video and audio are
+	// recorded for every emulated frame, so that frames which are drawn while the emulator is paused do not
+	// count. Without Clock, every Draw counts as a frame.
+	Clock func() uint64
+
+	last   *image.RGBA // The filtered image of the last frame
+	drawn  uint64      // Number of calls of Draw
+	gif    *gifRecording
+	video  *videoRecording
+	errors []error // Errors of the running video recording
+}
+
+// Draw records the display and passes it on to the renderer.
+func (r *Recorder) Draw(display [32][64]bool) {
+	r.drawn++
+	r.last = r.Filters.Apply(renderer.Frame(display))
+	if r.gif != nil {
+		r.gif.add(r.last, r.frame())
+	}
+	if r.video != nil {
+		if err := r.video.addFrames(r.last, r.frame()); err != nil {
+			r.errors = append(r.errors, err)
+		}
+	}
+	if r.Renderer != nil {
+		r.Renderer.Draw(display)
+	}
+}
+
+// Beep records the state of the tone and passes it on to the sound output.
+func (r *Recorder) Beep(on bool) {
+	if r.video != nil {
+		if err := r.video.addSound(on, r.frame()); err != nil {
+			r.errors = append(r.errors, err)
+		}
+	}
+	if r.Audio != nil {
+		r.Audio.Beep(on)
+	}
+}
+
+// frame returns the number of the current frame.
+func (r *Recorder) frame() uint64 {
+	if r.Clock != nil {
+		return r.Clock()
+	}
+	return r.drawn
+}
+
+// SetKeypad implements renderer.KeypadRenderer.
+func (r *Recorder) SetKeypad(pressed, polled [16]bool) {
+	renderer.ForwardKeypad(r.Renderer, pressed, polled)
+}
+
+// Screenshot writes the last frame as PNG image.
+func (r *Recorder) Screenshot(w io.Writer) error {
+	if r.last == nil {
+		return fmt.Errorf("no frame was drawn yet")
+	}
+	if err := png.Encode(w, renderer.Nearest{Factor: r.Scale}.Apply(r.last)); err != nil {
+		return fmt.Errorf("could not write screenshot: %w", err)
+	}
+	return nil
+}
+
+// StartGIF starts recording the following frames as an animated GIF, which is written to w by StopGIF.
+// A recording which is already running is discarded.
+func (r *Recorder) StartGIF(w io.Writer) {
+	r.gif = &gifRecording{w: w, scale: max(r.Scale, 1), start: r.frame()}
+}
+
+// StopGIF ends the recording and writes it. Every emulated frame lasts 1/60 second.
+func (r *Recorder) StopGIF() error {
+	if r.gif == nil {
+		return fmt.Errorf("no recording is running")
+	}
+	g := r.gif
+	r.gif = nil
+	return g.write(r.frame())
+}
+
+// StartVideo starts recording the following frames as YUV4MPEG2 video to video and, if wav is not nil,
+// the tone as WAV audio to wav. The tone is generated like by the sound output of the emulator with
+// the given settings. A recording which is already running is discarded.
+func (r *Recorder) StartVideo(video io.Writer, wav io.WriteSeeker, tone audio.Tone) error {
+	v, err := newVideoRecording(video, wav, r.Scale, tone, r.frame())
+	if err != nil {
+		return err
+	}
+	r.video, r.errors = v, nil
+	return nil
+}
+
+// StopVideo ends the video recording and completes the files. It returns the errors which occurred
+// while recording.
+func (r *Recorder) StopVideo() error {
+	if r.video == nil {
+		return fmt.Errorf("no video is recorded")
+	}
+	v := r.video
+	r.video = nil
+	return errors.Join(append(r.errors, v.close())...)
+}
+
+// RecordingVideo reports whether a video is being recorded.
+func (r *Recorder) RecordingVideo() bool {
+	return r.video != nil
+}
+
+// Recording reports whether a GIF is being recorded.
+func (r *Recorder) Recording() bool {
+	return r.gif != nil
+}
@@ -0,0 +1,124 @@
+package capture
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/png"
+	"slices"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+func TestScreenshot(t *testing.T) {
+	renderer.Use("black-white")
+	r := &Recorder{Scale: 3}
+
+	var buf bytes.Buffer
+	if err := r.Screenshot(&buf); err == nil {
+		t.Errorf("Expected an error without a frame")
+	}
+
+	var display [32][64]bool
+	display[1][2] = true
+	r.Draw(display)
+
+	if err := r.Screenshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if size := img.Bounds().Size(); size != image.Pt(192, 96) {
+		t.Errorf("Expected a screenshot of 192x96 but got %v", size)
+	}
+	if c := img.At(8, 5); c != renderer.Profile.Foreground {
+		t.Errorf("Expected the set pixel in the foreground color but got %v", c)
+	}
+	if c := img.At(9, 5); c != renderer.Profile.Background {
+		t.Errorf("Expected the pixel next to it in the background color but got %v", c)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	renderer.Use("black-white")
+	var buf bytes.Buffer
+	r := &Recorder{Scale: 2}
+	r.StartGIF(&buf)
+
+	// 6 frames, of which the second and the third are the same and the last ones differ in one pixel.
+	var display [32][64]bool
+	for frame := range 6 {
+		if frame == 1 || frame >= 3 {
+			display[10][frame] = !display[10][frame]
+		}
+		r.Draw(display)
+	}
+
+	if !r.Recording() {
+		t.Fatalf("Expected the recording to run")
+	}
+	if err := r.StopGIF(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Recording() {
+		t.Errorf("Expected the recording to be stopped")
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 6 frames at 60 Hz last 10/100 seconds. The fifth frame would be shown for 1/100 second, so it
+	// is replaced by the last one.
+	if len(anim.Image) != 4 {
+		t.Fatalf("Expected 4 frames but got %d", len(anim.Image))
+	}
+	if size := anim.Image[0].Bounds().Size(); size != image.Pt(128, 64) {
+		t.Errorf("Expected the first frame to cover the display but got %v", size)
+	}
+	if bounds := anim.Image[3].Bounds(); bounds != image.Rect(8, 20, 12, 22) {
+		t.Errorf("Expected the last frame to cover the changed pixels but got %v", bounds)
+	}
+	if want := []int{2, 3, 2, 3}; !slices.Equal(anim.Delay, want) {
+		t.Errorf("Expected the delays %v but got %v", want, anim.Delay)
+	}
+}
+
+func TestGIFClock(t *testing.T) {
+	renderer.Use("black-white")
+	var buf bytes.Buffer
+	var frame uint64 = 6
+	r := &Recorder{Clock: func() uint64 { return frame }}
+	r.StartGIF(&buf)
+
+	// The first image is drawn again while the emulator is paused and the last one is shown until
+	// the recording is stopped.
+	var display [32][64]bool
+	frame = 9
+	r.Draw(display)
+	display[0][0] = true
+	r.Draw(display)
+	display[0][1] = true
+	frame = 12
+	r.Draw(display)
+	frame = 15
+	if err := r.StopGIF(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{5, 10}; !slices.Equal(anim.Delay, want) {
+		t.Errorf("Expected the delays %v but got %v", want, anim.Delay)
+	}
+	if c := anim.Image[0].At(0, 0); c != renderer.Profile.Foreground {
+		t.Errorf("Expected the first frame to show the image drawn last while paused but got %v", c)
+	}
+}
@@ -0,0 +1,159 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"io"
+)
+
+// gifRecording collects the frames of an animated GIF. To keep long recordings small, a frame only
+// holds the area which changed since the previous frame, and frames without changes extend the
+// previous one.
+//
+// The timing follows the emulated frames: an image drawn at frame n is shown from the frame the
+// previous image was drawn at until n. GIF delays are given in 1/100 seconds and most viewers show
+// delays below 2/100 seconds much slower, so an image which would be shown shorter is replaced by
+// the next one.
+type gifRecording struct {
+	w       io.Writer
+	scale   int
+	frames  []*image.Paletted
+	starts  []int       // Time in 1/100 seconds at which each GIF frame starts
+	prev    *image.RGBA // Unscaled image of the last GIF frame
+	pending *image.RGBA // Unscaled image which is shown next, nil until the first frame is drawn
+	begin   int         // Time in 1/100 seconds at which the pending image is shown
+	start   uint64      // Number of the emulated frame the recording started at
+	end     uint64      // Number of the emulated frame the pending image was drawn at
+}
+
+// add records the image drawn at the given emulated frame.
+func (g *gifRecording) add(img *image.RGBA, frame uint64) {
+	switch {
+	case g.pending == nil:
+		g.begin = centiseconds(g.start)
+	case frame <= g.end:
+		// Drawn again in the same frame, e.g. while the emulator is paused.
+	case equal(g.pending, img):
+		g.end = frame
+		return
+	case centiseconds(g.end)-g.begin < 2:
+		// The pending image would be shown too short, so the new one replaces it.
+	default:
+		g.flush()
+		g.begin = centiseconds(g.end)
+	}
+	g.pending = clone(g.pending, img)
+	g.end = max(g.end, frame)
+}
+
+// flush adds the pending image as GIF frame. It only extends the last frame if it did not change.
+func (g *gifRecording) flush() {
+	img := g.pending
+	changed := img.Rect
+	if g.prev != nil && g.prev.Rect == img.Rect {
+		changed = difference(g.prev, img)
+		if changed.Empty() {
+			return
+		}
+	}
+	g.prev = clone(g.prev, img)
+
+	g.frames = append(g.frames, paletted(img, changed, g.scale))
+	g.starts = append(g.starts, g.begin)
+}
+
+// write encodes the recording, which ends at the given emulated frame. The delays are derived from
+// the start times rounded to 1/100 seconds, so the frames stay in sync with 60 Hz over the whole
+// recording.
+func (g *gifRecording) write(frame uint64) error {
+	if g.pending == nil {
+		return fmt.Errorf("no frame was recorded")
+	}
+	g.flush()
+
+	anim := &gif.GIF{Image: g.frames}
+	for i, start := range g.starts {
+		end := max(centiseconds(max(frame, g.end)), start+2)
+		if i+1 < len(g.starts) {
+			end = g.starts[i+1]
+		}
+		anim.Delay = append(anim.Delay, end-start)
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+	anim.Config = image.Config{Width: g.frames[0].Rect.Dx(), Height: g.frames[0].Rect.Dy()}
+
+	if err := gif.EncodeAll(g.w, anim); err != nil {
+		return fmt.Errorf("could not write GIF: %w", err)
+	}
+	return nil
+}
+
+// centiseconds returns the time at which the 60 Hz frame starts, rounded to 1/100 seconds.
+func centiseconds(frame uint64) int {
+	return int((frame*100 + 30) / 60)
+}
+
+// equal reports whether a and b hold the same image.
+func equal(a, b *image.RGBA) bool {
+	return a.Rect == b.Rect && bytes.Equal(a.Pix, b.Pix)
+}
+
+// clone copies src into dst, which is reallocated if it is nil or has another size.
+func clone(dst, src *image.RGBA) *image.RGBA {
+	if dst == nil || dst.Rect != src.Rect {
+		dst = image.NewRGBA(src.Rect)
+	}
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// difference returns the smallest rectangle which contains all pixels that differ between a and b.
+func difference(a, b *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+		for x := b.Rect.Min.X; x < b.Rect.Max.X; x++ {
+			i := b.PixOffset(x, y)
+			if !bytes.Equal(a.Pix[i:i+4], b.Pix[i:i+4]) {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// paletted returns the area of img enlarged by scale. Its palette consists of the colors of the area,
+// if there are at most 256, otherwise the colors are reduced to a standard palette.
+func paletted(img *image.RGBA, area image.Rectangle, scale int) *image.Paletted {
+	var colors color.Palette
+	seen := map[color.RGBA]bool{}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if c := img.RGBAAt(x, y); !seen[c] {
+				seen[c] = true
+				colors = append(colors, c)
+			}
+		}
+	}
+	if len(colors) > 256 {
+		colors = palette.Plan9
+	}
+
+	scaled := image.Rect(area.Min.X*scale, area.Min.Y*scale, area.Max.X*scale, area.Max.Y*scale)
+	dst := image.NewPaletted(scaled, colors)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			index := uint8(colors.Index(img.RGBAAt(x, y)))
+			for i := range scale {
+				row := dst.Pix[dst.PixOffset(x*scale, y*scale+i):]
+				for j := range scale {
+					row[j] = index
+				}
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,164 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/waldgaenger/go-acht/internal/audio"
+)
+
+// videoRecording writes every emulated frame as uncompressed YUV4MPEG2 video and the tone of the frame
+// as 16 bit PCM WAV audio. Each frame lasts exactly 1/60 second in both files, so they stay in sync.
+type videoRecording struct {
+	video   *bufio.Writer
+	wav     io.WriteSeeker // Optional
+	scale   int
+	size    image.Point // Size of the unscaled frames, set by the first frame
+	tone    audio.Tone
+	samples []int16
+	pcm     []byte
+	written uint32 // Bytes of audio data written
+	frame   uint64 // Emulated frame up to which the video is written
+	sound   uint64 // Emulated frame up to which the audio is written
+}
+
+// wavHeaderSize is the size of the RIFF header of a WAV file with a single fmt and data chunk.
+const wavHeaderSize = 44
+
+func newVideoRecording(video io.Writer, wav io.WriteSeeker, scale int, tone audio.Tone, frame uint64) (*videoRecording, error) {
+	v := &videoRecording{
+		video:   bufio.NewWriter(video),
+		wav:     wav,
+		scale:   max(scale, 1),
+		tone:    tone,
+		samples: make([]int16, audio.SamplesPerFrame),
+		pcm:     make([]byte, 2*audio.SamplesPerFrame),
+		frame:   frame,
+		sound:   frame,
+	}
+	if wav != nil {
+		if err := v.writeWAVHeader(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// addFrames writes the image as the video of the frames up to frame.
+func (v *videoRecording) addFrames(img *image.RGBA, frame uint64) error {
+	if frame <= v.frame {
+		return nil
+	}
+	count := frame - v.frame
+	v.frame = frame
+
+	if v.size == (image.Point{}) {
+		v.size = img.Rect.Size()
+		// The frames are stored with full range 4:4:4 chroma, so that no colors are lost by subsampling.
+		_, err := fmt.Fprintf(v.video, "YUV4MPEG2 W%d H%d F60:1 Ip A1:1 C444 XCOLORRANGE=FULL\n", v.size.X*v.scale, v.size.Y*v.scale)
+		if err != nil {
+			return fmt.Errorf("could not write video: %w", err)
+		}
+	}
+	if img.Rect.Size() != v.size {
+		return fmt.Errorf("the size of the frames changed from %v to %v", v.size, img.Rect.Size())
+	}
+
+	w, h := v.size.X, v.size.Y
+	planes := [3][]byte{make([]byte, w*h), make([]byte, w*h), make([]byte, w*h)}
+	for y := range h {
+		for x := range w {
+			c := img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			planes[0][y*w+x], planes[1][y*w+x], planes[2][y*w+x] = yy, cb, cr
+		}
+	}
+
+	row := make([]byte, w*v.scale)
+	for range count {
+		if _, err := v.video.WriteString("FRAME\n"); err != nil {
+			return fmt.Errorf("could not write video: %w", err)
+		}
+		for _, plane := range planes {
+			for y := range h * v.scale {
+				src := plane[y/v.scale*w:]
+				for x := range row {
+					row[x] = src[x/v.scale]
+				}
+				if _, err := v.video.Write(row); err != nil {
+					return fmt.Errorf("could not write video: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addSound writes the tone as the audio of the frames up to frame.
+func (v *videoRecording) addSound(on bool, frame uint64) error {
+	if frame <= v.sound {
+		return nil
+	}
+	count := frame - v.sound
+	v.sound = frame
+	if v.wav == nil {
+		return nil
+	}
+
+	for range count {
+		v.tone.Frame(on, v.samples)
+		for i, sample := range v.samples {
+			binary.LittleEndian.PutUint16(v.pcm[2*i:], uint16(sample))
+		}
+		if _, err := v.wav.Write(v.pcm); err != nil {
+			return fmt.Errorf("could not write audio: %w", err)
+		}
+		v.written += uint32(len(v.pcm))
+	}
+	return nil
+}
+
+// close flushes the video and completes the header of the WAV file with the length of the audio.
+func (v *videoRecording) close() error {
+	if err := v.video.Flush(); err != nil {
+		return fmt.Errorf("could not write video: %w", err)
+	}
+	if v.wav == nil {
+		return nil
+	}
+
+	if _, err := v.wav.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("could not write audio: %w", err)
+	}
+	if err := v.writeWAVHeader(); err != nil {
+		return err
+	}
+	_, err := v.wav.Seek(0, io.SeekEnd)
+	return err
+}
+
+// writeWAVHeader writes the header of a mono 16 bit PCM WAV file with the audio written so far.
+func (v *videoRecording) writeWAVHeader() error {
+	header := make([]byte, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, wavHeaderSize-8+v.written)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)                 // Size of the fmt chunk
+	header = binary.LittleEndian.AppendUint16(header, 1)                  // PCM
+	header = binary.LittleEndian.AppendUint16(header, 1)                  // Mono
+	header = binary.LittleEndian.AppendUint32(header, audio.SampleRate)   // Samples per second
+	header = binary.LittleEndian.AppendUint32(header, 2*audio.SampleRate) // Bytes per second
+	header = binary.LittleEndian.AppendUint16(header, 2)                  // Bytes per sample
+	header = binary.LittleEndian.AppendUint16(header, 16)                 // Bits per sample
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, v.written)
+
+	if _, err := v.wav.Write(header); err != nil {
+		return fmt.Errorf("could not write audio: %w", err)
+	}
+	return nil
+}
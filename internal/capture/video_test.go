@@ -0,0 +1,68 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/audio"
+)
+
+func TestVideo(t *testing.T) {
+	wav, err := os.Create(filepath.Join(t.TempDir(), "test.wav"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer wav.Close()
+
+	var frame uint64
+	var video bytes.Buffer
+	r := &Recorder{Scale: 2, Clock: func() uint64 { return frame }}
+	if err := r.StartVideo(&video, wav, audio.Tone{Frequency: 441, Volume: 0.5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 3 emulated frames, the second one is drawn twice like while the emulator is paused, and the
+	// third one follows two frames later, e.g. because the front-end could not keep up.
+	var display [32][64]bool
+	for _, f := range []uint64{1, 2, 2, 4} {
+		frame = f
+		r.Draw(display)
+		r.Beep(f >= 2)
+	}
+
+	if err := r.StopVideo(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header := "YUV4MPEG2 W128 H64 F60:1 Ip A1:1 C444 XCOLORRANGE=FULL\n"
+	if !bytes.HasPrefix(video.Bytes(), []byte(header)) {
+		t.Errorf("Expected the header %q", header)
+	}
+	if frames := bytes.Count(video.Bytes(), []byte("FRAME\n")); frames != 4 {
+		t.Errorf("Expected 4 frames but got %d", frames)
+	}
+	if size := video.Len(); size != len(header)+4*(len("FRAME\n")+3*128*64) {
+		t.Errorf("Expected 4 frames of 128x64 pixels with 3 planes but got %d bytes", size)
+	}
+
+	data, err := os.ReadFile(wav.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	samples := 4 * audio.SamplesPerFrame
+	if len(data) != wavHeaderSize+2*samples {
+		t.Fatalf("Expected %d samples but got %d bytes", samples, len(data))
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != uint32(2*samples) {
+		t.Errorf("Expected the data chunk to hold %d bytes but got %d", 2*samples, size)
+	}
+	if first := binary.LittleEndian.Uint16(data[wavHeaderSize:]); first != 0 {
+		t.Errorf("Expected the first frame to be silent but got %d", first)
+	}
+	if second := int16(binary.LittleEndian.Uint16(data[wavHeaderSize+2*audio.SamplesPerFrame:])); second != 16383 {
+		t.Errorf("Expected the tone in the second frame but got %d", second)
+	}
+}
@@ -0,0 +1,226 @@
+// Package cartridge reads and writes Octo cartridges.
+//
+// Octo distributes programs as GIF images, which show a label and carry the program together with its
+// options steganographically: the two least significant bits of every palette index hold two bits of the
+// payload, four pixels form one byte. The payload starts with its length as a 32 bit big endian number
+// followed by a JSON object with the program source and the options.
+//
+// The program is stored as Octo source code, which is assembled when the ROM is loaded. SetROM writes
+// the ROM as byte literals.
+package cartridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// Size of the cartridge image.
+const (
+	Width  = 160
+	Height = 128
+)
+
+// Options holds the settings Octo stores along with a program. Keys is no Octo option, it holds the
+// CHIP-8 keys of the named buttons in the format of the ROM database and is ignored by Octo.
+type Options struct {
+	Tickrate        int              `json:"tickrate"`
+	FillColor       string           `json:"fillColor"`
+	FillColor2      string           `json:"fillColor2"`
+	BlendColor      string           `json:"blendColor"`
+	BackgroundColor string           `json:"backgroundColor"`
+	BuzzColor       string           `json:"buzzColor"`
+	QuietColor      string           `json:"quietColor"`
+	ShiftQuirks     bool             `json:"shiftQuirks"`
+	LoadStoreQuirks bool             `json:"loadStoreQuirks"`
+	VFOrderQuirks   bool             `json:"vfOrderQuirks"`
+	ClipQuirks      bool             `json:"clipQuirks"`
+	JumpQuirks      bool             `json:"jumpQuirks"`
+	VBlankQuirks    bool             `json:"vBlankQuirks"`
+	LogicQuirks     bool             `json:"logicQuirks"`
+	ScreenRotation  int              `json:"screenRotation"`
+	MaxSize         int              `json:"maxSize"`
+	TouchInputMode  string           `json:"touchInputMode"`
+	FontStyle       string           `json:"fontStyle"`
+	Keys            map[string]uint8 `json:"keys,omitempty"`
+}
+
+// DefaultOptions returns the options Octo uses for new programs.
+func DefaultOptions() Options {
+	return Options{
+		Tickrate:        20,
+		FillColor:       "#FFCC00",
+		FillColor2:      "#FF6600",
+		BlendColor:      "#662200",
+		BackgroundColor: "#996600",
+		BuzzColor:       "#FFAA00",
+		QuietColor:      "#000000",
+		MaxSize:         3584,
+		TouchInputMode:  "none",
+		FontStyle:       "octo",
+	}
+}
+
+// Quirks converts the quirk options into the quirks of the emulator.
+func (o Options) Quirks() chip8.Quirks {
+	q := chip8.Quirks{
+		VFReset: o.LogicQuirks,
+		ShiftVY: !o.ShiftQuirks,
+		Memory:  chip8.MemoryIncrement,
+		JumpVX:  o.JumpQuirks,
+		Clip:    o.ClipQuirks,
+		VBlank:  o.VBlankQuirks,
+	}
+	if o.LoadStoreQuirks {
+		q.Memory = chip8.MemoryUnchanged
+	}
+	return q
+}
+
+// SetQuirks sets the quirk options from the quirks of the emulator. Octo can not express the legacy
+// behaviour of FX55 and FX65 and incrementing I by X, both are stored as incrementing I by X + 1.
+func (o *Options) SetQuirks(q chip8.Quirks) {
+	o.LogicQuirks = q.VFReset
+	o.ShiftQuirks = !q.ShiftVY
+	o.LoadStoreQuirks = q.Memory == chip8.MemoryUnchanged
+	o.JumpQuirks = q.JumpVX
+	o.ClipQuirks = q.Clip
+	o.VBlankQuirks = q.VBlank
+}
+
+// Colors returns the colors of the pixel values: background, fill, fill 2 and blend.
+func (o Options) Colors() []string {
+	return []string{o.BackgroundColor, o.FillColor, o.FillColor2, o.BlendColor}
+}
+
+// Palette returns the palette of the options.
+func (o Options) Palette() (renderer.Palette, error) {
+	return renderer.ParsePalette(o.Colors())
+}
+
+// Cartridge is the content of an Octo cartridge.
+type Cartridge struct {
+	Program string  `json:"program"` // Octo source code
+	Options Options `json:"options"`
+}
+
+// IsCartridge reports whether data looks like a cartridge, i.e. is a GIF image.
+func IsCartridge(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+}
+
+// Decode reads a cartridge from r.
+func Decode(r io.Reader) (*Cartridge, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode cartridge: %w", err)
+	}
+
+	var data []byte
+	var b, n uint8
+	for _, frame := range g.Image {
+		for _, index := range frame.Pix {
+			b = b<<2 | index&3
+			n++
+			if n == 4 {
+				data = append(data, b)
+				b, n = 0, 0
+			}
+		}
+	}
+
+	if len(data) < 4 {
+		return nil, errors.New("cartridge does not contain a payload")
+	}
+	size := binary.BigEndian.Uint32(data)
+	if uint64(size) > uint64(len(data)-4) {
+		return nil, fmt.Errorf("cartridge payload is truncated (%d of %d bytes)", len(data)-4, size)
+	}
+
+	c := &Cartridge{Options: DefaultOptions()}
+	if err := json.Unmarshal(data[4:4+size], c); err != nil {
+		return nil, fmt.Errorf("could not parse cartridge payload: %w", err)
+	}
+	return c, nil
+}
+
+// Encode writes the cartridge to w. The label is drawn twice its size in the palette of the options.
+func Encode(w io.Writer, c *Cartridge, label [32][64]bool) error {
+	palette, err := c.Options.Palette()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	data = append(data, payload...)
+
+	// Every base colour is repeated for all four payload values, so that the payload does not change
+	// the appearance of the label.
+	pal := make(color.Palette, 0, 16)
+	for _, c := range []color.RGBA{palette.Background, palette.Foreground, palette.Foreground2, palette.Blend} {
+		for range 4 {
+			pal = append(pal, c)
+		}
+	}
+
+	base := make([]uint8, Width*Height)
+	for y := range 64 {
+		for x := range 128 {
+			if label[y/2][x/2] {
+				base[(y+32)*Width+x+16] = 1
+			}
+		}
+	}
+
+	g := &gif.GIF{}
+	perFrame := Width * Height / 4
+	for start := 0; start < len(data); start += perFrame {
+		frame := image.NewPaletted(image.Rect(0, 0, Width, Height), pal)
+		for i := range frame.Pix {
+			var bits uint8
+			if offset := start + i/4; offset < len(data) {
+				bits = data[offset] >> (6 - 2*(i%4)) & 3
+			}
+			frame.Pix[i] = base[i]<<2 | bits
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 0)
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+// ROM assembles the program. Only the CHIP-8 subset of Octo is supported, see assemble.
+func (c *Cartridge) ROM() ([]byte, error) {
+	return assemble(c.Program)
+}
+
+// SetROM stores the ROM as Octo source code consisting of byte literals.
+func (c *Cartridge) SetROM(rom []byte) {
+	var sb strings.Builder
+	sb.WriteString(": main\n")
+	for i, b := range rom {
+		if i%16 != 0 {
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "0x%02X", b)
+		if i%16 == 15 || i == len(rom)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	c.Program = sb.String()
+}
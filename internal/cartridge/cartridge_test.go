@@ -0,0 +1,63 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rom := make([]byte, 3000)
+	for i := range rom {
+		rom[i] = uint8(i * 7)
+	}
+
+	cart := &Cartridge{Options: DefaultOptions()}
+	cart.SetROM(rom)
+	cart.Options.Tickrate = 15
+	cart.Options.Keys = map[string]uint8{"up": 5}
+	cart.Options.SetQuirks(chip8.QuirkProfiles["schip"])
+
+	var label [32][64]bool
+	label[10][20] = true
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, cart, label); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsCartridge(buf.Bytes()) {
+		t.Fatalf("Expected the encoded cartridge to be recognized")
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decodedRom, err := decoded.ROM()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(decodedRom, rom) {
+		t.Errorf("Expected the ROM to survive the round trip")
+	}
+	if decoded.Options.Tickrate != 15 || decoded.Options.Keys["up"] != 5 {
+		t.Errorf("Expected the options to survive the round trip but got %+v", decoded.Options)
+	}
+	if decoded.Options.Quirks() != chip8.QuirkProfiles["schip"] {
+		t.Errorf("Expected the quirks %+v but got %+v", chip8.QuirkProfiles["schip"], decoded.Options.Quirks())
+	}
+}
+
+func TestROM(t *testing.T) {
+	c := &Cartridge{Program: ": main\n0x12 0x00 # jump\n255 0b101 -1\n"}
+	rom, err := c.ROM()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []byte{0x12, 0x00, 0xFF, 0x05, 0xFF}
+	if !bytes.Equal(rom, expected) {
+		t.Errorf("Expected %X but got %X", expected, rom)
+	}
+}
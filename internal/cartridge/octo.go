@@ -0,0 +1,590 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// start is the address the program is loaded to, maxSize the number of bytes available from there on.
+const (
+	start   = 0x200
+	maxSize = 0x1000 - start
+)
+
+// unsupported holds the Octo statements for the SUPER-CHIP and XO-CHIP and the statements of the
+// assembler which are beyond the CHIP-8 subset understood by assemble.
+var unsupported = map[string]bool{
+	"hires": true, "lores": true, "exit": true, "scroll-down": true, "scroll-up": true, "scroll-left": true,
+	"scroll-right": true, "plane": true, "audio": true, "pitch": true, "saveflags": true, "loadflags": true,
+	"bighex": true, "long": true,
+}
+
+// keywords holds the names which can not be used for labels, constants and aliases.
+var keywords = map[string]bool{
+	"clear": true, "return": true, ";": true, "jump": true, "jump0": true, "sprite": true, "bcd": true,
+	"save": true, "load": true, "delay": true, "buzzer": true, "i": true, "hex": true, "random": true,
+	"key": true, "-key": true, "if": true, "then": true, "begin": true, "else": true, "end": true,
+	"loop": true, "while": true, "again": true,
+}
+
+// arithmetic holds the operators of the instructions 8XYN by N.
+var arithmetic = map[string]uint16{":=": 0x0, "|=": 0x1, "&=": 0x2, "^=": 0x3, "+=": 0x4, "-=": 0x5, ">>=": 0x6, "=-": 0x7, "<<=": 0xE}
+
+// token is a word of the source code and the line it appears in.
+type token struct {
+	text string
+	line int
+}
+
+// fixup is an instruction at offset of the ROM whose address refers to a label which was not yet
+// defined when the instruction was assembled.
+type fixup struct {
+	offset int
+	label  token
+}
+
+// block is an open if or loop. For an if, jump is the offset of the jump to the else branch or the
+// end, for a loop start is its address and breaks are the offsets of the jumps out of the loop.
+type block struct {
+	loop   bool
+	jump   int
+	start  int
+	breaks []int
+	line   int
+}
+
+// assembler translates Octo source code into a ROM.
+type assembler struct {
+	tokens  []token
+	pos     int
+	rom     []byte
+	labels  map[string]int
+	consts  map[string]int
+	aliases map[string]uint16
+	fixups  []fixup
+	blocks  []block
+	entry   bool // whether the start of the ROM is settled, by the label main or the jump to it
+}
+
+// assemble translates the CHIP-8 subset of Octo: the mnemonics of the CHIP-8 instructions including the
+// comparison operators, byte literals, labels, :alias, :const, :byte, :call and the control structures
+// if/then, if/begin/else/end and loop/while/again. Macros, :calc and the statements of the SUPER-CHIP
+// and XO-CHIP are not supported.
+//
+// Like Octo, a jump to the label main is placed at the start of the ROM unless main is the first label
+// and precedes all code.
+func assemble(source string) ([]byte, error) {
+	a := &assembler{
+		labels:  map[string]int{},
+		consts:  map[string]int{},
+		aliases: map[string]uint16{},
+	}
+	for n, line := range strings.Split(source, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.Fields(line) {
+			a.tokens = append(a.tokens, token{text: field, line: n + 1})
+		}
+	}
+
+	for a.pos < len(a.tokens) {
+		if err := a.statement(a.next()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+		if b.loop {
+			return nil, fmt.Errorf("line %d: loop is not closed with again", b.line)
+		}
+		return nil, fmt.Errorf("line %d: if is not closed with end", b.line)
+	}
+	if _, ok := a.labels["main"]; !ok {
+		return nil, errors.New("the program has no label main")
+	}
+	for _, f := range a.fixups {
+		address, ok := a.labels[f.label.text]
+		if !ok {
+			return nil, fmt.Errorf("line %d: undefined label %q", f.label.line, f.label.text)
+		}
+		a.patch(f.offset, address)
+	}
+	if len(a.rom) > maxSize {
+		return nil, fmt.Errorf("the program is %d bytes long, only %d bytes fit into the memory", len(a.rom), maxSize)
+	}
+	return a.rom, nil
+}
+
+// statement assembles the statement starting with t.
+func (a *assembler) statement(t token) error {
+	switch t.text {
+	case ":":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		if _, ok := a.labels[name.text]; ok {
+			return fmt.Errorf("line %d: the label %q is already defined", name.line, name.text)
+		}
+		if name.text == "main" {
+			a.entry = true
+		}
+		a.labels[name.text] = a.here()
+	case ":alias":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.aliases[name.text] = x
+	case ":const":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		v, err := a.value(-1<<15, 1<<16-1)
+		if err != nil {
+			return err
+		}
+		a.consts[name.text] = v
+	case ":byte":
+		v, err := a.value(-128, 255)
+		if err != nil {
+			return err
+		}
+		a.emit(uint8(v))
+	case ":call":
+		return a.address(0x2000)
+	case "clear":
+		a.inst(0x00E0)
+	case "return", ";":
+		a.inst(0x00EE)
+	case "jump":
+		return a.address(0x1000)
+	case "jump0":
+		return a.address(0xB000)
+	case "sprite":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+		n, err := a.value(0, 15)
+		if err != nil {
+			return err
+		}
+		a.inst(0xD000 | x<<8 | y<<4 | uint16(n))
+	case "bcd", "save", "load":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.inst(map[string]uint16{"bcd": 0xF033, "save": 0xF055, "load": 0xF065}[t.text] | x<<8)
+	case "delay", "buzzer":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.inst(map[string]uint16{"delay": 0xF015, "buzzer": 0xF018}[t.text] | x<<8)
+	case "i":
+		return a.index()
+	case "if":
+		return a.conditional(t)
+	case "else":
+		if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].loop {
+			return fmt.Errorf("line %d: else without if", t.line)
+		}
+		b := &a.blocks[len(a.blocks)-1]
+		jump := a.jump()
+		a.patch(b.jump, a.here())
+		b.jump = jump
+	case "end":
+		if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].loop {
+			return fmt.Errorf("line %d: end without if", t.line)
+		}
+		a.patch(a.blocks[len(a.blocks)-1].jump, a.here())
+		a.blocks = a.blocks[:len(a.blocks)-1]
+	case "loop":
+		a.blocks = append(a.blocks, block{loop: true, start: a.here(), line: t.line})
+	case "while":
+		i := len(a.blocks) - 1
+		for i >= 0 && !a.blocks[i].loop {
+			i--
+		}
+		if i < 0 {
+			return fmt.Errorf("line %d: while outside of a loop", t.line)
+		}
+		skip, err := a.condition()
+		if err != nil {
+			return err
+		}
+		a.skip(skip, true)
+		a.blocks[i].breaks = append(a.blocks[i].breaks, a.jump())
+	case "again":
+		if len(a.blocks) == 0 || !a.blocks[len(a.blocks)-1].loop {
+			return fmt.Errorf("line %d: again without loop", t.line)
+		}
+		b := a.blocks[len(a.blocks)-1]
+		a.blocks = a.blocks[:len(a.blocks)-1]
+		a.inst(0x1000 | uint16(b.start))
+		for _, offset := range b.breaks {
+			a.patch(offset, a.here())
+		}
+	default:
+		if x, ok := a.registerOf(t.text); ok {
+			return a.assignment(x)
+		}
+		if v, err := strconv.ParseInt(t.text, 0, 32); err == nil {
+			if v < -128 || v > 255 {
+				return fmt.Errorf("line %d: %s is not a byte", t.line, t.text)
+			}
+			a.emit(uint8(v))
+			return nil
+		}
+		if strings.HasPrefix(t.text, ":") || unsupported[t.text] || keywords[t.text] {
+			return fmt.Errorf("line %d: %s is not supported, only the CHIP-8 subset of Octo can be assembled", t.line, t.text)
+		}
+		// A name on its own calls the subroutine of that label.
+		a.pos--
+		return a.address(0x2000)
+	}
+	return nil
+}
+
+// assignment assembles the statement of the register x, e.g. "v1 += v2".
+func (a *assembler) assignment(x uint16) error {
+	op := a.next()
+	if op.text == "" {
+		return fmt.Errorf("line %d: missing operator after register", a.tokens[a.pos-1].line)
+	}
+
+	n, ok := arithmetic[op.text]
+	if !ok {
+		return fmt.Errorf("line %d: unknown operator %q", op.line, op.text)
+	}
+
+	if a.pos < len(a.tokens) {
+		if y, ok := a.registerOf(a.tokens[a.pos].text); ok {
+			a.pos++
+			a.inst(0x8000 | x<<8 | y<<4 | n)
+			return nil
+		}
+	}
+
+	switch op.text {
+	case ":=":
+		switch a.peek() {
+		case "random":
+			a.pos++
+			v, err := a.value(0, 255)
+			if err != nil {
+				return err
+			}
+			a.inst(0xC000 | x<<8 | uint16(v))
+			return nil
+		case "delay":
+			a.pos++
+			a.inst(0xF007 | x<<8)
+			return nil
+		case "key":
+			a.pos++
+			a.inst(0xF00A | x<<8)
+			return nil
+		}
+		v, err := a.value(-128, 255)
+		if err != nil {
+			return err
+		}
+		a.inst(0x6000 | x<<8 | uint16(uint8(v)))
+	case "+=", "-=":
+		v, err := a.value(-128, 255)
+		if err != nil {
+			return err
+		}
+		if op.text == "-=" {
+			v = -v
+		}
+		a.inst(0x7000 | x<<8 | uint16(uint8(v)))
+	default:
+		return fmt.Errorf("line %d: %s needs a register", op.line, op.text)
+	}
+	return nil
+}
+
+// index assembles the statements of the register I.
+func (a *assembler) index() error {
+	op := a.next()
+	switch op.text {
+	case ":=":
+		if a.peek() == "hex" {
+			a.pos++
+			x, err := a.register()
+			if err != nil {
+				return err
+			}
+			a.inst(0xF029 | x<<8)
+			return nil
+		}
+		return a.address(0xA000)
+	case "+=":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.inst(0xF01E | x<<8)
+		return nil
+	}
+	return fmt.Errorf("line %d: unknown operator %q for i", op.line, op.text)
+}
+
+// conditional assembles an if, which is either followed by then and a single statement or by begin
+// and a block.
+func (a *assembler) conditional(t token) error {
+	skip, err := a.condition()
+	if err != nil {
+		return err
+	}
+	switch next := a.next(); next.text {
+	case "then":
+		a.skip(skip, false)
+		if a.pos == len(a.tokens) {
+			return fmt.Errorf("line %d: missing statement after then", next.line)
+		}
+	case "begin":
+		a.skip(skip, true)
+		a.blocks = append(a.blocks, block{jump: a.jump(), line: t.line})
+	default:
+		return fmt.Errorf("line %d: expected then or begin after the condition of if", t.line)
+	}
+	return nil
+}
+
+// condition returns the instructions of a condition. The last one skips the following instruction if
+// the condition does not hold, the comparisons of magnitude compute the flag in VF first.
+func (a *assembler) condition() ([]uint16, error) {
+	x, err := a.register()
+	if err != nil {
+		return nil, err
+	}
+	op := a.next()
+	switch op.text {
+	case "key":
+		return []uint16{0xE0A1 | x<<8}, nil
+	case "-key":
+		return []uint16{0xE09E | x<<8}, nil
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return nil, fmt.Errorf("line %d: unknown comparison %q", op.line, op.text)
+	}
+
+	y, register := a.registerOf(a.peek())
+	var v int
+	if register {
+		a.pos++
+	} else if v, err = a.value(-128, 255); err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "==":
+		if register {
+			return []uint16{0x9000 | x<<8 | y<<4}, nil
+		}
+		return []uint16{0x4000 | x<<8 | uint16(uint8(v))}, nil
+	case "!=":
+		if register {
+			return []uint16{0x5000 | x<<8 | y<<4}, nil
+		}
+		return []uint16{0x3000 | x<<8 | uint16(uint8(v))}, nil
+	}
+
+	load := 0x6F00 | uint16(uint8(v))
+	if register {
+		load = 0x8F00 | y<<4
+	}
+	// VF holds the right operand, subtracting leaves the flag VX >= VF (=-) or VF >= VX (-=) in VF.
+	subtract := map[string]uint16{"<": 0x8F07, ">": 0x8F05, "<=": 0x8F05, ">=": 0x8F07}[op.text] | x<<4
+	skip := map[string]uint16{"<": 0x3F01, ">": 0x3F01, "<=": 0x3F00, ">=": 0x3F00}[op.text]
+	return []uint16{load, subtract, skip}, nil
+}
+
+// skip emits the instructions of a condition. If inverted, the last one skips the following instruction
+// if the condition holds instead.
+func (a *assembler) skip(instructions []uint16, inverted bool) {
+	for i, inst := range instructions {
+		if inverted && i == len(instructions)-1 {
+			switch inst & 0xF000 {
+			case 0x3000, 0x4000:
+				inst ^= 0x7000
+			case 0x5000, 0x9000:
+				inst ^= 0xC000
+			case 0xE000:
+				inst ^= 0x009E ^ 0x00A1
+			}
+		}
+		a.inst(inst)
+	}
+}
+
+// address assembles the instruction opcode with an address, which may refer to a label defined later.
+func (a *assembler) address(opcode uint16) error {
+	t := a.next()
+	if t.text == "" {
+		return fmt.Errorf("line %d: missing address", a.tokens[a.pos-1].line)
+	}
+	if _, ok := a.labels[t.text]; !ok {
+		if _, ok := a.consts[t.text]; !ok {
+			if _, err := strconv.ParseInt(t.text, 0, 32); err != nil {
+				if err := a.validName(t); err != nil {
+					return err
+				}
+				a.inst(opcode)
+				a.fixups = append(a.fixups, fixup{offset: len(a.rom) - 2, label: t})
+				return nil
+			}
+		}
+	}
+	a.pos--
+	v, err := a.value(0, 0xFFF)
+	if err != nil {
+		return err
+	}
+	a.inst(opcode | uint16(v))
+	return nil
+}
+
+// value returns the number, constant or label of the next token, which has to be between lo and hi.
+func (a *assembler) value(lo, hi int) (int, error) {
+	t := a.next()
+	var v int
+	if n, err := strconv.ParseInt(t.text, 0, 32); err == nil {
+		v = int(n)
+	} else if c, ok := a.consts[t.text]; ok {
+		v = c
+	} else if l, ok := a.labels[t.text]; ok {
+		v = l
+	} else if t.text == "" {
+		return 0, fmt.Errorf("line %d: missing value", a.tokens[a.pos-1].line)
+	} else {
+		return 0, fmt.Errorf("line %d: %q is no number, constant or label defined before", t.line, t.text)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("line %d: %s is out of the range %d to %d", t.line, t.text, lo, hi)
+	}
+	return v, nil
+}
+
+// register returns the register of the next token, which is either a register or an alias.
+func (a *assembler) register() (uint16, error) {
+	t := a.next()
+	if x, ok := a.registerOf(t.text); ok {
+		return x, nil
+	}
+	if t.text == "" {
+		return 0, fmt.Errorf("line %d: missing register", a.tokens[a.pos-1].line)
+	}
+	return 0, fmt.Errorf("line %d: %q is no register", t.line, t.text)
+}
+
+// registerOf returns the register of a name like v3 or vF or of an alias.
+func (a *assembler) registerOf(name string) (uint16, bool) {
+	if x, ok := a.aliases[name]; ok {
+		return x, true
+	}
+	if len(name) == 2 && (name[0] == 'v' || name[0] == 'V') {
+		if x, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return uint16(x), true
+		}
+	}
+	return 0, false
+}
+
+// name returns the next token, which has to be a valid name for a label, constant or alias.
+func (a *assembler) name() (token, error) {
+	t := a.next()
+	if t.text == "" {
+		return t, fmt.Errorf("line %d: missing name", a.tokens[a.pos-1].line)
+	}
+	return t, a.validName(t)
+}
+
+// validName reports an error if t can not be used as a name.
+func (a *assembler) validName(t token) error {
+	_, register := a.registerOf(t.text)
+	_, err := strconv.ParseInt(t.text, 0, 32)
+	if register || err == nil || keywords[t.text] || unsupported[t.text] || strings.HasPrefix(t.text, ":") {
+		return fmt.Errorf("line %d: %q can not be used as a name", t.line, t.text)
+	}
+	return nil
+}
+
+// expect consumes the next token, which has to be text.
+func (a *assembler) expect(text string) error {
+	if t := a.next(); t.text != text {
+		return fmt.Errorf("line %d: expected %s but got %q", a.tokens[a.pos-1].line, text, t.text)
+	}
+	return nil
+}
+
+// next returns the next token, or a token without text at the end of the program.
+func (a *assembler) next() token {
+	if a.pos == len(a.tokens) {
+		return token{}
+	}
+	a.pos++
+	return a.tokens[a.pos-1]
+}
+
+// peek returns the text of the next token without consuming it.
+func (a *assembler) peek() string {
+	if a.pos == len(a.tokens) {
+		return ""
+	}
+	return a.tokens[a.pos].text
+}
+
+// here returns the address the next instruction is assembled to.
+func (a *assembler) here() int {
+	if !a.entry {
+		a.entry = true
+		a.inst(0x1000)
+		a.fixups = append(a.fixups, fixup{offset: 0, label: token{text: "main", line: 1}})
+	}
+	return start + len(a.rom)
+}
+
+// emit appends bytes to the ROM.
+func (a *assembler) emit(b ...uint8) {
+	a.here()
+	a.rom = append(a.rom, b...)
+}
+
+// inst appends the instruction opcode to the ROM.
+func (a *assembler) inst(opcode uint16) {
+	a.emit(uint8(opcode>>8), uint8(opcode))
+}
+
+// jump appends a jump whose address is set later with patch and returns its offset.
+func (a *assembler) jump() int {
+	a.inst(0x1000)
+	return len(a.rom) - 2
+}
+
+// patch sets the address of the instruction at offset.
+func (a *assembler) patch(offset, address int) {
+	a.rom[offset] = a.rom[offset]&0xF0 | uint8(address>>8&0x0F)
+	a.rom[offset+1] = uint8(address)
+}
@@ -0,0 +1,150 @@
+package cartridge
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		testName string
+		program  string
+		rom      []byte
+		err      string
+	}{
+		{
+			testName: "Byte literals",
+			program:  ": main\n0x12 0x00 # jump\n255 0b101 -1\n",
+			rom:      []byte{0x12, 0x00, 0xFF, 0x05, 0xFF},
+		},
+		{
+			testName: "Jump to main",
+			program:  "0x00 0xE0\n: main\njump main\n",
+			rom:      []byte{0x12, 0x04, 0x00, 0xE0, 0x12, 0x04},
+		},
+		{
+			testName: "Mnemonics",
+			program: `: main
+				clear
+				v0 := 5  v1 := v0  v2 += 3  v2 += v1  v3 -= v1  v3 -= 1  v3 =- v1
+				v4 |= v1  v4 &= v1  v4 ^= v1  v5 >>= v5  v5 <<= v5
+				v6 := random 0x0F  v7 := delay  v8 := key  delay := v7  buzzer := v8
+				i := 0x300  i := hex v0  i += v1  sprite v0 v1 5
+				bcd v2  save v3  load v3  jump0 0x300  :call 0x300  return ;`,
+			rom: []byte{
+				0x00, 0xE0,
+				0x60, 0x05, 0x81, 0x00, 0x72, 0x03, 0x82, 0x14, 0x83, 0x15, 0x73, 0xFF, 0x83, 0x17,
+				0x84, 0x11, 0x84, 0x12, 0x84, 0x13, 0x85, 0x56, 0x85, 0x5E,
+				0xC6, 0x0F, 0xF7, 0x07, 0xF8, 0x0A, 0xF7, 0x15, 0xF8, 0x18,
+				0xA3, 0x00, 0xF0, 0x29, 0xF1, 0x1E, 0xD0, 0x15,
+				0xF2, 0x33, 0xF3, 0x55, 0xF3, 0x65, 0xB3, 0x00, 0x23, 0x00, 0x00, 0xEE, 0x00, 0xEE,
+			},
+		},
+		{
+			testName: "Labels, constants and aliases",
+			program: `:alias x v3
+				:const speed 2
+				: main
+					x := speed
+					i := sprite-data
+					draw
+					jump main
+				: draw
+					sprite x x 8
+					return
+				: sprite-data
+					:byte 0xFF 0x81`,
+			rom: []byte{0x63, 0x02, 0xA2, 0x0C, 0x22, 0x08, 0x12, 0x00, 0xD3, 0x38, 0x00, 0xEE, 0xFF, 0x81},
+		},
+		{
+			testName: "Conditions",
+			program: `: main
+				if v0 == 5 then v1 := 1
+				if v0 != v1 then clear
+				if v2 key then return
+				if v3 -key then return
+				if v0 > 3 then return
+				if v0 <= v1 then return
+				if v0 < v1 then return
+				if v2 >= 7 then return`,
+			rom: []byte{
+				0x40, 0x05, 0x61, 0x01,
+				0x50, 0x10, 0x00, 0xE0,
+				0xE2, 0xA1, 0x00, 0xEE,
+				0xE3, 0x9E, 0x00, 0xEE,
+				0x6F, 0x03, 0x8F, 0x05, 0x3F, 0x01, 0x00, 0xEE,
+				0x8F, 0x10, 0x8F, 0x05, 0x3F, 0x00, 0x00, 0xEE,
+				0x8F, 0x10, 0x8F, 0x07, 0x3F, 0x01, 0x00, 0xEE,
+				0x6F, 0x07, 0x8F, 0x27, 0x3F, 0x00, 0x00, 0xEE,
+			},
+		},
+		{
+			testName: "Control structures",
+			program: `: main
+				loop
+					v0 += 1
+					while v0 != 10
+					if v0 == 5 begin
+						v1 := 1
+					else
+						v1 := 2
+					end
+				again`,
+			rom: []byte{0x70, 0x01, 0x40, 0x0A, 0x12, 0x12, 0x30, 0x05, 0x12, 0x0E, 0x61, 0x01, 0x12, 0x10, 0x61, 0x02, 0x12, 0x00},
+		},
+		{
+			testName: "Missing main",
+			program:  "clear",
+			err:      "no label main",
+		},
+		{
+			testName: "Undefined label",
+			program:  ": main\njump nowhere",
+			err:      `line 2: undefined label "nowhere"`,
+		},
+		{
+			testName: "Values beyond a byte",
+			program:  ": main\nv0 := 256",
+			err:      "line 2: 256 is out of the range",
+		},
+		{
+			testName: "Label defined twice",
+			program:  ": main\n: main",
+			err:      "already defined",
+		},
+		{
+			testName: "Loop without again",
+			program:  ": main\nloop\nclear",
+			err:      "line 2: loop is not closed",
+		},
+		{
+			testName: "SUPER-CHIP instructions",
+			program:  ": main\nhires",
+			err:      "line 2: hires is not supported",
+		},
+		{
+			testName: "Macros",
+			program:  ": main\n:macro twice x { x x }",
+			err:      "line 2: :macro is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			rom, err := assemble(tt.program)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(rom, tt.rom) {
+				t.Errorf("Expected %X but got %X", tt.rom, rom)
+			}
+		})
+	}
+}
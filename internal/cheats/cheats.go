@@ -0,0 +1,173 @@
+// Package cheats finds and freezes game variables. A Search narrows the addresses of a variable down
+// across snapshots of the memory, a Cheat writes values to addresses after every frame, e.g. to keep
+// the lives at 3. The cheats of a ROM are kept in a JSON file named after its SHA-1 hash:
+//
+//	[
+//	  {"name": "Opponent never scores", "codes": ["0x2F4=0"]},
+//	  {"name": "Nine points", "codes": ["0x2F3=9"], "disabled": true}
+//	]
+package cheats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// Code writes Value to Address. It is written as ADDRESS=VALUE, e.g. 0x2F3=9.
+type Code struct {
+	Address uint16
+	Value   uint8
+}
+
+// ParseCode parses a code written as ADDRESS=VALUE. Both numbers may be decimal or hexadecimal
+// with the prefix 0x.
+func ParseCode(s string) (Code, error) {
+	a, v, found := strings.Cut(strings.TrimSpace(s), "=")
+	if !found {
+		return Code{}, fmt.Errorf("invalid code %q, expected ADDRESS=VALUE", s)
+	}
+	addr, err := strconv.ParseUint(strings.TrimSpace(a), 0, 16)
+	if err != nil || addr >= MemorySize {
+		return Code{}, fmt.Errorf("invalid address in code %q", s)
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(v), 0, 8)
+	if err != nil {
+		return Code{}, fmt.Errorf("invalid value in code %q", s)
+	}
+	return Code{Address: uint16(addr), Value: uint8(value)}, nil
+}
+
+func (c Code) String() string {
+	return fmt.Sprintf("0x%03X=%d", c.Address, c.Value)
+}
+
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Code) UnmarshalText(text []byte) error {
+	code, err := ParseCode(string(text))
+	if err != nil {
+		return err
+	}
+	*c = code
+	return nil
+}
+
+// Cheat is a named set of codes which are applied together.
+type Cheat struct {
+	Name     string `json:"name"`
+	Codes    []Code `json:"codes"`
+	Disabled bool   `json:"disabled,omitempty"` // Kept in the file but not applied
+}
+
+func (c Cheat) String() string {
+	codes := make([]string, len(c.Codes))
+	for i, code := range c.Codes {
+		codes[i] = code.String()
+	}
+	return fmt.Sprintf("%s: %s", c.Name, strings.Join(codes, " "))
+}
+
+// Writer is the memory of the emulator the codes are written to. It is implemented by *chip8.Chip8.
+type Writer interface {
+	SetMemory(addr uint16, value uint8)
+}
+
+// Engine applies the enabled cheats after every frame. It is a renderer which passes the frames on
+// to Renderer, so that it sees every frame the emulator draws.
+type Engine struct {
+	Renderer renderer.Renderer // Optional renderer which shows the frames
+	Machine  Writer            // Emulator the codes are written to, e.g. the Chip8 running the ROM
+	Cheats   []Cheat
+}
+
+// Draw applies the cheats and passes the display on to the renderer.
+func (e *Engine) Draw(display [32][64]bool) {
+	if e.Machine != nil {
+		e.Apply(e.Machine)
+	}
+	if e.Renderer != nil {
+		e.Renderer.Draw(display)
+	}
+}
+
+// SetKeypad implements renderer.KeypadRenderer.
+func (e *Engine) SetKeypad(pressed, polled [16]bool) {
+	renderer.ForwardKeypad(e.Renderer, pressed, polled)
+}
+
+// Active reports whether any cheat is enabled.
+func (e *Engine) Active() bool {
+	for _, c := range e.Cheats {
+		if !c.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply writes the codes of the enabled cheats to m.
+func (e *Engine) Apply(m Writer) {
+	for _, c := range e.Cheats {
+		if c.Disabled {
+			continue
+		}
+		for _, code := range c.Codes {
+			m.SetMemory(code.Address, code.Value)
+		}
+	}
+}
+
+// DefaultDir returns the directory the cheat files are kept in if no other one is configured.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-acht", "cheats"), nil
+}
+
+// Path returns the path of the cheat file of the ROM with the given SHA-1 hash in dir.
+func Path(dir, rom string) string {
+	return filepath.Join(dir, rom+".json")
+}
+
+// Load reads the cheats from the file at path. A missing file holds no cheats.
+func Load(path string) ([]Cheat, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the cheats: %w", err)
+	}
+	var list []Cheat
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Save writes the cheats to the file at path, creating its directory if needed.
+func Save(path string, list []Cheat) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not save the cheats: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not save the cheats: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package cheats
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		want     Code
+		err      bool
+	}{
+		{testName: "Hexadecimal", input: "0x2F3=0x09", want: Code{Address: 0x2F3, Value: 9}},
+		{testName: "Decimal", input: "755 = 9", want: Code{Address: 0x2F3, Value: 9}},
+		{testName: "Missing value", input: "0x2F3", err: true},
+		{testName: "Address out of memory", input: "0x1000=1", err: true},
+		{testName: "Value out of range", input: "0x2F3=256", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got, err := ParseCode(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected an error but got %v", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %v but got %v (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestEngine(t *testing.T) {
+	// The ROM counts down the byte at 0x300, once per frame.
+	rom := []byte{
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x65, // LD V0, [I]
+		0x70, 0xFF, // ADD V0, -1
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x55, // LD [I], V0
+		0x12, 0x00, // JP 0x200
+	}
+	c8 := &chip8.Chip8{Tickrate: 6}
+	if err := c8.Load(rom); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := &Engine{Machine: c8, Cheats: []Cheat{
+		{Name: "Frozen counter", Codes: []Code{{Address: 0x300, Value: 7}}},
+		{Name: "Disabled", Codes: []Code{{Address: 0x301, Value: 1}}, Disabled: true},
+	}}
+	for range 10 {
+		c8.StepFrame()
+		e.Draw(c8.Display())
+		if c8.Memory(0x300) != 7 {
+			t.Fatalf("Expected the counter to be frozen at 7 but got %d", c8.Memory(0x300))
+		}
+	}
+	if c8.Memory(0x301) != 0 {
+		t.Errorf("Expected the disabled cheat not to be applied")
+	}
+	if c8.Register(0) != 6 {
+		t.Errorf("Expected the ROM to count down from the frozen value to 6 but got %d", c8.Register(0))
+	}
+}
+
+func TestActive(t *testing.T) {
+	tests := []struct {
+		testName string
+		cheats   []Cheat
+		expected bool
+	}{
+		{testName: "No cheats", cheats: nil, expected: false},
+		{testName: "Only disabled cheats", cheats: []Cheat{{Name: "A", Disabled: true}}, expected: false},
+		{testName: "One enabled cheat", cheats: []Cheat{{Name: "A", Disabled: true}, {Name: "B"}}, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			e := &Engine{Cheats: test.cheats}
+			if got := e.Active(); got != test.expected {
+				t.Errorf("Expected %v but got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "cheats"), "b232ef880bd6060fb45fa6effed7edf0ae95670e")
+
+	list, err := Load(path)
+	if err != nil || list != nil {
+		t.Fatalf("Expected no cheats for a missing file but got %v (%v)", list, err)
+	}
+
+	want := []Cheat{
+		{Name: "Opponent never scores", Codes: []Code{{Address: 0x2F4, Value: 0}}},
+		{Name: "Nine points", Codes: []Code{{Address: 0x2F3, Value: 9}, {Address: 0x2F4, Value: 0}}, Disabled: true},
+	}
+	if err := Save(path, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v but got %v", want, got)
+	}
+}
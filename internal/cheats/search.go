@@ -0,0 +1,124 @@
+package cheats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemorySize is the number of bytes a search covers, the whole memory of the CHIP-8.
+const MemorySize = 4096
+
+// Memory is the memory of the emulator. It is implemented by *chip8.Chip8.
+type Memory interface {
+	Memory(addr uint16) uint8
+}
+
+// Comparison selects the candidates of a search by comparing a byte to its value in the previous
+// snapshot.
+type Comparison int
+
+const (
+	Equal     Comparison = iota // The byte did not change
+	Changed                     // The byte changed
+	Increased                   // The byte is greater than before
+	Decreased                   // The byte is less than before
+)
+
+func (c Comparison) String() string {
+	switch c {
+	case Equal:
+		return "equal"
+	case Changed:
+		return "changed"
+	case Increased:
+		return "increased"
+	default:
+		return "decreased"
+	}
+}
+
+// ParseComparison returns the comparison with the given name or its short form eq, ne, inc or dec.
+func ParseComparison(s string) (Comparison, error) {
+	switch strings.ToLower(s) {
+	case "equal", "eq":
+		return Equal, nil
+	case "changed", "ne":
+		return Changed, nil
+	case "increased", "inc":
+		return Increased, nil
+	case "decreased", "dec":
+		return Decreased, nil
+	}
+	return 0, fmt.Errorf("unknown comparison %q, expected equal, changed, increased or decreased", s)
+}
+
+func (c Comparison) match(previous, current uint8) bool {
+	switch c {
+	case Equal:
+		return current == previous
+	case Changed:
+		return current != previous
+	case Increased:
+		return current > previous
+	default:
+		return current < previous
+	}
+}
+
+// Search finds the address of a game variable, e.g. the lives, by narrowing the candidates across
+// snapshots of the memory: start a search, lose a life, keep the bytes which decreased, play on
+// without losing one, keep the bytes which are equal and so on.
+type Search struct {
+	snapshot   [MemorySize]uint8
+	candidates []uint16
+}
+
+// NewSearch starts a search with every address of the memory as candidate.
+func NewSearch(m Memory) *Search {
+	s := &Search{candidates: make([]uint16, MemorySize)}
+	for addr := range s.candidates {
+		s.candidates[addr] = uint16(addr)
+	}
+	s.take(m)
+	return s
+}
+
+// Narrow keeps the candidates whose byte compares to the previous snapshot as given and takes a
+// new snapshot. It returns the number of remaining candidates.
+func (s *Search) Narrow(m Memory, c Comparison) int {
+	return s.filter(m, func(addr uint16, value uint8) bool { return c.match(s.snapshot[addr], value) })
+}
+
+// NarrowValue keeps the candidates which hold value and takes a new snapshot. It returns the
+// number of remaining candidates.
+func (s *Search) NarrowValue(m Memory, value uint8) int {
+	return s.filter(m, func(_ uint16, v uint8) bool { return v == value })
+}
+
+// Candidates returns the remaining addresses in ascending order.
+func (s *Search) Candidates() []uint16 {
+	return s.candidates
+}
+
+// Value returns the byte at addr in the latest snapshot.
+func (s *Search) Value(addr uint16) uint8 {
+	return s.snapshot[addr%MemorySize]
+}
+
+func (s *Search) filter(m Memory, keep func(addr uint16, value uint8) bool) int {
+	remaining := s.candidates[:0]
+	for _, addr := range s.candidates {
+		if keep(addr, m.Memory(addr)) {
+			remaining = append(remaining, addr)
+		}
+	}
+	s.candidates = remaining
+	s.take(m)
+	return len(s.candidates)
+}
+
+func (s *Search) take(m Memory) {
+	for addr := range s.snapshot {
+		s.snapshot[addr] = m.Memory(uint16(addr))
+	}
+}
@@ -0,0 +1,100 @@
+package cheats
+
+import (
+	"slices"
+	"testing"
+)
+
+// memory is a fake memory of the emulator.
+type memory [MemorySize]uint8
+
+func (m *memory) Memory(addr uint16) uint8 {
+	return m[addr%MemorySize]
+}
+
+func (m *memory) SetMemory(addr uint16, value uint8) {
+	m[addr%MemorySize] = value
+}
+
+func TestSearch(t *testing.T) {
+	// The lives are kept at 0x300, 0x301 changes every frame and 0x302 only once.
+	m := &memory{}
+	m[0x300], m[0x301], m[0x302] = 3, 10, 3
+	s := NewSearch(m)
+	if len(s.Candidates()) != MemorySize {
+		t.Fatalf("Expected %d candidates but got %d", MemorySize, len(s.Candidates()))
+	}
+
+	steps := []struct {
+		testName   string
+		change     func()
+		narrow     func() int
+		candidates []uint16
+	}{
+		{
+			testName:   "Lost a life",
+			change:     func() { m[0x300], m[0x301], m[0x302] = 2, 9, 2 },
+			narrow:     func() int { return s.Narrow(m, Decreased) },
+			candidates: []uint16{0x300, 0x301, 0x302},
+		},
+		{
+			testName:   "No life lost",
+			change:     func() { m[0x301] = 8 },
+			narrow:     func() int { return s.Narrow(m, Equal) },
+			candidates: []uint16{0x300, 0x302},
+		},
+		{
+			testName:   "Extra life",
+			change:     func() { m[0x300] = 3 },
+			narrow:     func() int { return s.Narrow(m, Increased) },
+			candidates: []uint16{0x300},
+		},
+		{
+			testName:   "Value",
+			change:     func() {},
+			narrow:     func() int { return s.NarrowValue(m, 3) },
+			candidates: []uint16{0x300},
+		},
+		{
+			testName:   "Changed",
+			change:     func() {},
+			narrow:     func() int { return s.Narrow(m, Changed) },
+			candidates: []uint16{},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.testName, func(t *testing.T) {
+			step.change()
+			n := step.narrow()
+			if n != len(step.candidates) || !slices.Equal(s.Candidates(), step.candidates) {
+				t.Errorf("Expected the candidates %#x but got %#x", step.candidates, s.Candidates())
+			}
+		})
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Comparison
+	}{
+		{"eq", Equal},
+		{"Changed", Changed},
+		{"inc", Increased},
+		{"decreased", Decreased},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseComparison(tt.input)
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %s but got %s (%v)", tt.want, got, err)
+			}
+		})
+	}
+
+	if _, err := ParseComparison("less"); err == nil {
+		t.Errorf("Expected an error for an unknown comparison but got none")
+	}
+}
@@ -2,10 +2,12 @@ package chip8
 
 import (
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"time"
 
+	"github.com/waldgaenger/go-acht/internal/audio"
+	"github.com/waldgaenger/go-acht/internal/font"
 	"github.com/waldgaenger/go-acht/internal/input"
 	"github.com/waldgaenger/go-acht/internal/renderer"
 )
@@ -15,23 +17,13 @@ const fontStartAddress int = 0x50
 const displayWidth = 64
 const displayHeight = 32
 
-var fontSet = [80]byte{
-	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-	0x20, 0x60, 0x20, 0x20, 0x70, // 1
-	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+// DefaultTickrate is the number of instructions executed per frame if no Tickrate is set,
+// which results in roughly 1000 instructions per second.
+const DefaultTickrate = 17
+
+// FontSprite returns the sprite of the built-in font for the hex digit, as drawn after FX29.
+func FontSprite(digit uint8) [5]byte {
+	return font.Sprite(digit)
 }
 
 type opcodeHandler func(*Chip8)
@@ -83,13 +75,34 @@ type Chip8 struct {
 	stackPointer   uint8      // Always points to the current top of the call stack
 	opcode         uint16
 	keyPad         [16]bool
+	polled         [16]bool           // Keys read by EX9E, EXA1 and FX0A during the current frame
+	lastPolled     [16]bool           // Keys read during the previous frame
 	delayTimer     uint8              // The delay timer is decremented at a rate of 60 Hz according to the specification.
 	soundTimer     uint8              // The sound timer is decremented at a rate of 60 Hz according to the specification.
 	display        [32][64]bool       // 64x32 monochrome display
-	scaleFactor    int32              // Holds the scaling factor of the display
 	running        bool               // Indicates whether the emulator is running
+	paused         bool               // Indicates whether the execution of instructions is suspended
+	waitVBlank     bool               // Indicates whether a DXYN waits for the next frame (VBlank quirk)
+	frame          uint64             // Counts the 60 Hz frames since the emulator was started
+	history        *history           // Undo information of the recently executed instructions, nil if disabled
+	rng            uint64             // State of the random number generator used by CXKK
+	seeded         bool               // Indicates whether rng was seeded, by Seed or when the first ROM was loaded
 	Input          input.InputHandler // Holds the keyboard handler
 	Renderer       renderer.Renderer  // Holds the graphics renderer
+	Audio          audio.Beeper       // Optional sound output
+	Debugger       Debugger           // Optional debugger that is notified after every instruction
+	Quirks         Quirks             // Selects the behaviour of the ambiguous instructions
+	Tickrate       int                // Number of instructions per frame, DefaultTickrate if zero
+	Log            io.Writer          // Receives the messages about errors of the ROM, os.Stdout if nil
+}
+
+// logf writes a message about an error of the ROM to Log.
+func (c8 *Chip8) logf(format string, args ...any) {
+	w := c8.Log
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, format, args...)
 }
 
 // Run loads the CHIP-8 ROM from the specified romPath and starts the main emulation loop.
@@ -101,31 +114,118 @@ func (c8 *Chip8) Run(romPath string) error {
 	}
 	c8.init()
 
-	clock := time.NewTicker(time.Millisecond)
+	return c8.loop()
+}
+
+// RunROM starts the main emulation loop like Run, but with a ROM which is already in memory,
+// e.g. because it was extracted from a cartridge.
+func (c8 *Chip8) RunROM(rom []byte) error {
+	if err := c8.loadBytes(rom); err != nil {
+		return fmt.Errorf("failed to load ROM: %w", err)
+	}
+	c8.init()
+
+	return c8.loop()
+}
+
+// loop emulates and renders one frame per 60 Hz tick until the emulator is stopped.
+func (c8 *Chip8) loop() error {
 	video := time.NewTicker(time.Second / 60)
-	sound := time.NewTicker(time.Second / 60)
-	delay := time.NewTicker(time.Second / 60)
+	defer video.Stop()
 
 	for c8.Running() {
-		select {
-		case <-sound.C:
-			if c8.soundTimer > 0 {
-				c8.soundTimer--
-			}
-		case <-delay.C:
-			if c8.delayTimer > 0 {
-				c8.delayTimer--
-			}
-		case <-video.C:
-			c8.draw()
-		case <-clock.C:
-			c8.updateInput()
-			c8.cycle()
-		}
+		<-video.C
+		c8.Tick()
+	}
+
+	return nil
+}
+
+// Tick runs one 60 Hz tick of the main loop: it processes the input, emulates a frame unless the
+// emulator is paused, renders it and plays the sound. Run calls it from a ticker, front-ends with a
+// timing of their own, e.g. requestAnimationFrame in a browser, call it after Load instead.
+func (c8 *Chip8) Tick() {
+	if c8.Input != nil {
+		c8.updateInput()
 	}
+	if !c8.paused {
+		c8.StepFrame()
+	}
+	if c8.Renderer != nil {
+		c8.draw()
+	}
+	if c8.Audio != nil {
+		c8.Audio.Beep(c8.soundTimer > 0)
+	}
+	if c8.Debugger != nil {
+		c8.Debugger.Update(c8)
+	}
+}
 
+// Load resets the emulator and loads the given ROM, so that the next frame starts executing it.
+// The configuration (Input, Renderer, Debugger, Quirks, Tickrate and Log) is kept.
+func (c8 *Chip8) Load(rom []byte) error {
+	c8.reset()
+	if err := c8.loadBytes(rom); err != nil {
+		return err
+	}
+	c8.init()
 	return nil
+}
+
+// StepFrame emulates a single 60 Hz frame without rendering it: it executes Tickrate instructions
+// and decrements the timers. The frame ends early if the emulator is paused, halted or a
+// DXYN waits for the next frame.
+func (c8 *Chip8) StepFrame() {
+	tickrate := c8.Tickrate
+	if tickrate <= 0 {
+		tickrate = DefaultTickrate
+	}
 
+	c8.waitVBlank = false
+	for range tickrate {
+		if !c8.running || c8.paused || c8.waitVBlank {
+			break
+		}
+		c8.cycle()
+	}
+
+	// A frame interrupted by the debugger is not finished, its timers are updated once it is resumed.
+	if c8.paused {
+		return
+	}
+
+	if c8.delayTimer > 0 {
+		c8.delayTimer--
+	}
+	if c8.soundTimer > 0 {
+		c8.soundTimer--
+	}
+	c8.lastPolled, c8.polled = c8.polled, [16]bool{}
+	c8.frame++
+}
+
+// reset clears the complete machine state but keeps the configuration.
+func (c8 *Chip8) reset() {
+	c8.registers = [16]uint8{}
+	c8.memory = [4096]uint8{}
+	c8.programCounter = 0
+	c8.indexRegister = 0
+	c8.callStack = [16]uint16{}
+	c8.stackPointer = 0
+	c8.opcode = 0
+	c8.keyPad = [16]bool{}
+	c8.polled = [16]bool{}
+	c8.lastPolled = [16]bool{}
+	c8.delayTimer = 0
+	c8.soundTimer = 0
+	c8.display = [32][64]bool{}
+	c8.paused = false
+	c8.waitVBlank = false
+	c8.frame = 0
+	if c8.history != nil {
+		c8.EnableHistory(len(c8.history.records))
+	}
 }
 
 // Initializes the values of the Chip8 structure.
@@ -133,7 +233,11 @@ func (c8 *Chip8) init() {
 	c8.programCounter = uint16(startAddress)
 
 	// Loads the set of fonts into the specified memory area
-	copy(c8.memory[fontStartAddress:], fontSet[:])
+	copy(c8.memory[fontStartAddress:], font.Set[:])
+
+	if !c8.seeded {
+		c8.Seed(uint64(time.Now().UnixNano()))
+	}
 
 	c8.running = true
 }
@@ -143,6 +247,7 @@ func (c8 *Chip8) Running() bool {
 }
 
 func (c8 *Chip8) draw() {
+	renderer.ForwardKeypad(c8.Renderer, c8.keyPad, c8.lastPolled)
 	c8.Renderer.Draw(c8.display)
 }
 
@@ -162,15 +267,26 @@ func (c8 *Chip8) fetch() {
 
 // cycle carries out one full CPU cycle: fetches the next opcode, decodes it using the dispatch table, and executes the matching instruction handler.
 func (c8 *Chip8) cycle() {
+	previousOpcode := c8.opcode
 	c8.fetch()
+	if c8.history != nil {
+		c8.record(previousOpcode)
+	}
 	c8.programCounter += 2
 
 	if handler := dispatchTable[c8.decodeOpcode()]; handler != nil {
 		handler(c8)
 	} else {
-		fmt.Printf("Invalid opcode: %#04X at %#04X\n", c8.opcode, c8.programCounter-2)
+		c8.logf("Invalid opcode: %#04X at %#04X\n", c8.opcode, c8.programCounter-2)
 	}
 
+	if rec := c8.current(); rec != nil {
+		rec.written = c8.writtenRegisters(rec.address)
+	}
+
+	if c8.Debugger != nil {
+		c8.Debugger.AfterCycle(c8)
+	}
 }
 
 // loadRom loads the ROM from a given path into the CHIP8 memory.
@@ -180,6 +296,11 @@ func (c8 *Chip8) loadRom(pathToRom string) error {
 		return fmt.Errorf("could not open ROM file: %w", err)
 	}
 
+	return c8.loadBytes(data)
+}
+
+// loadBytes copies the ROM into the CHIP8 memory at the start address.
+func (c8 *Chip8) loadBytes(data []byte) error {
 	if len(data) > len(c8.memory)-startAddress {
 		return fmt.Errorf("ROM (%d bytes) is too large for memory (%d bytes available)", len(data), len(c8.memory)-startAddress)
 	}
@@ -206,6 +327,10 @@ func (c8 *Chip8) decodeOpcode() uint16 {
 
 // Clears the display by resetting all pixels to 0.
 func (c8 *Chip8) op00E0() {
+	if rec := c8.current(); rec != nil {
+		display := c8.display
+		rec.display = &display
+	}
 	c8.display = [32][64]bool{}
 }
 
@@ -214,7 +339,7 @@ func (c8 *Chip8) op00E0() {
 func (c8 *Chip8) op00EE() {
 	// Stack underflow protection
 	if c8.stackPointer == 0 {
-		fmt.Printf(
+		c8.logf(
 			"Stack underflow at PC=0x%03X (stackPointer=%d, stack size=%d).\n"+"Emulation halted.",
 			c8.programCounter, c8.stackPointer, len(c8.callStack),
 		)
@@ -240,7 +365,7 @@ func (c8 *Chip8) op2NNN() {
 
 	// Stack overflow protection
 	if c8.stackPointer >= uint8(len(c8.callStack)) {
-		fmt.Printf(
+		c8.logf(
 			"Stack overflow at PC=0x%03X (stackPointer=%d, stack size=%d).\n"+
 				"Too many nested CALLs. Emulation halted.\n"+
 				"Check your ROM for infinite or excessive recursion.",
@@ -250,6 +375,10 @@ func (c8 *Chip8) op2NNN() {
 		return
 	}
 
+	if rec := c8.current(); rec != nil {
+		rec.stackWritten = true
+		rec.stackValue = c8.callStack[c8.stackPointer]
+	}
 	c8.callStack[c8.stackPointer] = c8.programCounter
 	c8.stackPointer += 1
 
@@ -319,6 +448,10 @@ func (c8 *Chip8) op8XY1() {
 	var vy uint8 = uint8((c8.opcode & 0x00F0) >> 4)
 
 	c8.registers[vx] = (c8.registers[vx] | c8.registers[vy])
+
+	if c8.Quirks.VFReset {
+		c8.registers[0xF] = 0
+	}
 }
 
 // Sets VX to (VX AND VY)
@@ -327,6 +460,10 @@ func (c8 *Chip8) op8XY2() {
 	var vy uint8 = uint8((c8.opcode & 0x00F0) >> 4)
 
 	c8.registers[vx] = (c8.registers[vx] & c8.registers[vy])
+
+	if c8.Quirks.VFReset {
+		c8.registers[0xF] = 0
+	}
 }
 
 // Sets VX to (VX XOR VY)
@@ -335,6 +472,10 @@ func (c8 *Chip8) op8XY3() {
 	var vy uint8 = uint8((c8.opcode & 0x00F0) >> 4)
 
 	c8.registers[vx] = (c8.registers[vx] ^ c8.registers[vy])
+
+	if c8.Quirks.VFReset {
+		c8.registers[0xF] = 0
+	}
 }
 
 // Add VY to VX, sets the carry flag if necessary.
@@ -372,6 +513,10 @@ func (c8 *Chip8) op8XY5() {
 func (c8 *Chip8) op8XY6() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 
+	if c8.Quirks.ShiftVY {
+		c8.registers[vx] = c8.registers[(c8.opcode&0x00F0)>>4]
+	}
+
 	c8.registers[0xF] = (c8.registers[vx] & 0x1)
 
 	c8.registers[vx] = c8.registers[vx] >> 1
@@ -394,6 +539,9 @@ func (c8 *Chip8) op8XY7() {
 // Shift the register VX to the left. Sets the VF to one if the most significant bit is one.
 func (c8 *Chip8) op8XYE() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
+	if c8.Quirks.ShiftVY {
+		c8.registers[vx] = c8.registers[(c8.opcode&0x00F0)>>4]
+	}
 	c8.registers[0xF] = (c8.registers[vx] & uint8(0b10000000) >> 7)
 	c8.registers[vx] = c8.registers[vx] << 1
 }
@@ -415,18 +563,42 @@ func (c8 *Chip8) opANNN() {
 }
 
 // Jumps to address NNN + register V0.
+// With the JumpVX quirk the instruction is read as BXNN and jumps to XNN + register VX.
 func (c8 *Chip8) opBNNN() {
 	var address uint16 = c8.opcode & 0x0FFF
 
+	if c8.Quirks.JumpVX {
+		c8.programCounter = uint16(c8.registers[(c8.opcode&0x0F00)>>8]) + address
+		return
+	}
+
 	c8.programCounter = uint16(c8.registers[0x0]) + uint16((address))
 }
 
+// Seed seeds the random number generator of CXKK. Two emulators seeded alike and fed the same input
+// run the same, which netplay relies on. Without a seed it is seeded with the time when the first ROM
+// is loaded.
+func (c8 *Chip8) Seed(seed uint64) {
+	c8.rng = seed
+	c8.seeded = true
+}
+
+// random returns the next random byte. The generator is SplitMix64, whose whole state is a single
+// number, so it can be saved with the rest of the machine.
+func (c8 *Chip8) random() uint8 {
+	c8.rng += 0x9E3779B97F4A7C15
+	z := c8.rng
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return uint8((z ^ (z >> 31)) >> 56)
+}
+
 // Stores the result of a random byte & KK in VX.
 func (c8 *Chip8) opCXKK() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 	var value uint8 = uint8(c8.opcode & 0x00FF)
 
-	c8.registers[vx] = uint8(rand.Intn(256)) & value
+	c8.registers[vx] = c8.random() & value
 }
 
 // Draws the next n bytes from the position of the index register at position (VX, VY).
@@ -437,12 +609,21 @@ func (c8 *Chip8) opDXYN() {
 
 	c8.registers[0xF] = 0 // Resets collision flag
 
+	if c8.Quirks.VBlank {
+		c8.waitVBlank = true
+	}
+
 	for row := uint16(0); row < height; row++ {
 		spriteByte := c8.memory[c8.indexRegister+row]
 		for col := uint16(0); col < 8; col++ {
 			if (spriteByte & (0x80 >> col)) != 0 {
-				dx := (uint16(x) + col) % displayWidth
-				dy := (uint16(y) + row) % displayHeight
+				dx := (uint16(x)%displayWidth + col)
+				dy := (uint16(y)%displayHeight + row)
+				if c8.Quirks.Clip && (dx >= displayWidth || dy >= displayHeight) {
+					continue
+				}
+				dx %= displayWidth
+				dy %= displayHeight
 
 				// Detect collision
 				if c8.display[dy][dx] {
@@ -450,6 +631,9 @@ func (c8 *Chip8) opDXYN() {
 				}
 				// XOR pixel
 				c8.display[dy][dx] = c8.display[dy][dx] != true
+				if rec := c8.current(); rec != nil {
+					rec.pixels = append(rec.pixels, dy*displayWidth+dx)
+				}
 			}
 		}
 	}
@@ -459,7 +643,8 @@ func (c8 *Chip8) opDXYN() {
 func (c8 *Chip8) opEX9E() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 
-	var key uint8 = c8.registers[vx]
+	var key uint8 = c8.registers[vx] & 0xF
+	c8.polled[key] = true
 
 	if c8.keyPad[key] == true {
 		c8.programCounter += 2
@@ -470,7 +655,8 @@ func (c8 *Chip8) opEX9E() {
 func (c8 *Chip8) opEXA1() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 
-	var key uint8 = c8.registers[vx]
+	var key uint8 = c8.registers[vx] & 0xF
+	c8.polled[key] = true
 
 	if c8.keyPad[key] == false {
 		c8.programCounter += 2
@@ -488,6 +674,10 @@ func (c8 *Chip8) opFX07() {
 func (c8 *Chip8) opFX0A() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 	pressed := false
+	// Any key ends the wait, so all of them are polled.
+	for key := range c8.polled {
+		c8.polled[key] = true
+	}
 	for key, state := range c8.keyPad {
 		if state != false {
 			c8.registers[vx] = uint8(key)
@@ -538,13 +728,13 @@ func (c8 *Chip8) opFX33() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 	var value uint8 = c8.registers[vx]
 
-	c8.memory[c8.indexRegister+2] = value % 10
+	c8.writeMemory(c8.indexRegister+2, value%10)
 	value /= 10
 
-	c8.memory[c8.indexRegister+1] = value % 10
+	c8.writeMemory(c8.indexRegister+1, value%10)
 	value /= 10
 
-	c8.memory[c8.indexRegister] = value % 10
+	c8.writeMemory(c8.indexRegister, value%10)
 
 }
 
@@ -553,11 +743,10 @@ func (c8 *Chip8) opFX55() {
 	var vx uint8 = uint8((c8.opcode & 0x0F00) >> 8)
 
 	for i := 0; uint8(i) <= vx; i++ {
-		c8.memory[c8.indexRegister+uint16(i)] = c8.registers[i]
+		c8.writeMemory(c8.indexRegister+uint16(i), c8.registers[i])
 	}
 
-	// Reference manual dependent
-	c8.indexRegister = ((c8.opcode & 0x0F00) >> 8) + 1
+	c8.indexRegister = c8.indexAfterLoadStore((c8.opcode & 0x0F00) >> 8)
 }
 
 // Reads into registers V0 - VX from memory starting at location I.
@@ -568,5 +757,5 @@ func (c8 *Chip8) opFX65() {
 		c8.registers[i] = c8.memory[c8.indexRegister+uint16(i)]
 	}
 
-	c8.indexRegister = ((c8.opcode & 0x0F00) >> 8) + 1
+	c8.indexRegister = c8.indexAfterLoadStore((c8.opcode & 0x0F00) >> 8)
 }
@@ -1,6 +1,7 @@
 package chip8
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -1784,3 +1785,60 @@ func TestOpFX55(t *testing.T) {
 		})
 	}
 }
+
+func TestPolledKeys(t *testing.T) {
+	tests := []struct {
+		testName string
+		program  []byte
+		want     [16]bool
+	}{
+		{
+			testName: "EX9E and EXA1 poll the key in VX",
+			program: []byte{
+				0x60, 0x05, // 0x200: LD V0, 5
+				0x61, 0x0E, // 0x202: LD V1, 0xE
+				0xE0, 0x9E, // 0x204: SKP V0
+				0xE1, 0xA1, // 0x206: SKNP V1
+				0x12, 0x04, // 0x208: JP 0x204
+			},
+			want: [16]bool{0x5: true, 0xE: true},
+		},
+		{
+			testName: "FX0A polls all keys",
+			program:  []byte{0xF0, 0x0A}, // 0x200: LD V0, K
+			want:     [16]bool{true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true},
+		},
+		{
+			testName: "Keys are not polled without a key instruction",
+			program:  []byte{0x12, 0x00}, // 0x200: JP 0x200
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c8 := &Chip8{}
+			if err := c8.Load(tt.program); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			c8.StepFrame()
+
+			if polled := c8.PolledKeys(); polled != tt.want {
+				t.Errorf("Expected the polled keys %v but got %v", tt.want, polled)
+			}
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	var log bytes.Buffer
+	c8 := &Chip8{Log: &log}
+	c8.init()
+	copy(c8.memory[startAddress:], []byte{0x00, 0xEE}) // RET with an empty call stack
+
+	c8.cycle()
+
+	if !bytes.Contains(log.Bytes(), []byte("Stack underflow")) {
+		t.Errorf("Expected the stack underflow to be written to Log but got %q", log.String())
+	}
+}
@@ -0,0 +1,103 @@
+package chip8
+
+// Debugger is notified by the emulator while it is running.
+// AfterCycle is called after every executed instruction and may pause the emulator.
+// Update is called once per frame, also while the emulator is paused, and is the place
+// to process user commands on the emulation goroutine.
+type Debugger interface {
+	AfterCycle(c8 *Chip8)
+	Update(c8 *Chip8)
+}
+
+// Pause suspends the execution of instructions. Input and video are still processed.
+func (c8 *Chip8) Pause() {
+	c8.paused = true
+}
+
+// Resume continues the execution of instructions after a Pause.
+func (c8 *Chip8) Resume() {
+	c8.paused = false
+}
+
+// Paused reports whether the execution of instructions is suspended.
+func (c8 *Chip8) Paused() bool {
+	return c8.paused
+}
+
+// Step executes exactly one instruction, regardless of whether the emulator is paused.
+func (c8 *Chip8) Step() {
+	c8.cycle()
+}
+
+// Register returns the value of register VX.
+func (c8 *Chip8) Register(x uint8) uint8 {
+	return c8.registers[x&0xF]
+}
+
+// IndexRegister returns the value of the index register I.
+func (c8 *Chip8) IndexRegister() uint16 {
+	return c8.indexRegister
+}
+
+// ProgramCounter returns the address of the next instruction.
+func (c8 *Chip8) ProgramCounter() uint16 {
+	return c8.programCounter
+}
+
+// StackPointer returns the number of return addresses on the call stack.
+func (c8 *Chip8) StackPointer() uint8 {
+	return c8.stackPointer
+}
+
+// DelayTimer returns the current value of the delay timer.
+func (c8 *Chip8) DelayTimer() uint8 {
+	return c8.delayTimer
+}
+
+// SoundTimer returns the current value of the sound timer.
+func (c8 *Chip8) SoundTimer() uint8 {
+	return c8.soundTimer
+}
+
+// Memory returns the byte stored at addr. Addresses wrap around at the end of the memory.
+func (c8 *Chip8) Memory(addr uint16) uint8 {
+	return c8.memory[int(addr)%len(c8.memory)]
+}
+
+// SetMemory stores value at addr, e.g. to apply a cheat. Addresses wrap around at the end of the
+// memory. The write is not recorded in the history.
+func (c8 *Chip8) SetMemory(addr uint16, value uint8) {
+	c8.memory[int(addr)%len(c8.memory)] = value
+}
+
+// Opcode returns the most recently fetched instruction.
+func (c8 *Chip8) Opcode() uint16 {
+	return c8.opcode
+}
+
+// SetKey sets the state of a key of the hex keypad. It allows to feed input into the emulator
+// without an InputHandler, e.g. when it is driven by StepFrame.
+func (c8 *Chip8) SetKey(key uint8, pressed bool) {
+	c8.keyPad[key&0xF] = pressed
+}
+
+// KeyPad returns the state of all 16 keys of the hex keypad.
+func (c8 *Chip8) KeyPad() [16]bool {
+	return c8.keyPad
+}
+
+// PolledKeys returns the keys the ROM read with EX9E, EXA1 or FX0A during the previous frame.
+// While FX0A waits for a key, all keys are polled.
+func (c8 *Chip8) PolledKeys() [16]bool {
+	return c8.lastPolled
+}
+
+// Display returns the current content of the screen.
+func (c8 *Chip8) Display() [32][64]bool {
+	return c8.display
+}
+
+// Frame returns the number of 60 Hz frames emulated so far.
+func (c8 *Chip8) Frame() uint64 {
+	return c8.frame
+}
@@ -0,0 +1,250 @@
+package chip8
+
+// The history records undo information for every executed instruction, which allows
+// stepping backwards through the execution (time-travel debugging).
+//
+// Each record holds a snapshot of the small parts of the machine state (registers, timers, I, PC, SP,
+// the polled keys, the VBlank wait and the state of the random number generator) taken before the
+// instruction was executed. The handlers which modify the larger parts of the state additionally
+// record their changes: op2NNN the overwritten call stack slot, op00E0 and opDXYN the display, opFX33
+// and opFX55 the overwritten memory bytes.
+
+// memoryWrite holds the previous value of a memory byte overwritten by an instruction.
+type memoryWrite struct {
+	address uint16
+	old     uint8
+}
+
+// undoRecord holds everything that is needed to revert a single instruction.
+type undoRecord struct {
+	address        uint16 // Address of the instruction
+	opcode         uint16 // The executed instruction
+	previousOpcode uint16
+	registers      [16]uint8
+	indexRegister  uint16
+	stackPointer   uint8
+	delayTimer     uint8
+	soundTimer     uint8
+	frame          uint64
+	waitVBlank     bool
+	polled         [16]bool
+	lastPolled     [16]bool
+	rng            uint64
+	written        uint32 // Bit 0 - 15 are set if the instruction wrote V0 - VF, bit 16 if it wrote I
+
+	stackWritten bool   // Indicates whether the instruction overwrote a call stack slot
+	stackValue   uint16 // Previous value of the call stack slot at stackPointer
+
+	memory  []memoryWrite
+	pixels  []uint16      // Pixels toggled by the instruction, as y*displayWidth + x
+	display *[32][64]bool // Display before it was cleared
+}
+
+const indexWritten = 1 << 16
+
+// history is a ring buffer of undo records with a fixed capacity.
+// When it is full the oldest record is overwritten.
+type history struct {
+	records []undoRecord
+	head    int // Index of the next record to write
+	length  int
+}
+
+// EnableHistory starts recording undo information for the last size instructions,
+// which is needed by StepBack and the LastWrite queries. A size of 0 disables the history.
+func (c8 *Chip8) EnableHistory(size int) {
+	if size <= 0 {
+		c8.history = nil
+		return
+	}
+	c8.history = &history{records: make([]undoRecord, size)}
+}
+
+// HistoryLen returns the number of instructions which can currently be reverted.
+func (c8 *Chip8) HistoryLen() int {
+	if c8.history == nil {
+		return 0
+	}
+	return c8.history.length
+}
+
+// record starts a new undo record for the instruction at the program counter.
+// It must be called after the instruction was fetched and before it is executed.
+func (c8 *Chip8) record(previousOpcode uint16) {
+	h := c8.history
+	rec := &h.records[h.head]
+
+	*rec = undoRecord{
+		address:        c8.programCounter,
+		opcode:         c8.opcode,
+		previousOpcode: previousOpcode,
+		registers:      c8.registers,
+		indexRegister:  c8.indexRegister,
+		stackPointer:   c8.stackPointer,
+		delayTimer:     c8.delayTimer,
+		soundTimer:     c8.soundTimer,
+		frame:          c8.frame,
+		waitVBlank:     c8.waitVBlank,
+		polled:         c8.polled,
+		lastPolled:     c8.lastPolled,
+		rng:            c8.rng,
+		// Reuse the buffers of the overwritten record to keep the garbage collector quiet.
+		memory: rec.memory[:0],
+		pixels: rec.pixels[:0],
+	}
+
+	h.head = (h.head + 1) % len(h.records)
+	if h.length < len(h.records) {
+		h.length++
+	}
+}
+
+// current returns the undo record of the instruction that is being executed or nil
+// if no history is recorded.
+func (c8 *Chip8) current() *undoRecord {
+	h := c8.history
+	if h == nil || h.length == 0 {
+		return nil
+	}
+	return &h.records[(h.head-1+len(h.records))%len(h.records)]
+}
+
+// writeMemory stores value at addr and records the previous value for StepBack.
+func (c8 *Chip8) writeMemory(addr uint16, value uint8) {
+	if rec := c8.current(); rec != nil {
+		rec.memory = append(rec.memory, memoryWrite{address: addr, old: c8.memory[addr]})
+	}
+	c8.memory[addr] = value
+}
+
+// StepBack reverts the most recently executed instruction and reports whether there was
+// an instruction in the history to revert.
+func (c8 *Chip8) StepBack() bool {
+	rec := c8.current()
+	if rec == nil {
+		return false
+	}
+
+	// Memory writes are reverted in reverse order, in case an instruction wrote a byte twice.
+	for i := len(rec.memory) - 1; i >= 0; i-- {
+		c8.memory[rec.memory[i].address] = rec.memory[i].old
+	}
+	for _, p := range rec.pixels {
+		c8.display[p/displayWidth][p%displayWidth] = !c8.display[p/displayWidth][p%displayWidth]
+	}
+	if rec.display != nil {
+		c8.display = *rec.display
+	}
+	if rec.stackWritten {
+		c8.callStack[rec.stackPointer] = rec.stackValue
+	}
+
+	c8.programCounter = rec.address
+	c8.opcode = rec.previousOpcode
+	c8.registers = rec.registers
+	c8.indexRegister = rec.indexRegister
+	c8.stackPointer = rec.stackPointer
+	c8.delayTimer = rec.delayTimer
+	c8.soundTimer = rec.soundTimer
+	c8.frame = rec.frame
+	c8.waitVBlank = rec.waitVBlank
+	c8.polled = rec.polled
+	c8.lastPolled = rec.lastPolled
+	c8.rng = rec.rng
+	c8.running = true
+
+	h := c8.history
+	h.head = (h.head - 1 + len(h.records)) % len(h.records)
+	h.length--
+
+	return true
+}
+
+// Write describes an instruction from the history which modified a register or memory byte.
+type Write struct {
+	Address uint16 // Address of the instruction
+	Opcode  uint16 // The instruction
+	Frame   uint64 // Frame in which the instruction was executed
+	Age     int    // Number of instructions executed since, 0 for the most recent instruction
+}
+
+// LastRegisterWrite returns the most recent instruction in the history which wrote register VX.
+func (c8 *Chip8) LastRegisterWrite(x uint8) (Write, bool) {
+	return c8.lastWrite(func(rec *undoRecord) bool {
+		return rec.written&(1<<(x&0xF)) != 0
+	})
+}
+
+// LastIndexWrite returns the most recent instruction in the history which wrote the index register.
+func (c8 *Chip8) LastIndexWrite() (Write, bool) {
+	return c8.lastWrite(func(rec *undoRecord) bool {
+		return rec.written&indexWritten != 0
+	})
+}
+
+// LastMemoryWrite returns the most recent instruction in the history which wrote the memory byte at addr.
+func (c8 *Chip8) LastMemoryWrite(addr uint16) (Write, bool) {
+	return c8.lastWrite(func(rec *undoRecord) bool {
+		for _, w := range rec.memory {
+			if w.address == addr {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (c8 *Chip8) lastWrite(match func(rec *undoRecord) bool) (Write, bool) {
+	h := c8.history
+	if h == nil {
+		return Write{}, false
+	}
+
+	for age := range h.length {
+		rec := &h.records[(h.head-1-age+2*len(h.records))%len(h.records)]
+		if match(rec) {
+			return Write{Address: rec.address, Opcode: rec.opcode, Frame: rec.frame, Age: age}, true
+		}
+	}
+
+	return Write{}, false
+}
+
+// writtenRegisters returns which registers the current instruction wrote, using the layout of undoRecord.written.
+func (c8 *Chip8) writtenRegisters(address uint16) uint32 {
+	vx := uint32(1) << ((c8.opcode & 0x0F00) >> 8)
+	const vf = 1 << 0xF
+
+	switch c8.decodeOpcode() {
+	case 0x6000, 0x7000, 0x8000, 0xC000, 0xF007:
+		return vx
+	case 0x8001, 0x8002, 0x8003:
+		if c8.Quirks.VFReset {
+			return vx | vf
+		}
+		return vx
+	case 0x8004, 0x8005, 0x8006, 0x8007, 0x800E:
+		return vx | vf
+	case 0xD000:
+		return vf
+	case 0xF00A:
+		// The instruction only writes VX once a key was pressed, otherwise it is executed again.
+		if c8.programCounter == address {
+			return 0
+		}
+		return vx
+	case 0xA000, 0xF01E, 0xF029:
+		return indexWritten
+	case 0xF055, 0xF065:
+		written := uint32(indexWritten)
+		if c8.Quirks.Memory == MemoryUnchanged {
+			written = 0
+		}
+		if c8.decodeOpcode() == 0xF065 {
+			written |= vx<<1 - 1
+		}
+		return written
+	}
+
+	return 0
+}
@@ -0,0 +1,193 @@
+package chip8
+
+import (
+	"testing"
+)
+
+// newHistoryTestChip8 returns an initialized emulator with the given program loaded at the start address.
+func newHistoryTestChip8(program []byte, historySize int) *Chip8 {
+	c8 := &Chip8{}
+	c8.init()
+	copy(c8.memory[startAddress:], program)
+	c8.EnableHistory(historySize)
+	return c8
+}
+
+func TestStepBack(t *testing.T) {
+	program := []byte{
+		0x60, 0xFE, // LD V0, 0xFE
+		0x70, 0x03, // ADD V0, 0x03 (overflows)
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x33, // BCD V0
+		0x22, 0x0E, // CALL 0x20E
+		0x00, 0x00,
+		0x00, 0x00,
+		0xF0, 0x29, // LD F, V0
+		0xD0, 0x05, // DRW V0, V0, 5
+		0x00, 0xE0, // CLS
+		0xF1, 0x55, // LD [I], V1
+	}
+
+	c8 := newHistoryTestChip8(program, 64)
+	c8.display[31][63] = true
+	c8.memory[0x300] = 0xAA
+	want := *c8
+
+	for range 10 {
+		c8.cycle()
+	}
+
+	if c8.HistoryLen() != 10 {
+		t.Fatalf("Expected 10 instructions in the history but got %d", c8.HistoryLen())
+	}
+
+	t.Run("Step back to the first instruction", func(t *testing.T) {
+		for c8.StepBack() {
+		}
+
+		if c8.registers != want.registers {
+			t.Errorf("registers: got %v, want %v", c8.registers, want.registers)
+		}
+		if c8.memory != want.memory {
+			t.Errorf("memory differs from the initial state")
+		}
+		if c8.display != want.display {
+			t.Errorf("display differs from the initial state")
+		}
+		if c8.callStack != want.callStack || c8.stackPointer != want.stackPointer {
+			t.Errorf("call stack: got %v (SP=%d), want %v (SP=%d)", c8.callStack, c8.stackPointer, want.callStack, want.stackPointer)
+		}
+		if c8.programCounter != want.programCounter || c8.indexRegister != want.indexRegister {
+			t.Errorf("PC/I: got %#x/%#x, want %#x/%#x", c8.programCounter, c8.indexRegister, want.programCounter, want.indexRegister)
+		}
+	})
+
+	t.Run("Replaying reaches the same state", func(t *testing.T) {
+		for range 5 {
+			c8.cycle()
+		}
+		for range 2 {
+			c8.StepBack()
+		}
+		for range 2 {
+			c8.cycle()
+		}
+
+		if c8.programCounter != 0x20E || c8.stackPointer != 1 || c8.memory[0x302] != 1 {
+			t.Errorf("unexpected state after replay: PC=%#x SP=%d mem[0x302]=%d", c8.programCounter, c8.stackPointer, c8.memory[0x302])
+		}
+	})
+}
+
+func TestStepBackRestoresPolledKeys(t *testing.T) {
+	program := []byte{
+		0xE2, 0x9E, // SKP V2
+		0xE3, 0xA1, // SKNP V3
+	}
+
+	c8 := newHistoryTestChip8(program, 64)
+	c8.registers[2], c8.registers[3] = 0x2, 0x3
+	c8.cycle()
+	c8.cycle()
+
+	c8.StepBack()
+	if want := [16]bool{0x2: true}; c8.polled != want {
+		t.Errorf("Expected the keys polled before SKNP but got %v", c8.polled)
+	}
+	c8.StepBack()
+	if c8.polled != [16]bool{} {
+		t.Errorf("Expected no polled keys after stepping back over SKP but got %v", c8.polled)
+	}
+}
+
+func TestStepBackReplaysRandomNumbers(t *testing.T) {
+	program := []byte{
+		0xC0, 0xFF, // RND V0, 0xFF
+		0xC1, 0xFF, // RND V1, 0xFF
+		0xC2, 0xFF, // RND V2, 0xFF
+		0xC3, 0xFF, // RND V3, 0xFF
+	}
+
+	c8 := newHistoryTestChip8(program, 64)
+	c8.Seed(42)
+	for range 4 {
+		c8.cycle()
+	}
+	want := *c8
+
+	for range 3 {
+		c8.StepBack()
+	}
+	for range 3 {
+		c8.cycle()
+	}
+
+	if c8.registers != want.registers {
+		t.Errorf("registers: got %v, want %v", c8.registers, want.registers)
+	}
+	if c8.rng != want.rng {
+		t.Errorf("Expected the random number generator to match after replaying")
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	c8 := newHistoryTestChip8([]byte{0x70, 0x01, 0x12, 0x00}, 4) // ADD V0, 1; JP 0x200
+
+	for range 10 {
+		c8.cycle()
+	}
+
+	steps := 0
+	for c8.StepBack() {
+		steps++
+	}
+
+	if steps != 4 {
+		t.Errorf("Expected to step back 4 instructions but got %d", steps)
+	}
+	if c8.registers[0] != 3 {
+		t.Errorf("Expected V0 to be 3 after stepping back but got %d", c8.registers[0])
+	}
+}
+
+func TestLastWrite(t *testing.T) {
+	program := []byte{
+		0x63, 0x10, // 0x200: LD V3, 0x10
+		0xA3, 0x00, // 0x202: LD I, 0x300
+		0xF3, 0x55, // 0x204: LD [I], V3
+		0x64, 0x01, // 0x206: LD V4, 0x01
+		0xF0, 0x0A, // 0x208: LD V0, K (waits for a key)
+	}
+
+	c8 := newHistoryTestChip8(program, 16)
+	for range 6 {
+		c8.cycle()
+	}
+
+	tests := []struct {
+		testName    string
+		query       func() (Write, bool)
+		wantFound   bool
+		wantAddress uint16
+		wantAge     int
+	}{
+		{"V3 written by 0x200", func() (Write, bool) { return c8.LastRegisterWrite(3) }, true, 0x200, 5},
+		{"V4 written by 0x206", func() (Write, bool) { return c8.LastRegisterWrite(4) }, true, 0x206, 2},
+		{"V0 not written while waiting for a key", func() (Write, bool) { return c8.LastRegisterWrite(0) }, false, 0, 0},
+		{"I written by 0x204", func() (Write, bool) { return c8.LastIndexWrite() }, true, 0x204, 3},
+		{"Memory 0x303 written by 0x204", func() (Write, bool) { return c8.LastMemoryWrite(0x303) }, true, 0x204, 3},
+		{"Memory 0x304 never written", func() (Write, bool) { return c8.LastMemoryWrite(0x304) }, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			w, found := tt.query()
+			if found != tt.wantFound {
+				t.Fatalf("found: got %v, want %v", found, tt.wantFound)
+			}
+			if found && (w.Address != tt.wantAddress || w.Age != tt.wantAge) {
+				t.Errorf("got address %#x age %d, want address %#x age %d", w.Address, w.Age, tt.wantAddress, tt.wantAge)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package chip8
+
+// Quirks selects between the behaviours of the different CHIP-8 interpreters for the instructions
+// whose semantics changed over time. The zero value keeps the historic behaviour of this emulator.
+type Quirks struct {
+	VFReset bool        // 8XY1, 8XY2 and 8XY3 reset VF to zero (COSMAC VIP)
+	ShiftVY bool        // 8XY6 and 8XYE shift VY and store the result in VX instead of shifting VX in place
+	Memory  MemoryQuirk // How FX55 and FX65 change the index register
+	JumpVX  bool        // BNNN jumps to XNN + VX instead of NNN + V0 (SUPER-CHIP)
+	Clip    bool        // Sprites are clipped at the edges of the screen instead of wrapping around
+	VBlank  bool        // DXYN waits for the next frame before the execution continues (display wait)
+}
+
+// MemoryQuirk describes how FX55 and FX65 change the index register.
+type MemoryQuirk uint8
+
+const (
+	MemoryLegacy       MemoryQuirk = iota // I is set to X + 1, the historic behaviour of this emulator
+	MemoryIncrement                       // I is incremented by X + 1 (COSMAC VIP)
+	MemoryIncrementByX                    // I is incremented by X (CHIP-48)
+	MemoryUnchanged                       // I is left unchanged (SUPER-CHIP)
+)
+
+var memoryQuirkNames = map[MemoryQuirk]string{
+	MemoryLegacy:       "legacy",
+	MemoryIncrement:    "increment",
+	MemoryIncrementByX: "increment-by-x",
+	MemoryUnchanged:    "unchanged",
+}
+
+func (m MemoryQuirk) String() string {
+	return memoryQuirkNames[m]
+}
+
+// ParseMemoryQuirk returns the MemoryQuirk with the given name as returned by String.
+func ParseMemoryQuirk(name string) (MemoryQuirk, bool) {
+	for m, n := range memoryQuirkNames {
+		if n == name {
+			return m, true
+		}
+	}
+	return MemoryLegacy, false
+}
+
+// QuirkProfiles holds the quirks of the well-known CHIP-8 interpreters.
+var QuirkProfiles = map[string]Quirks{
+	"legacy": {},
+	"chip8":  {VFReset: true, ShiftVY: true, Memory: MemoryIncrement, Clip: true, VBlank: true},
+	"schip":  {Memory: MemoryUnchanged, JumpVX: true, Clip: true},
+	"xochip": {ShiftVY: true, Memory: MemoryIncrement},
+}
+
+// indexAfterLoadStore returns the index register after FX55 or FX65 transferred the registers V0 - VX.
+func (c8 *Chip8) indexAfterLoadStore(x uint16) uint16 {
+	switch c8.Quirks.Memory {
+	case MemoryIncrement:
+		return c8.indexRegister + x + 1
+	case MemoryIncrementByX:
+		return c8.indexRegister + x
+	case MemoryUnchanged:
+		return c8.indexRegister
+	default:
+		// Reference manual dependent
+		return x + 1
+	}
+}
@@ -0,0 +1,209 @@
+package chip8
+
+import (
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/font"
+)
+
+func TestQuirks(t *testing.T) {
+	tests := []struct {
+		testName string
+		quirks   Quirks
+		opcode   uint16
+		prepare  func(c8 *Chip8)
+		check    func(t *testing.T, c8 *Chip8)
+	}{
+		{
+			testName: "VFReset: OR resets VF",
+			quirks:   Quirks{VFReset: true},
+			opcode:   0x8011,
+			prepare:  func(c8 *Chip8) { c8.registers[0xF] = 1 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.registers[0xF] != 0 {
+					t.Errorf("Expected VF to be 0 but got %d", c8.registers[0xF])
+				}
+			},
+		},
+		{
+			testName: "ShiftVY: SHR shifts VY into VX",
+			quirks:   Quirks{ShiftVY: true},
+			opcode:   0x8126,
+			prepare:  func(c8 *Chip8) { c8.registers[1] = 0xFF; c8.registers[2] = 0x05 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.registers[1] != 0x02 || c8.registers[0xF] != 1 {
+					t.Errorf("Expected V1=0x02 VF=1 but got V1=%#x VF=%d", c8.registers[1], c8.registers[0xF])
+				}
+			},
+		},
+		{
+			testName: "ShiftVY: SHL shifts VY into VX",
+			quirks:   Quirks{ShiftVY: true},
+			opcode:   0x812E,
+			prepare:  func(c8 *Chip8) { c8.registers[1] = 0x01; c8.registers[2] = 0x81 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.registers[1] != 0x02 || c8.registers[0xF] != 1 {
+					t.Errorf("Expected V1=0x02 VF=1 but got V1=%#x VF=%d", c8.registers[1], c8.registers[0xF])
+				}
+			},
+		},
+		{
+			testName: "MemoryIncrement: FX55 increments I by X + 1",
+			quirks:   Quirks{Memory: MemoryIncrement},
+			opcode:   0xF255,
+			prepare:  func(c8 *Chip8) { c8.indexRegister = 0x300 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.indexRegister != 0x303 {
+					t.Errorf("Expected I to be 0x303 but got %#x", c8.indexRegister)
+				}
+			},
+		},
+		{
+			testName: "MemoryIncrementByX: FX65 increments I by X",
+			quirks:   Quirks{Memory: MemoryIncrementByX},
+			opcode:   0xF265,
+			prepare:  func(c8 *Chip8) { c8.indexRegister = 0x300 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.indexRegister != 0x302 {
+					t.Errorf("Expected I to be 0x302 but got %#x", c8.indexRegister)
+				}
+			},
+		},
+		{
+			testName: "MemoryUnchanged: FX55 leaves I unchanged",
+			quirks:   Quirks{Memory: MemoryUnchanged},
+			opcode:   0xF255,
+			prepare:  func(c8 *Chip8) { c8.indexRegister = 0x300 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.indexRegister != 0x300 {
+					t.Errorf("Expected I to be 0x300 but got %#x", c8.indexRegister)
+				}
+			},
+		},
+		{
+			testName: "JumpVX: BXNN jumps to XNN + VX",
+			quirks:   Quirks{JumpVX: true},
+			opcode:   0xB220,
+			prepare:  func(c8 *Chip8) { c8.registers[0] = 0x10; c8.registers[2] = 0x04 },
+			check: func(t *testing.T, c8 *Chip8) {
+				if c8.programCounter != 0x224 {
+					t.Errorf("Expected the program counter to be 0x224 but got %#x", c8.programCounter)
+				}
+			},
+		},
+		{
+			testName: "Clip: sprites are clipped at the right edge",
+			quirks:   Quirks{Clip: true},
+			opcode:   0xD011,
+			prepare: func(c8 *Chip8) {
+				c8.registers[0] = 60
+				c8.registers[1] = 0
+				c8.indexRegister = 0x300
+				c8.memory[0x300] = 0xFF
+			},
+			check: func(t *testing.T, c8 *Chip8) {
+				if !c8.display[0][63] || c8.display[0][0] {
+					t.Errorf("Expected the sprite to be clipped at the edge")
+				}
+			},
+		},
+		{
+			testName: "Clip: the start position still wraps around",
+			quirks:   Quirks{Clip: true},
+			opcode:   0xD011,
+			prepare: func(c8 *Chip8) {
+				c8.registers[0] = 64 + 2
+				c8.registers[1] = 32 + 1
+				c8.indexRegister = 0x300
+				c8.memory[0x300] = 0x80
+			},
+			check: func(t *testing.T, c8 *Chip8) {
+				if !c8.display[1][2] {
+					t.Errorf("Expected the pixel at (2, 1) to be set")
+				}
+			},
+		},
+		{
+			testName: "No Clip: sprites wrap around",
+			quirks:   Quirks{},
+			opcode:   0xD011,
+			prepare: func(c8 *Chip8) {
+				c8.registers[0] = 60
+				c8.registers[1] = 0
+				c8.indexRegister = 0x300
+				c8.memory[0x300] = 0xFF
+			},
+			check: func(t *testing.T, c8 *Chip8) {
+				if !c8.display[0][63] || !c8.display[0][3] {
+					t.Errorf("Expected the sprite to wrap around")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c8 := &Chip8{Quirks: tt.quirks}
+			c8.init()
+			tt.prepare(c8)
+			c8.memory[startAddress] = uint8(tt.opcode >> 8)
+			c8.memory[startAddress+1] = uint8(tt.opcode)
+
+			c8.cycle()
+
+			tt.check(t, c8)
+		})
+	}
+}
+
+func TestStepFrame(t *testing.T) {
+	program := []byte{
+		0x70, 0x01, // 0x200: ADD V0, 1
+		0xD1, 0x21, // 0x202: DRW V1, V2, 1
+		0x12, 0x00, // 0x204: JP 0x200
+	}
+
+	t.Run("Executes Tickrate instructions and decrements the timers", func(t *testing.T) {
+		c8 := &Chip8{Tickrate: 9}
+		if err := c8.Load(program); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		c8.delayTimer = 10
+
+		c8.StepFrame()
+
+		if c8.registers[0] != 3 || c8.delayTimer != 9 || c8.Frame() != 1 {
+			t.Errorf("Expected V0=3 DT=9 frame=1 but got V0=%d DT=%d frame=%d", c8.registers[0], c8.delayTimer, c8.Frame())
+		}
+	})
+
+	t.Run("VBlank ends the frame after drawing", func(t *testing.T) {
+		c8 := &Chip8{Tickrate: 9, Quirks: Quirks{VBlank: true}}
+		if err := c8.Load(program); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		c8.StepFrame()
+		c8.StepFrame()
+
+		if c8.registers[0] != 2 || c8.programCounter != 0x204 {
+			t.Errorf("Expected V0=2 PC=0x204 but got V0=%d PC=%#x", c8.registers[0], c8.programCounter)
+		}
+	})
+
+	t.Run("Load resets the previous state", func(t *testing.T) {
+		c8 := &Chip8{}
+		c8.registers[5] = 7
+		c8.display[3][3] = true
+		if err := c8.Load(program); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if c8.registers[5] != 0 || c8.display[3][3] || c8.programCounter != uint16(startAddress) || !c8.Running() {
+			t.Errorf("Expected a freshly initialized emulator")
+		}
+		if c8.memory[fontStartAddress] != font.Set[0] {
+			t.Errorf("Expected the font to be loaded")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// stateMagic starts every encoded save state, followed by stateVersion.
+const (
+	stateMagic   = "ACHT"
+	stateVersion = 1
+)
+
+// State is a snapshot of the complete machine state, which restores the emulator to the exact point
+// it was taken at. The configuration (Input, Renderer, Quirks, Tickrate...) is not part of it.
+type State struct {
+	registers      [16]uint8
+	memory         [4096]uint8
+	programCounter uint16
+	indexRegister  uint16
+	callStack      [16]uint16
+	stackPointer   uint8
+	opcode         uint16
+	keyPad         [16]bool
+	polled         [16]bool
+	lastPolled     [16]bool
+	delayTimer     uint8
+	soundTimer     uint8
+	display        [32][64]bool
+	waitVBlank     bool
+	frame          uint64
+	rng            uint64
+}
+
+// SaveState takes a snapshot of the machine state.
+func (c8 *Chip8) SaveState() *State {
+	return &State{
+		registers:      c8.registers,
+		memory:         c8.memory,
+		programCounter: c8.programCounter,
+		indexRegister:  c8.indexRegister,
+		callStack:      c8.callStack,
+		stackPointer:   c8.stackPointer,
+		opcode:         c8.opcode,
+		keyPad:         c8.keyPad,
+		polled:         c8.polled,
+		lastPolled:     c8.lastPolled,
+		delayTimer:     c8.delayTimer,
+		soundTimer:     c8.soundTimer,
+		display:        c8.display,
+		waitVBlank:     c8.waitVBlank,
+		frame:          c8.frame,
+		rng:            c8.rng,
+	}
+}
+
+// LoadState restores the machine state of a snapshot. The history is cleared, since the instructions
+// it records did not lead to the restored state.
+func (c8 *Chip8) LoadState(s *State) {
+	c8.registers = s.registers
+	c8.memory = s.memory
+	c8.programCounter = s.programCounter
+	c8.indexRegister = s.indexRegister
+	c8.callStack = s.callStack
+	c8.stackPointer = s.stackPointer
+	c8.opcode = s.opcode
+	c8.keyPad = s.keyPad
+	c8.polled = s.polled
+	c8.lastPolled = s.lastPolled
+	c8.delayTimer = s.delayTimer
+	c8.soundTimer = s.soundTimer
+	c8.display = s.display
+	c8.waitVBlank = s.waitVBlank
+	c8.frame = s.frame
+	c8.rng = s.rng
+	c8.seeded = true
+	c8.running = true
+	if c8.history != nil {
+		c8.EnableHistory(len(c8.history.records))
+	}
+}
+
+// Frame returns the number of the frame the state was taken at.
+func (s *State) Frame() uint64 {
+	return s.frame
+}
+
+// fields returns pointers to the fields in the order they are encoded.
+func (s *State) fields() []any {
+	return []any{
+		&s.registers, &s.memory, &s.programCounter, &s.indexRegister, &s.callStack, &s.stackPointer,
+		&s.opcode, &s.keyPad, &s.polled, &s.lastPolled, &s.delayTimer, &s.soundTimer, &s.display,
+		&s.waitVBlank, &s.frame, &s.rng,
+	}
+}
+
+// MarshalBinary encodes the state, e.g. to send it over the network or to save it to a file.
+func (s *State) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(stateMagic)
+	buf.WriteByte(stateVersion)
+	for _, field := range s.fields() {
+		if err := binary.Write(&buf, binary.BigEndian, field); err != nil {
+			return nil, fmt.Errorf("could not encode the state: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a state encoded by MarshalBinary.
+func (s *State) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(stateMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(stateMagic)]) != stateMagic {
+		return fmt.Errorf("not a save state")
+	}
+	if header[len(stateMagic)] != stateVersion {
+		return fmt.Errorf("unsupported save state version: %d", header[len(stateMagic)])
+	}
+	for _, field := range s.fields() {
+		if err := binary.Read(r, binary.BigEndian, field); err != nil {
+			return fmt.Errorf("the save state is truncated")
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("the save state has %d trailing bytes", r.Len())
+	}
+	return nil
+}
+
+// Hash returns a hash of the state. Two emulators which ran alike have the same hash at the same frame.
+func (s *State) Hash() uint64 {
+	h := fnv.New64a()
+	for _, field := range s.fields() {
+		binary.Write(h, binary.BigEndian, field)
+	}
+	return h.Sum64()
+}
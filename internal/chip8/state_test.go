@@ -0,0 +1,114 @@
+package chip8
+
+import "testing"
+
+// randomDots draws dots at random positions, so that the display depends on the random numbers.
+var randomDots = []byte{
+	0xA2, 0x0C, // 0x200: LD I, 0x20C
+	0xC0, 0x3F, // 0x202: RND V0, 0x3F
+	0xC1, 0x1F, // 0x204: RND V1, 0x1F
+	0xD0, 0x11, // 0x206: DRW V0, V1, 1
+	0x12, 0x02, // 0x208: JP 0x202
+	0x00, 0x00, // 0x20A: padding
+	0x80, // 0x20C: sprite of a single pixel
+}
+
+func TestSeed(t *testing.T) {
+	run := func(seed uint64) [32][64]bool {
+		c8 := &Chip8{}
+		c8.Seed(seed)
+		if err := c8.Load(randomDots); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for range 10 {
+			c8.StepFrame()
+		}
+		return c8.Display()
+	}
+
+	if run(42) != run(42) {
+		t.Errorf("Expected the same display with the same seed")
+	}
+	if run(42) == run(43) {
+		t.Errorf("Expected different displays with different seeds")
+	}
+}
+
+func TestState(t *testing.T) {
+	c8 := &Chip8{}
+	c8.Seed(1)
+	if err := c8.Load(randomDots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range 5 {
+		c8.StepFrame()
+	}
+
+	state := c8.SaveState()
+	for range 5 {
+		c8.StepFrame()
+	}
+	want, wantHash := c8.Display(), c8.SaveState().Hash()
+
+	t.Run("LoadState restores the state", func(t *testing.T) {
+		c8.LoadState(state)
+		if c8.Frame() != 5 {
+			t.Errorf("Expected frame 5 but got %d", c8.Frame())
+		}
+		for range 5 {
+			c8.StepFrame()
+		}
+		if c8.Display() != want || c8.SaveState().Hash() != wantHash {
+			t.Errorf("Expected the same frames after loading the state")
+		}
+	})
+
+	t.Run("MarshalBinary and UnmarshalBinary", func(t *testing.T) {
+		data, err := state.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded State
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoded != *state {
+			t.Errorf("Expected the decoded state to equal the encoded one")
+		}
+		if decoded.Hash() != state.Hash() {
+			t.Errorf("Expected the same hash")
+		}
+		if state.Hash() == wantHash {
+			t.Errorf("Expected different states to have different hashes")
+		}
+	})
+}
+
+func TestUnmarshalStateInvalid(t *testing.T) {
+	data, err := (&State{}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	version := append([]byte{}, data...)
+	version[4] = 99
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no save state", []byte("GIF89a")},
+		{"unknown version", version},
+		{"truncated", data[:len(data)-1]},
+		{"trailing bytes", append(append([]byte{}, data...), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s State
+			if err := s.UnmarshalBinary(tt.data); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
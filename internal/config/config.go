@@ -0,0 +1,319 @@
+// Package config holds the settings of the emulator, which are read from a JSON configuration file.
+//
+// The effective configuration is built from layers, each one overriding the settings set by the previous
+// ones: the defaults, the global section of the configuration file, the recommendations of the ROM database
+// or of a cartridge, the section of the configuration file for the SHA-1 hash of the ROM and finally the
+// flags given on the command line. Settings which are left empty in a layer are inherited.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/achievements"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// Config holds the settings of the emulator. A configuration file holds the global settings and
+// optionally settings for single ROMs in ROMs.
+type Config struct {
+	Quirks       *Quirks                    `json:"quirks,omitempty"`
+	Tickrate     int                        `json:"tickrate,omitempty"`     // Instructions per frame
+	ColorProfile string                     `json:"colorProfile,omitempty"` // Name of a color profile
+	Palette      []string                   `json:"palette,omitempty"`      // 2 or 4 colors as hex strings, background first; replaces the color profile
+	Buttons      map[string]uint8           `json:"buttons,omitempty"`      // CHIP-8 keys of the buttons up, down, left, right, a, b, player2Up, ...
+	Keyboard     map[string][]string        `json:"keyboard,omitempty"`     // Host keys of the CHIP-8 keys 0 - F, replacing the default layout of these keys
+	Controller   map[string][]string        `json:"controller,omitempty"`   // Game controller buttons and axes of the CHIP-8 keys 0 - F, like Keyboard
+	Scale        int                        `json:"scale,omitempty"`        // Initial size of a CHIP-8 pixel in the window
+	Scaling      string                     `json:"scaling,omitempty"`      // aspect to fill the window keeping the aspect ratio, integer to scale by whole multiples
+	Filters      []string                   `json:"filters,omitempty"`      // Post-processing filters applied in order, e.g. ghosting, scale2x, scanlines, crt
+	Keypad       *bool                      `json:"keypad,omitempty"`       // Shows the clickable hex keypad next to the display
+	Audio        *Audio                     `json:"audio,omitempty"`
+	Paths        *Paths                     `json:"paths,omitempty"`
+	Achievements []achievements.Achievement `json:"achievements,omitempty"` // Achievements of a ROM, replacing the ones of the ROM database with the same ID
+	ROMs         map[string]*Config         `json:"roms,omitempty"`         // Settings for single ROMs keyed by their SHA-1 hash
+}
+
+// Quirks selects a quirk profile and optionally overrides single quirks of it.
+type Quirks struct {
+	Profile string `json:"profile,omitempty"` // legacy, chip8, schip, xochip or auto to detect the quirks
+	VFReset *bool  `json:"vfReset,omitempty"`
+	ShiftVY *bool  `json:"shiftVY,omitempty"`
+	Memory  string `json:"memory,omitempty"` // legacy, increment, increment-by-x or unchanged
+	JumpVX  *bool  `json:"jumpVX,omitempty"`
+	Clip    *bool  `json:"clip,omitempty"`
+	VBlank  *bool  `json:"vblank,omitempty"`
+}
+
+// Audio holds the settings of the sound output.
+type Audio struct {
+	Enabled   *bool   `json:"enabled,omitempty"`
+	Volume    float64 `json:"volume,omitempty"`    // From 0 to 1
+	Frequency int     `json:"frequency,omitempty"` // Frequency of the tone in Hz
+}
+
+// Paths holds the directories the emulator reads from.
+type Paths struct {
+	ROMs         string `json:"roms,omitempty"`         // Directory which is searched for ROMs given by a relative path
+	Database     string `json:"database,omitempty"`     // Directory with the community CHIP-8 database, the bundled one if empty
+	Palettes     string `json:"palettes,omitempty"`     // Directory with palette files, which become available as color profiles
+	Screenshots  string `json:"screenshots,omitempty"`  // Directory screenshots and recordings are saved to, the current directory if empty
+	Achievements string `json:"achievements,omitempty"` // File the unlocked achievements are kept in, achievements.json next to the default configuration file if empty
+	Cheats       string `json:"cheats,omitempty"`       // Directory with the cheat files of the ROMs, cheats next to the default configuration file if empty
+}
+
+// Default returns the default settings.
+func Default() *Config {
+	enabled := true
+	return &Config{
+		Quirks:       &Quirks{Profile: "legacy"},
+		Tickrate:     chip8.DefaultTickrate,
+		ColorProfile: "black-white",
+		Scale:        20,
+		Scaling:      "aspect",
+		Audio:        &Audio{Enabled: &enabled, Volume: 0.25, Frequency: 440},
+		Paths:        &Paths{},
+	}
+}
+
+// DefaultPath returns the path of the configuration file which is used if no other one is given.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-acht", "config.json"), nil
+}
+
+// Load reads the configuration file at path. If optional is set, a missing file results in an
+// empty configuration instead of an error.
+func Load(path string, optional bool) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration file: %w", err)
+	}
+
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("could not parse configuration file %s: %w", path, err)
+	}
+
+	// The hashes are looked up in lower case.
+	roms := make(map[string]*Config, len(c.ROMs))
+	for hash, rom := range c.ROMs {
+		roms[strings.ToLower(hash)] = rom
+	}
+	c.ROMs = roms
+
+	return c, nil
+}
+
+// ForROM returns the settings for the ROM with the given SHA-1 hash, nil if there are none.
+func (c *Config) ForROM(hash string) *Config {
+	return c.ROMs[strings.ToLower(hash)]
+}
+
+// Merge overrides the settings of c with the settings set in o. The per-ROM settings are not merged.
+func (c *Config) Merge(o *Config) {
+	if o == nil {
+		return
+	}
+
+	if o.Quirks != nil {
+		if c.Quirks == nil || o.Quirks.Profile != "" {
+			// Selecting a profile replaces the quirks inherited from the previous layers.
+			c.Quirks = &Quirks{}
+		}
+		c.Quirks.merge(o.Quirks)
+	}
+	if o.Tickrate != 0 {
+		c.Tickrate = o.Tickrate
+	}
+	if o.ColorProfile != "" || len(o.Palette) > 0 {
+		c.ColorProfile, c.Palette = o.ColorProfile, o.Palette
+	}
+	if len(o.Buttons) > 0 {
+		buttons := make(map[string]uint8, len(c.Buttons)+len(o.Buttons))
+		for button, key := range c.Buttons {
+			buttons[button] = key
+		}
+		for button, key := range o.Buttons {
+			buttons[button] = key
+		}
+		c.Buttons = buttons
+	}
+	if len(o.Keyboard) > 0 {
+		c.Keyboard = mergeBindings(c.Keyboard, o.Keyboard)
+	}
+	if len(o.Controller) > 0 {
+		c.Controller = mergeBindings(c.Controller, o.Controller)
+	}
+	if o.Scale != 0 {
+		c.Scale = o.Scale
+	}
+	if o.Scaling != "" {
+		c.Scaling = o.Scaling
+	}
+	if len(o.Filters) > 0 {
+		c.Filters = o.Filters
+	}
+	if o.Keypad != nil {
+		c.Keypad = o.Keypad
+	}
+	if o.Audio != nil {
+		if c.Audio == nil {
+			c.Audio = &Audio{}
+		}
+		c.Audio.merge(o.Audio)
+	}
+	if o.Paths != nil {
+		if c.Paths == nil {
+			c.Paths = &Paths{}
+		}
+		c.Paths.merge(o.Paths)
+	}
+	if len(o.Achievements) > 0 {
+		c.Achievements = mergeAchievements(c.Achievements, o.Achievements)
+	}
+}
+
+func (q *Quirks) merge(o *Quirks) {
+	if o.Profile != "" {
+		q.Profile = o.Profile
+	}
+	if o.Memory != "" {
+		q.Memory = o.Memory
+	}
+	for _, f := range []struct{ dst, src **bool }{
+		{&q.VFReset, &o.VFReset}, {&q.ShiftVY, &o.ShiftVY}, {&q.JumpVX, &o.JumpVX}, {&q.Clip, &o.Clip}, {&q.VBlank, &o.VBlank},
+	} {
+		if *f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+}
+
+func (a *Audio) merge(o *Audio) {
+	if o.Enabled != nil {
+		a.Enabled = o.Enabled
+	}
+	if o.Volume != 0 {
+		a.Volume = o.Volume
+	}
+	if o.Frequency != 0 {
+		a.Frequency = o.Frequency
+	}
+}
+
+func (p *Paths) merge(o *Paths) {
+	if o.ROMs != "" {
+		p.ROMs = o.ROMs
+	}
+	if o.Database != "" {
+		p.Database = o.Database
+	}
+	if o.Palettes != "" {
+		p.Palettes = o.Palettes
+	}
+	if o.Screenshots != "" {
+		p.Screenshots = o.Screenshots
+	}
+	if o.Achievements != "" {
+		p.Achievements = o.Achievements
+	}
+	if o.Cheats != "" {
+		p.Cheats = o.Cheats
+	}
+}
+
+// mergeAchievements returns the achievements of c followed by the ones of o, which replace the
+// achievements of c with the same ID.
+func mergeAchievements(c, o []achievements.Achievement) []achievements.Achievement {
+	merged := make([]achievements.Achievement, 0, len(c)+len(o))
+	for _, a := range c {
+		if !slices.ContainsFunc(o, func(b achievements.Achievement) bool { return b.ID == a.ID }) {
+			merged = append(merged, a)
+		}
+	}
+	return append(merged, o...)
+}
+
+// mergeBindings returns the bindings of c with the CHIP-8 keys bound by o replaced.
+func mergeBindings(c, o map[string][]string) map[string][]string {
+	bindings := make(map[string][]string, len(c)+len(o))
+	for key, hostKeys := range c {
+		bindings[key] = hostKeys
+	}
+	for key, hostKeys := range o {
+		bindings[strings.ToUpper(key)] = hostKeys
+	}
+	return bindings
+}
+
+// QuirksOf returns the configuration which sets all quirks to the given ones. It is based on the
+// legacy profile, which does not change any quirk.
+func QuirksOf(q chip8.Quirks) *Quirks {
+	return &Quirks{
+		Profile: "legacy",
+		VFReset: &q.VFReset,
+		ShiftVY: &q.ShiftVY,
+		Memory:  q.Memory.String(),
+		JumpVX:  &q.JumpVX,
+		Clip:    &q.Clip,
+		VBlank:  &q.VBlank,
+	}
+}
+
+// Resolve returns the quirks of the profile with the single quirks applied. The profile auto is
+// resolved by calling detect, no profile keeps the legacy behaviour.
+func (q *Quirks) Resolve(detect func() (chip8.Quirks, error)) (chip8.Quirks, error) {
+	var quirks chip8.Quirks
+
+	switch q.Profile {
+	case "":
+	case "auto":
+		detected, err := detect()
+		if err != nil {
+			return quirks, err
+		}
+		quirks = detected
+	default:
+		profile, found := chip8.QuirkProfiles[q.Profile]
+		if !found {
+			return quirks, fmt.Errorf("no such quirk profile: %s", q.Profile)
+		}
+		quirks = profile
+	}
+
+	if q.Memory != "" {
+		memory, found := chip8.ParseMemoryQuirk(q.Memory)
+		if !found {
+			return quirks, fmt.Errorf("no such memory quirk: %s", q.Memory)
+		}
+		quirks.Memory = memory
+	}
+	for _, f := range []struct{ dst, src *bool }{
+		{&quirks.VFReset, q.VFReset}, {&quirks.ShiftVY, q.ShiftVY}, {&quirks.JumpVX, q.JumpVX}, {&quirks.Clip, q.Clip}, {&quirks.VBlank, q.VBlank},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+
+	return quirks, nil
+}
+
+// Colors returns the palette given by Palette.
+func (c *Config) Colors() (renderer.Palette, error) {
+	return renderer.ParsePalette(c.Palette)
+}
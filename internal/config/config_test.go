@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/achievements"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+func TestMerge(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		testName string
+		layers   []*Config
+		check    func(t *testing.T, c *Config)
+	}{
+		{
+			testName: "Empty layers keep the defaults",
+			layers:   []*Config{nil, {}},
+			check: func(t *testing.T, c *Config) {
+				if c.Tickrate != chip8.DefaultTickrate || c.ColorProfile != "black-white" || c.Quirks.Profile != "legacy" {
+					t.Errorf("Expected the defaults but got %+v", c)
+				}
+			},
+		},
+		{
+			testName: "Later layers take precedence",
+			layers:   []*Config{{Tickrate: 10, Scale: 5}, {Tickrate: 30}},
+			check: func(t *testing.T, c *Config) {
+				if c.Tickrate != 30 || c.Scale != 5 {
+					t.Errorf("Expected tickrate 30 and scale 5 but got %d and %d", c.Tickrate, c.Scale)
+				}
+			},
+		},
+		{
+			testName: "A palette replaces the color profile and vice versa",
+			layers:   []*Config{{Palette: []string{"#000000", "#FFFFFF"}}, {ColorProfile: "honey"}},
+			check: func(t *testing.T, c *Config) {
+				if c.ColorProfile != "honey" || c.Palette != nil {
+					t.Errorf("Expected the color profile honey without palette but got %s and %v", c.ColorProfile, c.Palette)
+				}
+			},
+		},
+		{
+			testName: "Single quirks override the inherited profile",
+			layers:   []*Config{{Quirks: &Quirks{Profile: "chip8"}}, {Quirks: &Quirks{VBlank: &no}}},
+			check: func(t *testing.T, c *Config) {
+				want := chip8.QuirkProfiles["chip8"]
+				want.VBlank = false
+				if q, _ := c.Quirks.Resolve(nil); q != want {
+					t.Errorf("Expected the quirks %+v but got %+v", want, q)
+				}
+			},
+		},
+		{
+			testName: "A profile replaces the inherited single quirks",
+			layers:   []*Config{{Quirks: &Quirks{Clip: &yes}}, {Quirks: &Quirks{Profile: "xochip"}}},
+			check: func(t *testing.T, c *Config) {
+				if q, _ := c.Quirks.Resolve(nil); q != chip8.QuirkProfiles["xochip"] {
+					t.Errorf("Expected the quirks %+v but got %+v", chip8.QuirkProfiles["xochip"], q)
+				}
+			},
+		},
+		{
+			testName: "Buttons and audio settings are merged",
+			layers:   []*Config{{Buttons: map[string]uint8{"up": 1, "down": 4}, Audio: &Audio{Enabled: &no}}, {Buttons: map[string]uint8{"up": 2}, Audio: &Audio{Volume: 0.5}}},
+			check: func(t *testing.T, c *Config) {
+				if c.Buttons["up"] != 2 || c.Buttons["down"] != 4 {
+					t.Errorf("Expected up=2 down=4 but got %v", c.Buttons)
+				}
+				if *c.Audio.Enabled || c.Audio.Volume != 0.5 || c.Audio.Frequency != 440 {
+					t.Errorf("Expected disabled audio with volume 0.5 and 440 Hz but got %+v", c.Audio)
+				}
+			},
+		},
+		{
+			testName: "Keyboard bindings are replaced per CHIP-8 key",
+			layers:   []*Config{{Keyboard: map[string][]string{"5": {"W", "Up"}, "8": {"S"}}}, {Keyboard: map[string][]string{"5": {"key:Z"}}}},
+			check: func(t *testing.T, c *Config) {
+				if len(c.Keyboard["5"]) != 1 || c.Keyboard["5"][0] != "key:Z" || c.Keyboard["8"][0] != "S" {
+					t.Errorf("Expected 5=[key:Z] 8=[S] but got %v", c.Keyboard)
+				}
+			},
+		},
+		{
+			testName: "Controller bindings are replaced per CHIP-8 key",
+			layers:   []*Config{{Controller: map[string][]string{"a": {"a"}, "5": {"dpup"}}}, {Controller: map[string][]string{"A": {"x", "lefttrigger"}}}},
+			check: func(t *testing.T, c *Config) {
+				if len(c.Controller["A"]) != 2 || c.Controller["5"][0] != "dpup" || len(c.Controller) != 2 {
+					t.Errorf("Expected A=[x lefttrigger] 5=[dpup] but got %v", c.Controller)
+				}
+			},
+		},
+		{
+			testName: "Achievements are replaced by ID",
+			layers: []*Config{
+				{Achievements: []achievements.Achievement{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}}},
+				{Achievements: []achievements.Achievement{{ID: "b", Title: "Better B"}, {ID: "c", Title: "C"}}},
+			},
+			check: func(t *testing.T, c *Config) {
+				var titles []string
+				for _, a := range c.Achievements {
+					titles = append(titles, a.Title)
+				}
+				if fmt.Sprint(titles) != "[A Better B C]" {
+					t.Errorf("Expected the achievements [A Better B C] but got %v", titles)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c := Default()
+			for _, layer := range tt.layers {
+				c.Merge(layer)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Run("Auto calls the detection", func(t *testing.T) {
+		detected := chip8.QuirkProfiles["schip"]
+		q, err := (&Quirks{Profile: "auto"}).Resolve(func() (chip8.Quirks, error) { return detected, nil })
+		if err != nil || q != detected {
+			t.Errorf("Expected the detected quirks but got %+v, %v", q, err)
+		}
+	})
+
+	t.Run("Unknown profile", func(t *testing.T) {
+		if _, err := (&Quirks{Profile: "unknown"}).Resolve(nil); err == nil {
+			t.Errorf("Expected an error for an unknown profile")
+		}
+	})
+
+	t.Run("QuirksOf round trip", func(t *testing.T) {
+		want := chip8.Quirks{ShiftVY: true, Memory: chip8.MemoryIncrementByX, Clip: true}
+		if q, err := QuirksOf(want).Resolve(nil); err != nil || q != want {
+			t.Errorf("Expected the quirks %+v but got %+v, %v", want, q, err)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"tickrate": 12, "roms": {"AB12": {"tickrate": 30}}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := Load(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Tickrate != 12 {
+		t.Errorf("Expected the tickrate 12 but got %d", c.Tickrate)
+	}
+	if rom := c.ForROM("ab12"); rom == nil || rom.Tickrate != 30 {
+		t.Errorf("Expected the per-ROM settings to be found regardless of the case of the hash")
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json"), true); err != nil {
+		t.Errorf("Expected a missing optional file to be no error but got %v", err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json"), false); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
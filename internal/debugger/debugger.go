@@ -0,0 +1,498 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/cheats"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// Kind distinguishes the different points a Debugger can evaluate.
+type Kind int
+
+const (
+	Breakpoint Kind = iota // Pauses the emulator when the expression becomes true
+	Watchpoint             // Pauses the emulator when the value of the expression changes
+	Logpoint               // Prints a message when the expression is true without pausing
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Breakpoint:
+		return "break"
+	case Watchpoint:
+		return "watch"
+	default:
+		return "log"
+	}
+}
+
+// Point is a conditional breakpoint, watchpoint or log-point.
+type Point struct {
+	ID      int
+	Kind    Kind
+	Expr    *Expr
+	Message string // Log-points only. Placeholders like {V3} or {mem[I]:x} are replaced by the value of the expression.
+	Hits    int
+
+	last   int64 // Holds the value of the previous evaluation, not used by log-points.
+	primed bool  // Indicates whether last holds a value, not used by log-points.
+	failed bool  // Indicates whether the previous evaluation failed.
+}
+
+// Debugger evaluates breakpoints, watchpoints and log-points after each instruction of the emulator
+// and executes the commands of an interactive console.
+type Debugger struct {
+	Cheats    *cheats.Engine // Cheats the freeze and cheat commands work on, nil disables them
+	CheatFile string         // File the cheat save command writes to
+
+	out    io.Writer
+	points []*Point
+	nextID int
+	lines  chan string
+	search *cheats.Search
+}
+
+// New creates a Debugger which writes its messages to out.
+func New(out io.Writer) *Debugger {
+	return &Debugger{out: out, nextID: 1, lines: make(chan string, 16)}
+}
+
+// Add registers a new point with the given expression and returns it.
+// The message is only used by log-points.
+func (d *Debugger) Add(kind Kind, src string, message string) (*Point, error) {
+	expr, err := Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+
+	p := &Point{ID: d.nextID, Kind: kind, Expr: expr, Message: message}
+	d.nextID++
+	d.points = append(d.points, p)
+
+	return p, nil
+}
+
+// Remove deletes the point with the given id and reports whether it existed.
+func (d *Debugger) Remove(id int) bool {
+	for i, p := range d.points {
+		if p.ID == id {
+			d.points = append(d.points[:i], d.points[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Points returns all registered points in the order they were added.
+func (d *Debugger) Points() []*Point {
+	return d.points
+}
+
+// AfterCycle evaluates all points against the emulator state and pauses the emulator if a
+// breakpoint or watchpoint fired.
+func (d *Debugger) AfterCycle(c8 *chip8.Chip8) {
+	if d.check(c8, true) {
+		c8.Pause()
+	}
+}
+
+// check evaluates all points and reports whether the emulation should be stopped.
+// Breakpoints fire when their expression becomes true and an expression which cannot be
+// evaluated stops the emulation when it starts failing, so that the emulation can be continued.
+// Log-points are skipped if logs is false, which is used while executing backwards.
+func (d *Debugger) check(m Machine, logs bool) (stop bool) {
+	for _, p := range d.points {
+		v, err := p.Expr.Eval(m)
+		if err != nil {
+			if !p.failed {
+				fmt.Fprintf(d.out, "%s #%d: %s: %v\n", p.Kind, p.ID, p.Expr, err)
+				stop = true
+			}
+			p.failed = true
+			continue
+		}
+		p.failed = false
+
+		switch p.Kind {
+		case Breakpoint:
+			if v != 0 && (!p.primed || p.last == 0) {
+				p.Hits++
+				fmt.Fprintf(d.out, "break #%d hit: %s (PC=%#03x, frame %d)\n", p.ID, p.Expr, m.ProgramCounter(), m.Frame())
+				stop = true
+			}
+			p.last, p.primed = v, true
+		case Watchpoint:
+			if p.primed && v != p.last {
+				p.Hits++
+				fmt.Fprintf(d.out, "watch #%d: %s changed from %d to %d (PC=%#03x, frame %d)\n", p.ID, p.Expr, p.last, v, m.ProgramCounter(), m.Frame())
+				stop = true
+			}
+			p.last, p.primed = v, true
+		case Logpoint:
+			if v != 0 && logs {
+				p.Hits++
+				fmt.Fprintf(d.out, "log #%d (PC=%#03x, frame %d): %s\n", p.ID, m.ProgramCounter(), m.Frame(), d.format(p, m))
+			}
+		}
+	}
+
+	return stop
+}
+
+var placeholder = regexp.MustCompile(`\{([^{}:]+)(:x)?\}`)
+
+// prime stores the current values of all breakpoints and watchpoints, so that they only fire on
+// changes made after this call. It is used after the execution was reverted.
+func (d *Debugger) prime(m Machine) {
+	for _, p := range d.points {
+		if p.Kind == Logpoint {
+			continue
+		}
+		v, err := p.Expr.Eval(m)
+		p.failed = err != nil
+		if err == nil {
+			p.last, p.primed = v, true
+		}
+	}
+}
+
+// format replaces the placeholders of a log-point message with the current values.
+// Without a message the expression itself is printed.
+func (d *Debugger) format(p *Point, m Machine) string {
+	if p.Message == "" {
+		return p.Expr.String()
+	}
+
+	return placeholder.ReplaceAllStringFunc(p.Message, func(s string) string {
+		match := placeholder.FindStringSubmatch(s)
+		expr, err := Compile(match[1])
+		if err != nil {
+			return "<" + err.Error() + ">"
+		}
+		v, err := expr.Eval(m)
+		if err != nil {
+			return "<" + err.Error() + ">"
+		}
+		if match[2] != "" {
+			return fmt.Sprintf("%#x", v)
+		}
+		return fmt.Sprint(v)
+	})
+}
+
+// Console reads debugger commands line by line from r. The commands are executed by Update
+// on the emulation goroutine, so Console can safely be fed from standard input.
+func (d *Debugger) Console(r io.Reader) {
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			d.lines <- scanner.Text()
+		}
+	}()
+}
+
+// Update executes all pending console commands.
+func (d *Debugger) Update(c8 *chip8.Chip8) {
+	for {
+		select {
+		case line := <-d.lines:
+			d.Exec(c8, line)
+		default:
+			return
+		}
+	}
+}
+
+const help = `commands:
+  break|b <expr>            pause when expr becomes true, e.g. break PC == 0x2A0
+  watch|w <expr>            pause when the value of expr changes
+  log|l <expr> ["message"]  print message when expr is true, {expr} and {expr:x} are substituted
+  delete|d <id>             remove a break-, watch- or log-point
+  list                      list all points
+  continue|c                resume the emulation
+  pause                     pause the emulation
+  step|s [n]                execute n instructions (default 1)
+  rstep|rs [n]              revert n instructions (default 1), requires -history
+  rcontinue|rc              revert instructions until a break- or watchpoint fires
+  who <V0-VF|I|addr>        print the instruction which last wrote a register or memory byte
+  print|p <expr>            print the value of expr
+  regs|r                    print the registers
+  search [eq|ne|inc|dec|n]  start a memory search, or keep the bytes which are equal, changed,
+                            increased or decreased since the last search or which hold n
+  freeze <addr> [value]     keep the byte at addr at value, by default its current one
+  cheat [on|off|delete <n>] list the cheats, or enable, disable or delete cheat n
+  cheat save                save the cheats to the cheat file of the ROM`
+
+// Exec executes a single console command.
+func (d *Debugger) Exec(c8 *chip8.Chip8, line string) {
+	cmd, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	args = strings.TrimSpace(args)
+
+	switch cmd {
+	case "":
+	case "break", "b":
+		d.addFromConsole(Breakpoint, args, "")
+	case "watch", "w":
+		d.addFromConsole(Watchpoint, args, "")
+	case "log", "l":
+		src, message := args, ""
+		if i := strings.IndexByte(args, '"'); i >= 0 {
+			src, message = strings.TrimSpace(args[:i]), strings.Trim(args[i:], `"`)
+		}
+		d.addFromConsole(Logpoint, src, message)
+	case "delete", "d":
+		var id int
+		if _, err := fmt.Sscan(args, &id); err != nil || !d.Remove(id) {
+			fmt.Fprintf(d.out, "no such point: %s\n", args)
+		}
+	case "list":
+		for _, p := range d.points {
+			fmt.Fprintf(d.out, "#%d %s %s %q (%d hits)\n", p.ID, p.Kind, p.Expr, p.Message, p.Hits)
+		}
+	case "continue", "c":
+		c8.Resume()
+	case "pause":
+		c8.Pause()
+		d.printState(c8)
+	case "step", "s":
+		n, ok := d.count(args)
+		if !ok {
+			return
+		}
+		c8.Pause()
+		for range n {
+			c8.Step()
+		}
+		d.printState(c8)
+	case "rstep", "rs":
+		n, ok := d.count(args)
+		if !ok {
+			return
+		}
+		c8.Pause()
+		for range n {
+			if !c8.StepBack() {
+				fmt.Fprintln(d.out, "reached the beginning of the recorded history")
+				break
+			}
+		}
+		d.prime(c8)
+		d.printState(c8)
+	case "rcontinue", "rc":
+		c8.Pause()
+		d.reverseContinue(c8)
+		d.printState(c8)
+	case "who":
+		d.who(c8, args)
+	case "print", "p":
+		expr, err := Compile(args)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			return
+		}
+		v, err := expr.Eval(c8)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			return
+		}
+		fmt.Fprintf(d.out, "%s = %d (%#x)\n", expr, v, v)
+	case "regs", "r":
+		d.printState(c8)
+	case "search":
+		d.searchMemory(c8, args)
+	case "freeze":
+		d.freeze(c8, args)
+	case "cheat":
+		d.cheat(args)
+	case "help", "h":
+		fmt.Fprintln(d.out, help)
+	default:
+		fmt.Fprintf(d.out, "unknown command %q, type help for a list of commands\n", cmd)
+	}
+}
+
+// count parses the optional instruction count of the step commands.
+func (d *Debugger) count(args string) (int, bool) {
+	n := 1
+	if args != "" {
+		if _, err := fmt.Sscan(args, &n); err != nil || n < 1 {
+			fmt.Fprintf(d.out, "invalid step count: %s\n", args)
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// reverseContinue reverts instructions until a breakpoint or watchpoint fires or the history is exhausted.
+func (d *Debugger) reverseContinue(c8 *chip8.Chip8) {
+	d.prime(c8)
+	for c8.StepBack() {
+		if d.check(c8, false) {
+			d.prime(c8)
+			return
+		}
+	}
+	d.prime(c8)
+	fmt.Fprintln(d.out, "reached the beginning of the recorded history")
+}
+
+// who prints the instruction from the history which last wrote the given register or memory byte.
+func (d *Debugger) who(c8 *chip8.Chip8, target string) {
+	var w chip8.Write
+	var found bool
+
+	name := strings.ToUpper(strings.TrimSpace(target))
+	switch {
+	case name == "I":
+		w, found = c8.LastIndexWrite()
+	case len(name) == 2 && name[0] == 'V':
+		x, err := strconv.ParseUint(name[1:], 16, 4)
+		if err != nil {
+			fmt.Fprintf(d.out, "invalid register: %s\n", target)
+			return
+		}
+		w, found = c8.LastRegisterWrite(uint8(x))
+	default:
+		addr, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "MEM["), "]"), 0, 16)
+		if err != nil {
+			fmt.Fprintf(d.out, "invalid target %q, expected a register or an address\n", target)
+			return
+		}
+		w, found = c8.LastMemoryWrite(uint16(addr))
+	}
+
+	if !found {
+		fmt.Fprintf(d.out, "%s was not written in the recorded history (%d instructions)\n", target, c8.HistoryLen())
+		return
+	}
+	fmt.Fprintf(d.out, "%s was last written by %04X at %#03x in frame %d, %d instructions ago\n", target, w.Opcode, w.Address, w.Frame, w.Age)
+}
+
+// maxCandidates is the number of candidates of a memory search which are printed.
+const maxCandidates = 16
+
+// searchMemory starts a memory search or narrows the running one and prints the candidates.
+func (d *Debugger) searchMemory(c8 *chip8.Chip8, args string) {
+	if args == "" {
+		d.search = cheats.NewSearch(c8)
+		fmt.Fprintf(d.out, "search started with %d candidates, narrow it once the variable changed\n", len(d.search.Candidates()))
+		return
+	}
+	if d.search == nil {
+		fmt.Fprintln(d.out, "no search is running, start one with search")
+		return
+	}
+
+	if value, err := strconv.ParseUint(args, 0, 8); err == nil {
+		d.search.NarrowValue(c8, uint8(value))
+	} else if c, err := cheats.ParseComparison(args); err == nil {
+		d.search.Narrow(c8, c)
+	} else {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+
+	candidates := d.search.Candidates()
+	fmt.Fprintf(d.out, "candidates left: %d\n", len(candidates))
+	for _, addr := range candidates[:min(len(candidates), maxCandidates)] {
+		fmt.Fprintf(d.out, "  %#03x = %d\n", addr, d.search.Value(addr))
+	}
+	if len(candidates) > maxCandidates {
+		fmt.Fprintln(d.out, "  ...")
+	}
+}
+
+// freeze adds a cheat which keeps a byte at its value.
+func (d *Debugger) freeze(c8 *chip8.Chip8, args string) {
+	if d.Cheats == nil {
+		fmt.Fprintln(d.out, "cheats are disabled")
+		return
+	}
+	a, v, _ := strings.Cut(args, " ")
+	addr, err := strconv.ParseUint(a, 0, 16)
+	if err != nil || addr >= cheats.MemorySize {
+		fmt.Fprintf(d.out, "invalid address: %s\n", a)
+		return
+	}
+	code := cheats.Code{Address: uint16(addr), Value: c8.Memory(uint16(addr))}
+	if v = strings.TrimSpace(v); v != "" {
+		value, err := strconv.ParseUint(v, 0, 8)
+		if err != nil {
+			fmt.Fprintf(d.out, "invalid value: %s\n", v)
+			return
+		}
+		code.Value = uint8(value)
+	}
+	c := cheats.Cheat{Name: fmt.Sprintf("freeze %#03x", code.Address), Codes: []cheats.Code{code}}
+	d.Cheats.Cheats = append(d.Cheats.Cheats, c)
+	fmt.Fprintf(d.out, "cheat #%d: %s\n", len(d.Cheats.Cheats), c)
+}
+
+// cheat lists, enables, disables, deletes or saves the cheats.
+func (d *Debugger) cheat(args string) {
+	if d.Cheats == nil {
+		fmt.Fprintln(d.out, "cheats are disabled")
+		return
+	}
+	cmd, arg, _ := strings.Cut(args, " ")
+	switch cmd {
+	case "", "list":
+		for i, c := range d.Cheats.Cheats {
+			state := "on"
+			if c.Disabled {
+				state = "off"
+			}
+			fmt.Fprintf(d.out, "#%d %s %s\n", i+1, state, c)
+		}
+		return
+	case "save":
+		if d.CheatFile == "" {
+			fmt.Fprintln(d.out, "there is no cheat file")
+		} else if err := cheats.Save(d.CheatFile, d.Cheats.Cheats); err != nil {
+			fmt.Fprintln(d.out, err)
+		} else {
+			fmt.Fprintf(d.out, "saved %d cheats to %s\n", len(d.Cheats.Cheats), d.CheatFile)
+		}
+		return
+	}
+
+	var n int
+	if _, err := fmt.Sscan(arg, &n); err != nil || n < 1 || n > len(d.Cheats.Cheats) {
+		fmt.Fprintf(d.out, "no such cheat: %s\n", arg)
+		return
+	}
+	switch cmd {
+	case "on":
+		d.Cheats.Cheats[n-1].Disabled = false
+	case "off":
+		d.Cheats.Cheats[n-1].Disabled = true
+	case "delete":
+		d.Cheats.Cheats = append(d.Cheats.Cheats[:n-1], d.Cheats.Cheats[n:]...)
+	default:
+		fmt.Fprintf(d.out, "unknown cheat command %q\n", cmd)
+	}
+}
+
+func (d *Debugger) addFromConsole(kind Kind, src string, message string) {
+	p, err := d.Add(kind, src, message)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	fmt.Fprintf(d.out, "%s #%d: %s\n", p.Kind, p.ID, p.Expr)
+}
+
+func (d *Debugger) printState(c8 *chip8.Chip8) {
+	fmt.Fprintf(d.out, "PC=%#03x I=%#03x SP=%d DT=%d ST=%d frame=%d\n",
+		c8.ProgramCounter(), c8.IndexRegister(), c8.StackPointer(), c8.DelayTimer(), c8.SoundTimer(), c8.Frame())
+	for x := range uint8(16) {
+		fmt.Fprintf(d.out, "V%X=%02x ", x, c8.Register(x))
+	}
+	fmt.Fprintln(d.out)
+}
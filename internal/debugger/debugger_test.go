@@ -0,0 +1,53 @@
+package debugger
+
+import (
+	"io"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+func TestContinueAfterHit(t *testing.T) {
+	// The ROM counts V3 up in a loop.
+	rom := []byte{
+		0x73, 0x01, // ADD V3, 1
+		0x12, 0x00, // JP 0x200
+	}
+
+	tests := []struct {
+		testName string
+		src      string
+	}{
+		{testName: "Breakpoint which keeps holding", src: "V3 >= 0x10"},
+		{testName: "Expression which keeps failing", src: "PC / V0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c8 := &chip8.Chip8{Tickrate: 100}
+			if err := c8.Load(rom); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d := New(io.Discard)
+			c8.Debugger = d
+			if _, err := d.Add(Breakpoint, tt.src, ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			c8.StepFrame()
+			if !c8.Paused() {
+				t.Fatalf("Expected the emulator to be paused")
+			}
+			stopped := c8.Register(3)
+
+			c8.Resume()
+			c8.StepFrame()
+			if c8.Paused() {
+				t.Errorf("Expected the emulator to keep running after continue")
+			}
+			if c8.Register(3) < stopped+40 {
+				t.Errorf("Expected V3 to be counted past %d but got %d", stopped+40, c8.Register(3))
+			}
+		})
+	}
+}
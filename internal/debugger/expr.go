@@ -0,0 +1,387 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Machine is the view of the emulator state that expressions are evaluated against.
+// It is implemented by *chip8.Chip8.
+type Machine interface {
+	Register(x uint8) uint8
+	IndexRegister() uint16
+	ProgramCounter() uint16
+	StackPointer() uint8
+	DelayTimer() uint8
+	SoundTimer() uint8
+	Memory(addr uint16) uint8
+	Frame() uint64
+}
+
+// Expr is a compiled watch expression such as "V3 == 0x10 && mem[I] > 4".
+//
+// The language knows the registers V0 - VF, I, PC, SP, DT (delay timer), ST (sound timer),
+// the frame counter "frame" and memory access via "mem[addr]". Numbers can be written in
+// decimal, hexadecimal (0x10) or binary (0b101). The binary operators follow the precedence of Go,
+// from the highest to the lowest: "* / % << >> &", "+ - | ^", "== != < <= > >=", "&&" and "||".
+// The unary operators are ! (logical not), - (negation) and ^ (bitwise complement).
+// All values are integers; comparisons and logical operators yield 1 for true and 0 for false.
+type Expr struct {
+	src  string
+	root node
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// Compile parses src into an expression.
+func Compile(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	root, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates the expression against the given machine state.
+func (e *Expr) Eval(m Machine) (int64, error) {
+	return e.root.eval(m)
+}
+
+// String returns the source text of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+type node interface {
+	eval(m Machine) (int64, error)
+}
+
+type numberNode int64
+
+func (n numberNode) eval(Machine) (int64, error) {
+	return int64(n), nil
+}
+
+type variableNode func(m Machine) int64
+
+func (n variableNode) eval(m Machine) (int64, error) {
+	return n(m), nil
+}
+
+type memoryNode struct {
+	addr node
+}
+
+func (n memoryNode) eval(m Machine) (int64, error) {
+	addr, err := n.addr.eval(m)
+	if err != nil {
+		return 0, err
+	}
+	return int64(m.Memory(uint16(addr))), nil
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n unaryNode) eval(m Machine) (int64, error) {
+	v, err := n.operand.eval(m)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "-":
+		return -v, nil
+	case "^":
+		return ^v, nil
+	default: // "!"
+		return boolValue(v == 0), nil
+	}
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n binaryNode) eval(m Machine) (int64, error) {
+	l, err := n.left.eval(m)
+	if err != nil {
+		return 0, err
+	}
+
+	// Logical operators short-circuit like they do in Go.
+	switch n.op {
+	case "&&":
+		if l == 0 {
+			return 0, nil
+		}
+	case "||":
+		if l != 0 {
+			return 1, nil
+		}
+	}
+
+	r, err := n.right.eval(m)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return boolValue(r != 0), nil
+	case "==":
+		return boolValue(l == r), nil
+	case "!=":
+		return boolValue(l != r), nil
+	case "<":
+		return boolValue(l < r), nil
+	case "<=":
+		return boolValue(l <= r), nil
+	case ">":
+		return boolValue(l > r), nil
+	case ">=":
+		return boolValue(l >= r), nil
+	case "|":
+		return l | r, nil
+	case "^":
+		return l ^ r, nil
+	case "&":
+		return l & r, nil
+	case "<<":
+		return l << (uint64(r) & 63), nil
+	case ">>":
+		return l >> (uint64(r) & 63), nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return 0, errDivisionByZero
+		}
+		return l / r, nil
+	default: // "%"
+		if r == 0 {
+			return 0, errDivisionByZero
+		}
+		return l % r, nil
+	}
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// precedence holds the binding power of every binary operator, higher binds tighter.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4, "|": 4, "^": 4,
+	"*": 5, "/": 5, "%": 5, "<<": 5, ">>": 5, "&": 5,
+}
+
+// variables maps the (upper case) names of the machine state to their accessors.
+var variables = map[string]variableNode{
+	"I":     func(m Machine) int64 { return int64(m.IndexRegister()) },
+	"PC":    func(m Machine) int64 { return int64(m.ProgramCounter()) },
+	"SP":    func(m Machine) int64 { return int64(m.StackPointer()) },
+	"DT":    func(m Machine) int64 { return int64(m.DelayTimer()) },
+	"ST":    func(m Machine) int64 { return int64(m.SoundTimer()) },
+	"FRAME": func(m Machine) int64 { return int64(m.Frame()) },
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators is ordered so that two-character operators are matched first.
+var operators = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"<", ">", "|", "^", "&", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]",
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && isIdentChar(rune(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && isIdentChar(rune(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOperator, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+		}
+	}
+
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(op string) error {
+	if tok := p.next(); tok.kind != tokOperator || tok.text != op {
+		return fmt.Errorf("expected %q but got %q at offset %d", op, tok.text, tok.pos)
+	}
+	return nil
+}
+
+// parseBinary parses a chain of binary operators which bind tighter than minPrec (precedence climbing).
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		prec, ok := precedence[tok.text]
+		if tok.kind != tokOperator || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.kind == tokOperator && (tok.text == "!" || tok.text == "-" || tok.text == "^") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: tok.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseInt(strings.ReplaceAll(tok.text, "_", ""), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return numberNode(v), nil
+
+	case tokIdent:
+		name := strings.ToUpper(tok.text)
+		if name == "MEM" {
+			if err := p.expect("["); err != nil {
+				return nil, err
+			}
+			addr, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return memoryNode{addr: addr}, nil
+		}
+		if len(name) == 2 && name[0] == 'V' {
+			if x, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+				return variableNode(func(m Machine) int64 { return int64(m.Register(uint8(x))) }), nil
+			}
+		}
+		if v, ok := variables[name]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q at offset %d", tok.text, tok.pos)
+
+	case tokOperator:
+		if tok.text == "(" {
+			inner, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
@@ -0,0 +1,181 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// fakeMachine is a minimal Machine implementation for testing expressions without an emulator.
+type fakeMachine struct {
+	registers [16]uint8
+	memory    [4096]uint8
+	index     uint16
+	pc        uint16
+	sp        uint8
+	dt, st    uint8
+	frame     uint64
+}
+
+func (m *fakeMachine) Register(x uint8) uint8   { return m.registers[x] }
+func (m *fakeMachine) IndexRegister() uint16    { return m.index }
+func (m *fakeMachine) ProgramCounter() uint16   { return m.pc }
+func (m *fakeMachine) StackPointer() uint8      { return m.sp }
+func (m *fakeMachine) DelayTimer() uint8        { return m.dt }
+func (m *fakeMachine) SoundTimer() uint8        { return m.st }
+func (m *fakeMachine) Memory(addr uint16) uint8 { return m.memory[addr%4096] }
+func (m *fakeMachine) Frame() uint64            { return m.frame }
+
+func TestEval(t *testing.T) {
+	m := &fakeMachine{index: 0x300, pc: 0x2A0, sp: 12, dt: 3, st: 7, frame: 601}
+	m.registers[0x3] = 0x10
+	m.registers[0xF] = 1
+	m.memory[0x300] = 5
+
+	tests := []struct {
+		src  string
+		want int64
+	}{
+		{"V3 == 0x10 && mem[I] > 4", 1},
+		{"v3 == 16 && mem[I] > 5", 0},
+		{"frame > 600", 1},
+		{"SP >= 12", 1},
+		{"PC == 0x2A0", 1},
+		{"DT + ST", 10},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"1 << 4 | 1", 17},
+		{"VF == 1 || 1 / 0", 1},
+		{"!V0", 1},
+		{"-V3", -16},
+		{"^0 & 0xFF", 0xFF},
+		{"0b101 % 3", 2},
+		{"mem[I + 1] != mem[I]", 1},
+		{"FRAME >= 601 && Frame <= 601", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := expr.Eval(m)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"V3 ==",
+		"VG == 1",
+		"mem[I",
+		"(1 + 2",
+		"1 2",
+		"V3 = 1",
+		"0xZZ",
+		"$",
+	}
+
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			if _, err := Compile(src); err == nil {
+				t.Errorf("expected an error for %q, got nil", src)
+			}
+		})
+	}
+}
+
+func TestEvalDivisionByZero(t *testing.T) {
+	expr, err := Compile("V0 / V1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := expr.Eval(&fakeMachine{}); err == nil {
+		t.Errorf("expected a division by zero error, got nil")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var out bytes.Buffer
+	d := New(&out)
+	m := &fakeMachine{}
+
+	if _, err := d.Add(Breakpoint, "frame > 2", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := d.Add(Watchpoint, "V3", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := d.Add(Logpoint, "V3 == 0x10", "V3={V3:x} I={I}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("Nothing fires on the first evaluation", func(t *testing.T) {
+		if d.check(m, true) {
+			t.Errorf("expected the emulation to keep running")
+		}
+	})
+
+	t.Run("Watchpoint fires on change and log-point prints without stopping", func(t *testing.T) {
+		m.registers[3] = 0x10
+		m.index = 0x123
+		if !d.check(m, true) {
+			t.Errorf("expected the watchpoint to stop the emulation")
+		}
+		if !strings.Contains(out.String(), "V3=0x10 I=291") {
+			t.Errorf("expected the log-point message in the output, got %q", out.String())
+		}
+	})
+
+	t.Run("Unchanged watchpoint does not fire", func(t *testing.T) {
+		if d.check(m, true) {
+			t.Errorf("expected the emulation to keep running")
+		}
+	})
+
+	t.Run("Breakpoint fires", func(t *testing.T) {
+		m.frame = 3
+		if !d.check(m, true) {
+			t.Errorf("expected the breakpoint to stop the emulation")
+		}
+		if d.Points()[0].Hits != 1 {
+			t.Errorf("expected 1 hit, got %d", d.Points()[0].Hits)
+		}
+	})
+
+	t.Run("Breakpoint does not fire again while it holds", func(t *testing.T) {
+		m.frame = 4
+		if d.check(m, true) {
+			t.Errorf("expected the emulation to keep running")
+		}
+	})
+
+	t.Run("Breakpoint fires again once it held no longer", func(t *testing.T) {
+		m.frame = 0
+		d.check(m, true)
+		m.frame = 5
+		if !d.check(m, true) {
+			t.Errorf("expected the breakpoint to stop the emulation")
+		}
+		if d.Points()[0].Hits != 2 {
+			t.Errorf("expected 2 hits, got %d", d.Points()[0].Hits)
+		}
+	})
+
+	t.Run("Removed breakpoint does not fire", func(t *testing.T) {
+		if !d.Remove(1) {
+			t.Fatalf("expected point #1 to exist")
+		}
+		if d.check(m, true) {
+			t.Errorf("expected the emulation to keep running")
+		}
+	})
+}
@@ -0,0 +1,329 @@
+// Package detect guesses the quirks a ROM needs, so that users do not have to know them.
+//
+// The detection combines the static analysis of the ROM with short headless trial runs with the quirks
+// of every profile, during which the executed instructions are observed. Every behaviour that only works with a particular quirk setting
+// is reported as a Finding together with its reasoning. The findings are then matched against the
+// well-known quirk profiles.
+package detect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/analyzer"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// TrialFrames is the number of frames (10 seconds) the ROM is executed during the trial run.
+const TrialFrames = 600
+
+// profileOrder is the order in which the quirk profiles are tried and preferred if several of them match.
+var profileOrder = []string{"chip8", "schip", "xochip"}
+
+// Finding is an observed behaviour of the ROM which requires a particular quirk setting.
+type Finding struct {
+	Quirk   string // Name of the affected quirk: shift, memory, jump or clip
+	Address uint16 // Address of the first instruction showing the behaviour
+	Count   int    // Number of times the behaviour was observed
+	Reason  string
+
+	holds func(q chip8.Quirks) bool // Reports whether the quirks satisfy the finding
+	apply func(q *chip8.Quirks)     // Changes the quirks so that they satisfy the finding
+}
+
+// Report is the result of the quirk detection.
+type Report struct {
+	Profile  string // Name of the suggested profile, "custom" if no profile fits or empty without findings
+	Trial    string // Name of the profile whose quirks the trial run which found the findings used
+	Quirks   chip8.Quirks
+	Findings []*Finding
+}
+
+// String returns the suggestion together with its reasoning.
+func (r *Report) String() string {
+	var sb strings.Builder
+
+	if len(r.Findings) == 0 {
+		sb.WriteString("no quirk dependent behaviour detected, the current quirks are kept\n")
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "suggested quirk profile: %s\n", r.Profile)
+	if r.Profile != r.Trial {
+		fmt.Fprintf(&sb, "  the ROM was observed running with the %s quirks, adjusted where it needs other ones\n", r.Trial)
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&sb, "  [%s] %#03x: %s", f.Quirk, f.Address, f.Reason)
+		if f.Count > 1 {
+			fmt.Fprintf(&sb, " (%d times)", f.Count)
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// Quirks detects the quirks the ROM needs.
+//
+// What the ROM does during the trial run may depend on the quirks, e.g. whether a sprite crosses the
+// edge of the screen, so the trial is run with the quirks of every profile. The profile whose own run
+// contradicts the fewest findings is suggested, together with the findings of that run.
+func Quirks(rom []byte) (*Report, error) {
+	analysis := analyzer.Analyze(rom)
+
+	var best *Report
+	bestMisses := 0
+	for _, name := range profileOrder {
+		r, err := detectWith(analysis, rom, name)
+		if err != nil {
+			return nil, err
+		}
+
+		misses := 0
+		for _, f := range r.Findings {
+			if !f.holds(chip8.QuirkProfiles[name]) {
+				misses++
+			}
+		}
+		if best == nil || misses < bestMisses || misses == bestMisses && len(r.Findings) > len(best.Findings) {
+			best, bestMisses = r, misses
+		}
+	}
+
+	if len(best.Findings) == 0 {
+		return &Report{}, nil
+	}
+	if bestMisses > 0 {
+		best.Profile = "custom"
+		for _, f := range best.Findings {
+			f.apply(&best.Quirks)
+		}
+	}
+	return best, nil
+}
+
+// detectWith runs the detection with the quirks of the profile and returns its findings together
+// with the quirks of the profile.
+func detectWith(analysis *analyzer.Analysis, rom []byte, profile string) (*Report, error) {
+	d := &detector{analysis: analysis, findings: map[string]*Finding{}}
+
+	d.static()
+	if err := d.trial(rom, chip8.QuirkProfiles[profile]); err != nil {
+		return nil, err
+	}
+
+	r := &Report{Profile: profile, Trial: profile, Quirks: chip8.QuirkProfiles[profile]}
+	for _, key := range d.order {
+		r.Findings = append(r.Findings, d.findings[key])
+	}
+	return r, nil
+}
+
+type detector struct {
+	analysis *analyzer.Analysis
+	findings map[string]*Finding // Keyed by quirk and behaviour
+	order    []string            // Keys of the findings in the order they were found
+}
+
+// report records a finding. Repeated findings of the same behaviour are counted.
+func (d *detector) report(key string, f *Finding) {
+	if existing, found := d.findings[key]; found {
+		existing.Count++
+		return
+	}
+	f.Count = 1
+	d.findings[key] = f
+	d.order = append(d.order, key)
+}
+
+// static looks for quirk dependent instructions in the discovered code.
+func (d *detector) static() {
+	for _, b := range d.analysis.Blocks {
+		for i, inst := range b.Instructions {
+			switch {
+			case inst.Opcode&0xF00F == 0x8006 || inst.Opcode&0xF00F == 0x800E:
+				d.shift(inst)
+			case inst.Opcode&0xF0FF == 0xF055 || inst.Opcode&0xF0FF == 0xF065:
+				for _, next := range b.Instructions[i+1:] {
+					if d.memory(inst, next) {
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// shift reports 8XY6 and 8XYE instructions with X != Y, which only work if VY is shifted.
+func (d *detector) shift(inst analyzer.Instruction) {
+	x, y := (inst.Opcode&0x0F00)>>8, (inst.Opcode&0x00F0)>>4
+	if x == y {
+		return
+	}
+
+	d.report("shift", &Finding{
+		Quirk:   "shift",
+		Address: inst.Address,
+		Reason:  fmt.Sprintf("%s names two different registers, the ROM expects VY to be shifted into VX", inst.Text),
+		holds:   func(q chip8.Quirks) bool { return q.ShiftVY },
+		apply:   func(q *chip8.Quirks) { q.ShiftVY = true },
+	})
+}
+
+// memory inspects the instruction following a FX55 or FX65 and reports whether the index register
+// was used or reassigned by it. A store or load following another one of the same kind continues
+// where the previous one ended and relies on I being incremented, any other use of I relies on I
+// being left unchanged.
+func (d *detector) memory(prev, next analyzer.Instruction) (done bool) {
+	switch {
+	case next.Opcode&0xF000 == 0xA000, next.Opcode&0xF0FF == 0xF029:
+		return true
+	case next.Opcode&0xF0FF == prev.Opcode&0xF0FF:
+		d.report("memory-increment", &Finding{
+			Quirk:   "memory",
+			Address: prev.Address,
+			Reason:  fmt.Sprintf("%s is followed by %s at %#03x without reloading I, the ROM expects I to be incremented", prev.Text, next.Text, next.Address),
+			holds:   func(q chip8.Quirks) bool { return q.Memory == chip8.MemoryIncrement },
+			apply:   func(q *chip8.Quirks) { q.Memory = chip8.MemoryIncrement },
+		})
+		return true
+	case next.Opcode&0xF000 == 0xD000, next.Opcode&0xF0FF == 0xF033, next.Opcode&0xF0FF == 0xF01E,
+		next.Opcode&0xF0FF == 0xF055, next.Opcode&0xF0FF == 0xF065:
+		d.report("memory-unchanged", &Finding{
+			Quirk:   "memory",
+			Address: prev.Address,
+			Reason:  fmt.Sprintf("%s is followed by %s at %#03x without reloading I, the ROM expects I to be unchanged", prev.Text, next.Text, next.Address),
+			holds:   func(q chip8.Quirks) bool { return q.Memory == chip8.MemoryUnchanged },
+			apply:   func(q *chip8.Quirks) { q.Memory = chip8.MemoryUnchanged },
+		})
+		return true
+	}
+	return false
+}
+
+// trial runs the ROM headlessly with the given quirks and observes the executed instructions.
+func (d *detector) trial(rom []byte, quirks chip8.Quirks) error {
+	o := &observer{detector: d, address: 0x200}
+	c8 := &chip8.Chip8{Debugger: o, Quirks: quirks}
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+
+	for frame := range TrialFrames {
+		// Press the keys one after another, so that the ROM gets past its title screen.
+		key := uint8(frame / 30 % 16)
+		c8.SetKey(key, frame%30 < 5)
+
+		c8.StepFrame()
+		if !c8.Running() || c8.Paused() {
+			break
+		}
+	}
+
+	return nil
+}
+
+// observer is attached to the emulator during the trial run as its debugger.
+type observer struct {
+	detector *detector
+	address  uint16                // Address of the instruction executed next
+	pending  *analyzer.Instruction // The last FX55 or FX65 as long as I was not reassigned
+}
+
+func (o *observer) AfterCycle(c8 *chip8.Chip8) {
+	inst := analyzer.Instruction{Address: o.address, Opcode: c8.Opcode(), Text: analyzer.Disassemble(c8.Opcode())}
+	o.address = c8.ProgramCounter()
+
+	// The ROM ran into data, nothing it does from here on tells anything about its quirks.
+	if strings.HasPrefix(inst.Text, "DW") {
+		c8.Pause()
+		return
+	}
+
+	if o.pending != nil && o.detector.memory(*o.pending, inst) {
+		o.pending = nil
+	}
+
+	switch {
+	case inst.Opcode&0xF0FF == 0xF055 || inst.Opcode&0xF0FF == 0xF065:
+		o.pending = &inst
+	case inst.Opcode&0xF000 == 0xB000:
+		o.jump(c8, inst)
+	case inst.Opcode&0xF000 == 0xD000:
+		o.edge(c8, inst)
+	}
+}
+
+func (o *observer) Update(*chip8.Chip8) {}
+
+// jump compares the targets of a BNNN instruction under both interpretations and reports
+// the interpretation if only one of them plausibly leads to code.
+func (o *observer) jump(c8 *chip8.Chip8, inst analyzer.Instruction) {
+	x := uint8((inst.Opcode & 0x0F00) >> 8)
+	if x == 0 {
+		return
+	}
+
+	nnn := inst.Opcode & 0x0FFF
+	viaV0 := nnn + uint16(c8.Register(0))
+	viaVX := nnn + uint16(c8.Register(x))
+
+	switch {
+	case o.plausible(c8, viaV0) && !o.plausible(c8, viaVX):
+		o.detector.report("jump-v0", &Finding{
+			Quirk:   "jump",
+			Address: inst.Address,
+			Reason:  fmt.Sprintf("%s only reaches code at %#03x when adding V0", inst.Text, viaV0),
+			holds:   func(q chip8.Quirks) bool { return !q.JumpVX },
+			apply:   func(q *chip8.Quirks) { q.JumpVX = false },
+		})
+	case o.plausible(c8, viaVX) && !o.plausible(c8, viaV0):
+		o.detector.report("jump-vx", &Finding{
+			Quirk:   "jump",
+			Address: inst.Address,
+			Reason:  fmt.Sprintf("%s only reaches code at %#03x when adding V%X", inst.Text, viaVX, x),
+			holds:   func(q chip8.Quirks) bool { return q.JumpVX },
+			apply:   func(q *chip8.Quirks) { q.JumpVX = true },
+		})
+	}
+}
+
+// plausible reports whether the code at addr was discovered by the static analysis or at least
+// holds a valid instruction.
+func (o *observer) plausible(c8 *chip8.Chip8, addr uint16) bool {
+	if o.detector.analysis.IsCode(addr) {
+		return true
+	}
+	opcode := uint16(c8.Memory(addr))<<8 | uint16(c8.Memory(addr+1))
+	return !strings.HasPrefix(analyzer.Disassemble(opcode), "DW")
+}
+
+// edge reports sprites which are drawn across the right or bottom edge of the screen.
+func (o *observer) edge(c8 *chip8.Chip8, inst analyzer.Instruction) {
+	x := int(c8.Register(uint8((inst.Opcode&0x0F00)>>8))) % 64
+	y := int(c8.Register(uint8((inst.Opcode&0x00F0)>>4))) % 32
+	height := int(inst.Opcode & 0x000F)
+
+	crosses := false
+	for row := range height {
+		sprite := c8.Memory(c8.IndexRegister() + uint16(row))
+		// Only set pixels beyond the edge make a difference.
+		if x+8 > 64 && sprite&(0xFF>>(64-x)) != 0 {
+			crosses = true
+		}
+		if y+row >= 32 && sprite != 0 {
+			crosses = true
+		}
+	}
+	if !crosses {
+		return
+	}
+
+	o.detector.report("clip", &Finding{
+		Quirk:   "clip",
+		Address: inst.Address,
+		Reason:  fmt.Sprintf("%s draws a sprite across the edge of the screen at (%d, %d), the original interpreters clip it", inst.Text, x, y),
+		holds:   func(q chip8.Quirks) bool { return q.Clip },
+		apply:   func(q *chip8.Quirks) { q.Clip = true },
+	})
+}
@@ -0,0 +1,113 @@
+package detect
+
+import (
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+func TestQuirks(t *testing.T) {
+	tests := []struct {
+		testName    string
+		rom         []byte
+		wantProfile string
+		wantQuirks  []string
+		check       func(q chip8.Quirks) bool
+	}{
+		{
+			testName: "No quirk dependent instructions",
+			rom: []byte{
+				0x60, 0x01, // LD V0, 1
+				0x12, 0x02, // JP 0x202
+			},
+			wantProfile: "",
+			check:       func(q chip8.Quirks) bool { return q == chip8.Quirks{} },
+		},
+		{
+			testName: "Shift with X != Y and sequential stores",
+			rom: []byte{
+				0x81, 0x26, // SHR V1, V2
+				0xA3, 0x00, // LD I, 0x300
+				0xF1, 0x55, // LD [I], V1
+				0xF1, 0x55, // LD [I], V1
+				0x12, 0x08, // JP 0x208
+			},
+			wantProfile: "chip8",
+			wantQuirks:  []string{"shift", "memory"},
+			check:       func(q chip8.Quirks) bool { return q.ShiftVY && q.Memory == chip8.MemoryIncrement },
+		},
+		{
+			testName: "Reading back stored registers and a jump via VX",
+			rom: []byte{
+				0xA3, 0x00, // 0x200: LD I, 0x300
+				0xF1, 0x55, // 0x202: LD [I], V1
+				0xF1, 0x65, // 0x204: LD V1, [I]
+				0x60, 0x10, // 0x206: LD V0, 0x10
+				0x62, 0x14, // 0x208: LD V2, 0x14
+				0xB2, 0x00, // 0x20A: JP V0, 0x200 (BXNN: 0x200 + V2 = 0x214)
+				0x00, 0x00, // 0x20C: data
+				0x00, 0x00, // 0x20E: data
+				0x00, 0x00, // 0x210: data
+				0x00, 0x00, // 0x212: data
+				0x12, 0x14, // 0x214: JP 0x214
+			},
+			wantProfile: "schip",
+			wantQuirks:  []string{"memory", "jump"},
+			check:       func(q chip8.Quirks) bool { return q.Memory == chip8.MemoryUnchanged && q.JumpVX },
+		},
+		{
+			testName: "Sprite drawn across the right edge",
+			rom: []byte{
+				0x60, 0x3C, // LD V0, 60
+				0xA2, 0x08, // LD I, 0x208
+				0xD0, 0x11, // DRW V0, V1, 1
+				0x12, 0x06, // JP 0x206
+				0xFF, 0x00, // sprite
+			},
+			wantProfile: "chip8",
+			wantQuirks:  []string{"clip"},
+			check:       func(q chip8.Quirks) bool { return q.Clip },
+		},
+		{
+			testName: "Sprite only crosses the edge when VY is shifted",
+			rom: []byte{
+				0x60, 0x78, // 0x200: LD V0, 0x78
+				0x61, 0x7A, // 0x202: LD V1, 0x7A
+				0x80, 0x16, // 0x204: SHR V0, V1 (x = 61 if VY is shifted, 60 otherwise)
+				0xA2, 0x0E, // 0x206: LD I, 0x20E
+				0xD0, 0x21, // 0x208: DRW V0, V2, 1
+				0x12, 0x0A, // 0x20A: JP 0x20A
+				0x00, 0x00, // 0x20C: data
+				0xF0, 0x00, // 0x20E: sprite, 4 pixels wide
+			},
+			wantProfile: "chip8",
+			wantQuirks:  []string{"shift", "clip"},
+			check:       func(q chip8.Quirks) bool { return q.ShiftVY && q.Clip },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r, err := Quirks(tt.rom)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if r.Profile != tt.wantProfile {
+				t.Errorf("profile: got %q, want %q\n%s", r.Profile, tt.wantProfile, r)
+			}
+			for _, quirk := range tt.wantQuirks {
+				found := false
+				for _, f := range r.Findings {
+					found = found || f.Quirk == quirk
+				}
+				if !found {
+					t.Errorf("expected a finding for the %s quirk\n%s", quirk, r)
+				}
+			}
+			if !tt.check(r.Quirks) {
+				t.Errorf("unexpected quirks %+v\n%s", r.Quirks, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package font holds the built-in font of the CHIP-8 interpreters: the hex digits 0 to F as sprites
+// of 4x5 pixels. It is shared by the emulator, which loads it into memory, and the renderer, which
+// labels the on-screen keypad with it.
+package font
+
+// Set holds the sprites of the hex digits one after another, as they are stored in the memory.
+var Set = [80]byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// Sprite returns the sprite of the hex digit. Only the lowest 4 bits of digit are used.
+func Sprite(digit uint8) [5]byte {
+	return [5]byte(Set[5*int(digit&0xF):])
+}
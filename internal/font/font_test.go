@@ -0,0 +1,23 @@
+package font
+
+import "testing"
+
+func TestSprite(t *testing.T) {
+	tests := []struct {
+		testName string
+		digit    uint8
+		want     [5]byte
+	}{
+		{testName: "Zero", digit: 0x0, want: [5]byte{0xF0, 0x90, 0x90, 0x90, 0xF0}},
+		{testName: "F", digit: 0xF, want: [5]byte{0xF0, 0x80, 0xF0, 0x80, 0x80}},
+		{testName: "Only the lowest 4 bits are used", digit: 0x1A, want: [5]byte{0xF0, 0x90, 0xF0, 0x90, 0x90}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if got := Sprite(tt.digit); got != tt.want {
+				t.Errorf("Expected %X but got %X", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package gamestate reads values of the game state, such as the score and the lives, from a running
+// emulator, e.g. to reward an agent or to unlock achievements.
+//
+// Where a ROM keeps a value is declared per ROM as a Variable: in memory, in a register or as number
+// drawn on the screen. The ROM database holds the declarations of the known ROMs. Without a
+// declaration, the score is read from the screen: most games draw it with the digits of the built-in
+// font, which ReadNumbers recognises.
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// The names of the variables with a meaning known to the emulator.
+const (
+	Score = "score"
+	Lives = "lives"
+)
+
+// Variable declares where a ROM keeps a value. Exactly one of Address, Register and Screen is set.
+type Variable struct {
+	Address  uint16  `json:"address,omitempty"`  // Address of the value in memory
+	Length   int     `json:"length,omitempty"`   // Number of bytes at Address, 1 if 0
+	BCD      bool    `json:"bcd,omitempty"`      // The bytes at Address are decimal digits, as stored by FX33
+	Register *uint8  `json:"register,omitempty"` // Number of the register VX holding the value
+	Screen   *Region `json:"screen,omitempty"`   // Region of the screen the value is drawn in
+}
+
+// Region is a rectangle on the screen.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// contains reports whether the digit lies within the region.
+func (r *Region) contains(d Digit) bool {
+	return d.X >= r.X && d.Y >= r.Y && d.X+glyphWidth <= r.X+r.Width && d.Y+glyphHeight <= r.Y+r.Height
+}
+
+// Validate reports an error if the variable does not declare a single valid location.
+func (v Variable) Validate() error {
+	locations := 0
+	if v.Address != 0 {
+		locations++
+		if int(v.Address)+max(v.Length, 1) > 4096 || v.Length < 0 || v.Length > 8 {
+			return fmt.Errorf("the variable exceeds the memory or has an invalid length")
+		}
+	}
+	if v.Register != nil {
+		locations++
+		if *v.Register > 0xF {
+			return fmt.Errorf("there is no register V%X", *v.Register)
+		}
+	}
+	if v.Screen != nil {
+		locations++
+	}
+	if locations != 1 {
+		return fmt.Errorf("a variable needs exactly one of address, register and screen")
+	}
+	return nil
+}
+
+// String describes the location of the variable, e.g. 0x2F3, 0x300[3] BCD, VE or screen 20,0 10x5.
+func (v Variable) String() string {
+	switch {
+	case v.Register != nil:
+		return fmt.Sprintf("V%X", *v.Register)
+	case v.Screen != nil:
+		return fmt.Sprintf("screen %d,%d %dx%d", v.Screen.X, v.Screen.Y, v.Screen.Width, v.Screen.Height)
+	}
+	s := fmt.Sprintf("0x%X", v.Address)
+	if v.Length > 1 {
+		s += fmt.Sprintf("[%d]", v.Length)
+	}
+	if v.BCD {
+		s += " BCD"
+	}
+	return s
+}
+
+// Read returns the value of the variable. It reports false if the value cannot be read, e.g. if the
+// bytes of a BCD value are no decimal digits or no number is drawn in the region of the screen.
+func (v Variable) Read(c8 *chip8.Chip8) (int, bool) {
+	switch {
+	case v.Register != nil:
+		return int(c8.Register(*v.Register)), true
+	case v.Screen != nil:
+		display := c8.Display()
+		for _, n := range ReadNumbers(&display) {
+			if v.Screen.contains(n.Digits[0]) && n.Value >= 0 {
+				return n.Value, true
+			}
+		}
+		return 0, false
+	case v.Address != 0:
+		value := 0
+		for i := range uint16(max(v.Length, 1)) {
+			b := int(c8.Memory(v.Address + i))
+			if !v.BCD {
+				value = value<<8 | b
+				continue
+			}
+			if b > 9 {
+				return 0, false
+			}
+			value = value*10 + b
+		}
+		return value, true
+	}
+	return 0, false
+}
+
+// Reader reads the variables declared for a ROM.
+type Reader struct {
+	Variables map[string]Variable
+}
+
+// NewReader returns a reader of the given variables, which may be nil if none are declared.
+func NewReader(variables map[string]Variable) (*Reader, error) {
+	for name, v := range variables {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid variable %s: %w", name, err)
+		}
+	}
+	return &Reader{Variables: variables}, nil
+}
+
+// Known reports whether the value of the variable with the given name can be read: it is declared or
+// it is the score, which is read from the screen otherwise.
+func (r *Reader) Known(name string) bool {
+	_, ok := r.Variables[name]
+	return ok || name == Score
+}
+
+// Value returns the value of the variable with the given name. It reports false if the variable is
+// not known or cannot be read. If the score is not declared, the first decimal number on the screen
+// is taken for the score.
+func (r *Reader) Value(name string, c8 *chip8.Chip8) (int, bool) {
+	if v, ok := r.Variables[name]; ok {
+		return v.Read(c8)
+	}
+	if name != Score {
+		return 0, false
+	}
+	display := c8.Display()
+	for _, n := range ReadNumbers(&display) {
+		if n.Value >= 0 {
+			return n.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Score returns the score, see Value.
+func (r *Reader) Score(c8 *chip8.Chip8) (int, bool) {
+	return r.Value(Score, c8)
+}
+
+// Lives returns the number of lives left. It reports false if they are not declared.
+func (r *Reader) Lives(c8 *chip8.Chip8) (int, bool) {
+	return r.Value(Lives, c8)
+}
@@ -0,0 +1,228 @@
+package gamestate
+
+import (
+	"os"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// text is a row of digits at x, y.
+type text struct {
+	x, y   uint8
+	digits []uint8
+}
+
+// drawDigits returns a ROM which draws the texts with the built-in font, the digits with a distance
+// of 5 pixels, and stores 42 in V3 and at 0x300 as BCD.
+func drawDigits(texts ...text) []byte {
+	var rom []byte
+	for _, row := range texts {
+		rom = append(rom, 0x60, row.x, 0x61, row.y)
+		for _, d := range row.digits {
+			rom = append(rom,
+				0x62, d, // LD V2, d
+				0xF2, 0x29, // LD F, V2
+				0xD0, 0x15, // DRW V0, V1, 5
+				0x70, 0x05, // ADD V0, 5
+			)
+		}
+	}
+	loop := 0x200 + len(rom) + 6
+	return append(rom,
+		0x63, 42, // LD V3, 42
+		0xA3, 0x00, // LD I, 0x300
+		0xF3, 0x33, // LD B, V3
+		0x10|byte(loop>>8), byte(loop), // JP to itself
+	)
+}
+
+func run(t *testing.T, rom []byte) *chip8.Chip8 {
+	t.Helper()
+	c8 := &chip8.Chip8{Tickrate: 100}
+	if err := c8.Load(rom); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c8.StepFrame()
+	return c8
+}
+
+func TestReadNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		rom   []byte
+		texts []string
+		value int
+	}{
+		{"Single digit", drawDigits(text{10, 3, []uint8{7}}), []string{"7"}, 7},
+		{"Number", drawDigits(text{0, 0, []uint8{1, 0, 9}}), []string{"109"}, 109},
+		{"Hex digits", drawDigits(text{30, 20, []uint8{0xA, 0xF}}), []string{"AF"}, -1},
+		{"Bottom right corner", drawDigits(text{60, 27, []uint8{8}}), []string{"8"}, 8},
+		{"Clipped", drawDigits(text{62, 10, []uint8{8}}), nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			display := run(t, test.rom).Display()
+			numbers := ReadNumbers(&display)
+			if len(numbers) != len(test.texts) {
+				t.Fatalf("Expected %d numbers but got %+v", len(test.texts), numbers)
+			}
+			for i, n := range numbers {
+				if n.Text != test.texts[i] {
+					t.Errorf("Expected %s but got %s", test.texts[i], n.Text)
+				}
+				if n.Value != test.value {
+					t.Errorf("Expected the value %d but got %d", test.value, n.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestReadDigitsTouching(t *testing.T) {
+	// A pixel next to the digit makes it part of another sprite.
+	display := run(t, drawDigits(text{10, 10, []uint8{3}})).Display()
+	display[12][14] = true
+	if digits := ReadDigits(&display); len(digits) != 0 {
+		t.Errorf("Expected no digits but got %+v", digits)
+	}
+}
+
+func TestVariable(t *testing.T) {
+	register := uint8(3)
+	tests := []struct {
+		name     string
+		variable Variable
+		value    int
+		ok       bool
+	}{
+		{"Register", Variable{Register: &register}, 42, true},
+		{"Memory", Variable{Address: 0x301}, 4, true},
+		{"Big-endian", Variable{Address: 0x301, Length: 2}, 0x0402, true},
+		{"BCD", Variable{Address: 0x300, Length: 3, BCD: true}, 42, true},
+		{"Invalid BCD", Variable{Address: 0x200, Length: 2, BCD: true}, 0, false},
+		{"Screen", Variable{Screen: &Region{X: 20, Y: 0, Width: 20, Height: 10}}, 56, true},
+		{"Empty screen", Variable{Screen: &Region{X: 0, Y: 10, Width: 64, Height: 22}}, 0, false},
+	}
+
+	c8 := run(t, drawDigits(text{5, 2, []uint8{1}}, text{25, 2, []uint8{5, 6}}))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.variable.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, ok := test.variable.Read(c8)
+			if value != test.value || ok != test.ok {
+				t.Errorf("Expected %d, %t but got %d, %t", test.value, test.ok, value, ok)
+			}
+		})
+	}
+}
+
+func TestVariableString(t *testing.T) {
+	register := uint8(14)
+	tests := []struct {
+		variable Variable
+		want     string
+	}{
+		{Variable{Address: 0x2F3}, "0x2F3"},
+		{Variable{Address: 0x300, Length: 3, BCD: true}, "0x300[3] BCD"},
+		{Variable{Register: &register}, "VE"},
+		{Variable{Screen: &Region{X: 20, Y: 0, Width: 10, Height: 5}}, "screen 20,0 10x5"},
+	}
+
+	for _, test := range tests {
+		if got := test.variable.String(); got != test.want {
+			t.Errorf("Expected %s but got %s", test.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	register := uint8(16)
+	tests := []struct {
+		name     string
+		variable Variable
+	}{
+		{"Nothing", Variable{}},
+		{"Two locations", Variable{Address: 0x300, Screen: &Region{}}},
+		{"No register", Variable{Register: &register}},
+		{"Beyond the memory", Variable{Address: 0xFFF, Length: 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.variable.Validate(); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	register := uint8(3)
+	c8 := run(t, drawDigits(text{5, 2, []uint8{1, 2}}))
+
+	declared, err := NewReader(map[string]Variable{Score: {Register: &register}, Lives: {Address: 0x302}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if score, ok := declared.Score(c8); score != 42 || !ok {
+		t.Errorf("Expected the declared score 42 but got %d", score)
+	}
+	if lives, ok := declared.Lives(c8); lives != 2 || !ok {
+		t.Errorf("Expected 2 lives but got %d", lives)
+	}
+
+	fallback, _ := NewReader(nil)
+	if score, ok := fallback.Score(c8); score != 12 || !ok {
+		t.Errorf("Expected the score 12 read from the screen but got %d", score)
+	}
+	if _, ok := fallback.Lives(c8); ok {
+		t.Errorf("Expected the lives to be unknown")
+	}
+
+	if _, err := NewReader(map[string]Variable{Score: {}}); err == nil {
+		t.Errorf("Expected an error for an invalid variable")
+	}
+}
+
+// TestPong compares the scores of the bundled Pong in memory with the ones drawn on the screen.
+func TestPong(t *testing.T) {
+	rom, err := os.ReadFile("../../roms/pong.rom")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c8 := &chip8.Chip8{Quirks: chip8.QuirkProfiles["chip8"], Tickrate: 15}
+	c8.Seed(1)
+	if err := c8.Load(rom); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	player := Variable{Address: 0x2F3}
+	opponent := Variable{Address: 0x2F4}
+	left := Variable{Screen: &Region{X: 0, Y: 0, Width: 32, Height: 8}}
+	right := Variable{Screen: &Region{X: 32, Y: 0, Width: 32, Height: 8}}
+
+	compared := 0
+	for range 3000 {
+		c8.StepFrame()
+		p, _ := player.Read(c8)
+		o, _ := opponent.Read(c8)
+		l, lok := left.Read(c8)
+		r, rok := right.Read(c8)
+		// The digits are not drawn while the score is updated. The ball may turn a digit into another
+		// while it passes them, e.g. a 5 into a 9.
+		if !lok || !rok || p+o == 0 || c8.Register(7) < 6 {
+			continue
+		}
+		if p != l || o != r {
+			t.Fatalf("Expected the scores %d and %d on the screen but got %d and %d", p, o, l, r)
+		}
+		compared++
+	}
+	if compared == 0 {
+		t.Errorf("Expected a point to be scored")
+	}
+}
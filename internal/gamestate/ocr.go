@@ -0,0 +1,116 @@
+package gamestate
+
+import (
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+const (
+	glyphWidth  = 4
+	glyphHeight = 5
+	// maxGap is the number of blank columns which may separate the digits of a number.
+	maxGap = 3
+)
+
+// Digit is a hex digit of the built-in font found on the display.
+type Digit struct {
+	X, Y  int // Top left pixel
+	Value uint8
+}
+
+// Number is a row of digits of the built-in font found on the display.
+type Number struct {
+	X, Y   int    // Top left pixel of the first digit
+	Text   string // The digits, e.g. "0A"
+	Value  int    // The decimal value, -1 if Text holds hex digits other than 0 to 9
+	Digits []Digit
+}
+
+// glyphs holds the pixels of the font sprites, indexed by row and column.
+var glyphs = func() (g [16][glyphHeight][glyphWidth]bool) {
+	for digit := range uint8(16) {
+		sprite := chip8.FontSprite(digit)
+		for y, bits := range sprite {
+			for x := range glyphWidth {
+				g[digit][y][x] = bits&(0x80>>x) != 0
+			}
+		}
+	}
+	return g
+}()
+
+// ReadDigits finds the digits of the built-in font on the display, as drawn by the ROM with FX29 and a
+// sprite of 5 rows. A digit is only recognised if its pixels match exactly and the pixels around it are
+// blank, so that parts of other sprites are not taken for digits. The digits are ordered by row and
+// column.
+func ReadDigits(display *[32][64]bool) []Digit {
+	var digits []Digit
+	for y := 0; y+glyphHeight <= len(display); y++ {
+		for x := 0; x+glyphWidth <= len(display[y]); x++ {
+			if value, ok := digitAt(display, x, y); ok {
+				digits = append(digits, Digit{X: x, Y: y, Value: value})
+			}
+		}
+	}
+	return digits
+}
+
+// digitAt reports which digit is drawn with its top left pixel at x, y.
+func digitAt(display *[32][64]bool, x, y int) (uint8, bool) {
+	// Nothing but the digit may be drawn in the frame of a pixel around it.
+	for fy := y - 1; fy <= y+glyphHeight; fy++ {
+		for fx := x - 1; fx <= x+glyphWidth; fx++ {
+			inside := fy >= y && fy < y+glyphHeight && fx >= x && fx < x+glyphWidth
+			if !inside && pixel(display, fx, fy) {
+				return 0, false
+			}
+		}
+	}
+
+next:
+	for digit, glyph := range glyphs {
+		for gy := range glyph {
+			for gx := range glyph[gy] {
+				if display[y+gy][x+gx] != glyph[gy][gx] {
+					continue next
+				}
+			}
+		}
+		return uint8(digit), true
+	}
+	return 0, false
+}
+
+// pixel returns the pixel at x, y, which is blank outside of the display.
+func pixel(display *[32][64]bool, x, y int) bool {
+	return y >= 0 && y < len(display) && x >= 0 && x < len(display[y]) && display[y][x]
+}
+
+// ReadNumbers finds the numbers written with the digits of the built-in font on the display. The
+// digits of a number are on the same row and separated by at most a few blank columns. The numbers
+// are ordered by row and column.
+func ReadNumbers(display *[32][64]bool) []Number {
+	var numbers []Number
+	for _, d := range ReadDigits(display) {
+		if n := len(numbers) - 1; n >= 0 {
+			last := numbers[n].Digits[len(numbers[n].Digits)-1]
+			if last.Y == d.Y && d.X-(last.X+glyphWidth) <= maxGap {
+				numbers[n].Digits = append(numbers[n].Digits, d)
+				continue
+			}
+		}
+		numbers = append(numbers, Number{X: d.X, Y: d.Y, Digits: []Digit{d}})
+	}
+
+	for i := range numbers {
+		n := &numbers[i]
+		for _, d := range n.Digits {
+			n.Text += string("0123456789ABCDEF"[d.Value])
+			if d.Value > 9 || n.Value < 0 {
+				n.Value = -1
+			} else {
+				n.Value = n.Value*10 + int(d.Value)
+			}
+		}
+	}
+	return numbers
+}
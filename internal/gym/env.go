@@ -0,0 +1,151 @@
+// Package gym lets reinforcement learning agents play CHIP-8 games, following the interface of
+// OpenAI Gym: an episode starts with Reset, then the agent chooses an action for every Step and gets
+// the next observation, a reward and whether the episode is done.
+//
+// The observation is the display, the action are the keys held during the step. A step lasts a
+// few frames (frame skipping), so that the agent does not have to decide 60 times per second. The
+// reward is computed by a Reward, typically from the game memory, e.g. the increase of the score.
+//
+// Serve drives an environment with JSON messages, so that trainers written in other languages, e.g.
+// Python, can run it as a subprocess.
+package gym
+
+import (
+	"io"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// DefaultFrameSkip is the number of frames a step lasts if none is given.
+const DefaultFrameSkip = 4
+
+// Observation is the display, indexed by row and column.
+type Observation [32][64]bool
+
+// Pack returns the pixels row by row as bits, the most significant bit of the first byte being the
+// top left pixel.
+func (o *Observation) Pack() []byte {
+	packed := make([]byte, 0, 32*64/8)
+	for _, row := range o {
+		for x := 0; x < 64; x += 8 {
+			var b byte
+			for bit := range 8 {
+				if row[x+bit] {
+					b |= 0x80 >> bit
+				}
+			}
+			packed = append(packed, b)
+		}
+	}
+	return packed
+}
+
+// Action is the set of keys held during a step, bit n is set if key n is held.
+type Action uint16
+
+// NumKeys is the number of keys an action consists of.
+const NumKeys = 16
+
+// Options configures an environment.
+type Options struct {
+	Quirks    chip8.Quirks
+	Tickrate  int
+	FrameSkip int       // Frames per step, DefaultFrameSkip if 0
+	MaxFrames int       // Frames after which an episode ends, 0 for no limit
+	Reward    Reward    // Reward of a step, 0 if nil
+	Done      Condition // Ends the episode once it holds, e.g. if no lives are left
+	Log       io.Writer // Receives the messages of the emulator about errors of the ROM, os.Stdout if nil
+}
+
+// Env is an environment running a ROM.
+type Env struct {
+	c8   *chip8.Chip8
+	rom  []byte
+	opts Options
+	seed uint64
+	done bool
+}
+
+// NewEnv returns an environment running rom. Reset has to be called before the first step.
+func NewEnv(rom []byte, opts Options) (*Env, error) {
+	if opts.FrameSkip <= 0 {
+		opts.FrameSkip = DefaultFrameSkip
+	}
+	e := &Env{
+		c8:   &chip8.Chip8{Quirks: opts.Quirks, Tickrate: opts.Tickrate, Log: opts.Log},
+		rom:  rom,
+		opts: opts,
+		done: true,
+	}
+	if err := e.c8.Load(rom); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Seed sets the seed of the random numbers of the following episodes, so that they repeat. With
+// seed 0, every episode is seeded differently.
+func (e *Env) Seed(seed uint64) {
+	e.seed = seed
+}
+
+// Reset starts a new episode and returns the first observation.
+func (e *Env) Reset() Observation {
+	seed := e.seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	e.c8.Seed(seed)
+	// The ROM was loaded successfully by NewEnv.
+	e.c8.Load(e.rom)
+	if e.opts.Reward != nil {
+		e.opts.Reward.Reset(e.c8)
+	}
+	e.done = false
+	return Observation(e.c8.Display())
+}
+
+// Step holds the keys of action for the frames of a step and returns the observation afterwards, the
+// reward of the step and whether the episode is done. The episode is done if the Done condition
+// holds, the ROM halted or MaxFrames have run. Steps of a finished episode do nothing.
+func (e *Env) Step(action Action) (obs Observation, reward float64, done bool) {
+	if e.done {
+		return Observation(e.c8.Display()), 0, true
+	}
+	for key := range uint8(NumKeys) {
+		e.c8.SetKey(key, action&(1<<key) != 0)
+	}
+	for range e.opts.FrameSkip {
+		e.c8.StepFrame()
+		if e.opts.Reward != nil {
+			reward += e.opts.Reward.Reward(e.c8)
+		}
+		if e.finished() {
+			e.done = true
+			break
+		}
+	}
+	return Observation(e.c8.Display()), reward, e.done
+}
+
+func (e *Env) finished() bool {
+	if !e.c8.Running() {
+		return true
+	}
+	if e.opts.MaxFrames > 0 && e.c8.Frame() >= uint64(e.opts.MaxFrames) {
+		return true
+	}
+	return e.opts.Done != nil && e.opts.Done(e.c8)
+}
+
+// Frame returns the number of frames run in the current episode.
+func (e *Env) Frame() uint64 {
+	return e.c8.Frame()
+}
+
+// Emulator returns the emulator running the episodes, e.g. to read its memory. It must not be
+// changed.
+func (e *Env) Emulator() *chip8.Chip8 {
+	return e.c8
+}
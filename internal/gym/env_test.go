@@ -0,0 +1,202 @@
+package gym
+
+import (
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/gamestate"
+)
+
+// counter adds 1 to the byte at 0x300 for every loop while key 5 is held.
+var counter = []byte{
+	0x62, 0x05, // 0x200: LD V2, 5
+	0xE2, 0xA1, // 0x202: SKNP V2
+	0x70, 0x01, // 0x204: ADD V0, 1
+	0xA3, 0x00, // 0x206: LD I, 0x300
+	0xF0, 0x55, // 0x208: LD [I], V0
+	0x12, 0x02, // 0x20A: JP 0x202
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		actions    []Action
+		wantReward float64
+		wantFrame  uint64
+		wantDone   bool
+	}{
+		{"No key", Options{Tickrate: 10, Reward: MemoryReward(0x300, 1)}, []Action{0, 0}, 0, 8, false},
+		// 5 instructions per loop, so 2 loops per frame and 8 per step.
+		{"Key held", Options{Tickrate: 10, Reward: MemoryReward(0x300, 1)}, []Action{1 << 5, 1 << 5}, 16, 8, false},
+		{"Other key", Options{Tickrate: 10, Reward: MemoryReward(0x300, 1)}, []Action{1 << 4}, 0, 4, false},
+		{"Frame skip", Options{Tickrate: 10, FrameSkip: 1, Reward: MemoryReward(0x300, 0.5)}, []Action{1 << 5}, 1, 1, false},
+		{"Max frames", Options{Tickrate: 10, MaxFrames: 6}, []Action{0, 0, 0}, 0, 6, true},
+		{"Done", Options{Tickrate: 10, Done: MemoryEquals(0x300, 6)}, []Action{1 << 5, 1 << 5}, 0, 3, true},
+		{"Survival", Options{Tickrate: 10, Reward: FrameReward(0.25)}, []Action{0}, 1, 4, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env, err := NewEnv(counter, test.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			env.Reset()
+			var total float64
+			var done bool
+			for _, action := range test.actions {
+				var reward float64
+				_, reward, done = env.Step(action)
+				total += reward
+			}
+			if total != test.wantReward {
+				t.Errorf("Expected reward %v but got %v", test.wantReward, total)
+			}
+			if env.Frame() != test.wantFrame {
+				t.Errorf("Expected frame %d but got %d", test.wantFrame, env.Frame())
+			}
+			if done != test.wantDone {
+				t.Errorf("Expected done %v but got %v", test.wantDone, done)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	env, err := NewEnv(counter, Options{Tickrate: 10, MaxFrames: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, done := env.Step(1 << 5); !done {
+		t.Errorf("Expected a step before Reset to be done")
+	}
+
+	env.Reset()
+	if _, _, done := env.Step(1 << 5); !done {
+		t.Errorf("Expected the episode to end after 4 frames")
+	}
+	if env.Emulator().Memory(0x300) != 8 {
+		t.Errorf("Expected the counter at 8 but got %d", env.Emulator().Memory(0x300))
+	}
+
+	env.Reset()
+	if env.Frame() != 0 || env.Emulator().Memory(0x300) != 0 {
+		t.Errorf("Expected Reset to restart the ROM")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	// Draws a dot at a random position.
+	rom := []byte{0xA2, 0x08, 0xC0, 0x3F, 0xC1, 0x1F, 0xD0, 0x11, 0x80}
+	env, err := NewEnv(rom, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env.Seed(42)
+	env.Reset()
+	first, _, _ := env.Step(0)
+	env.Reset()
+	second, _, _ := env.Step(0)
+	if first != second {
+		t.Errorf("Expected episodes with the same seed to repeat")
+	}
+}
+
+func TestPack(t *testing.T) {
+	var obs Observation
+	obs[0][0] = true
+	obs[0][9] = true
+	obs[31][63] = true
+	packed := obs.Pack()
+	if len(packed) != 256 {
+		t.Fatalf("Expected 256 bytes but got %d", len(packed))
+	}
+	if packed[0] != 0x80 || packed[1] != 0x40 || packed[255] != 0x01 {
+		t.Errorf("Expected 0x80, 0x40 and 0x01 but got %#x, %#x and %#x", packed[0], packed[1], packed[255])
+	}
+}
+
+func TestParseReward(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"memory:0x300", false},
+		{"memory:768*-10", false},
+		{"memory:0x300*2,frame:0.01", false},
+		{"memory:0x1000", true},
+		{"memory:0x300*x", true},
+		{"frame:", true},
+		{"score*0.1", false},
+		{"lives", true},
+		{"unknown:1", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			_, err := ParseReward(test.spec, nil)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expected error %v but got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	reward, _ := ParseReward("memory:0x300*2,frame:0.5", nil)
+	c8 := &chip8.Chip8{Tickrate: 10}
+	c8.Load(counter)
+	reward.Reset(c8)
+	c8.SetKey(5, true)
+	c8.StepFrame()
+	if got := reward.Reward(c8); got != 4.5 {
+		t.Errorf("Expected reward 4.5 but got %v", got)
+	}
+}
+
+func TestVariableReward(t *testing.T) {
+	state, err := gamestate.NewReader(map[string]gamestate.Variable{gamestate.Lives: {Address: 0x300}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reward, err := ParseReward("lives*-2", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done, err := ParseCondition("lives=6", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ParseCondition("lifes=0", state); err == nil {
+		t.Errorf("Expected an error for an unknown variable")
+	}
+
+	env, err := NewEnv(counter, Options{Tickrate: 10, Reward: reward, Done: done})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env.Reset()
+	// The counter reaches 6 in the third frame.
+	if _, reward, done := env.Step(1 << 5); reward != -12 || !done {
+		t.Errorf("Expected reward -12 and the episode to end but got %v, %t", reward, done)
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	if _, err := ParseCondition("memory:0x300", nil); err == nil {
+		t.Errorf("Expected an error for a condition without value")
+	}
+	done, err := ParseCondition("memory:0x300=2, memory:0x301=1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c8 := &chip8.Chip8{Tickrate: 10}
+	c8.Load(counter)
+	if done(c8) {
+		t.Errorf("Expected the condition not to hold")
+	}
+	c8.SetKey(5, true)
+	c8.StepFrame()
+	if !done(c8) {
+		t.Errorf("Expected the condition to hold")
+	}
+}
@@ -0,0 +1,103 @@
+package gym
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// request is a line sent to Serve.
+type request struct {
+	Cmd    string  `json:"cmd"`
+	Seed   *uint64 `json:"seed,omitempty"`
+	Action Action  `json:"action"`
+}
+
+type specResponse struct {
+	Keys      int `json:"keys"`
+	Width     int `json:"width"`
+	Height    int `json:"height"`
+	FrameSkip int `json:"frame_skip"`
+	MaxFrames int `json:"max_frames"`
+}
+
+type resetResponse struct {
+	Obs   []byte `json:"obs"`
+	Frame uint64 `json:"frame"`
+}
+
+type stepResponse struct {
+	Obs    []byte  `json:"obs"`
+	Reward float64 `json:"reward"`
+	Done   bool    `json:"done"`
+	Frame  uint64  `json:"frame"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Serve reads requests from r, one JSON object per line, runs them on env and writes a JSON response
+// per request to w, until r ends or a close request arrives:
+//
+//	{"cmd": "spec"}                  {"keys": 16, "width": 64, "height": 32, "frame_skip": 4, "max_frames": 0}
+//	{"cmd": "reset", "seed": 42}     {"obs": "AAAA...", "frame": 0}
+//	{"cmd": "step", "action": 32}    {"obs": "AAAA...", "reward": 1, "done": false, "frame": 4}
+//	{"cmd": "close"}                 {}
+//
+// The seed of reset is optional, see Env.Seed. The action is the bit mask of the held keys, 32 holds
+// key 5. The observation is the display packed by Observation.Pack and encoded as base64. Invalid
+// requests are answered with {"error": "..."}.
+func Serve(env *Env, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		response, quit := handle(env, scanner.Bytes())
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// handle runs a request and returns its response. It reports quit for a close request.
+func handle(env *Env, line []byte) (response any, quit bool) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse{fmt.Sprintf("invalid request: %v", err)}, false
+	}
+	switch req.Cmd {
+	case "spec":
+		return specResponse{
+			Keys:      NumKeys,
+			Width:     64,
+			Height:    32,
+			FrameSkip: env.opts.FrameSkip,
+			MaxFrames: env.opts.MaxFrames,
+		}, false
+	case "reset":
+		if req.Seed != nil {
+			env.Seed(*req.Seed)
+		}
+		obs := env.Reset()
+		return resetResponse{Obs: obs.Pack(), Frame: env.Frame()}, false
+	case "step":
+		obs, reward, done := env.Step(req.Action)
+		return stepResponse{Obs: obs.Pack(), Reward: reward, Done: done, Frame: env.Frame()}, false
+	case "close":
+		return struct{}{}, true
+	default:
+		return errorResponse{fmt.Sprintf("unknown command %q", req.Cmd)}, false
+	}
+}
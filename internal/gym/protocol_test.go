@@ -0,0 +1,54 @@
+package gym
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	env, err := NewEnv(counter, Options{Tickrate: 10, Reward: MemoryReward(0x300, 1)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	in := strings.Join([]string{
+		`{"cmd": "spec"}`,
+		`{"cmd": "reset", "seed": 1}`,
+		`{"cmd": "step", "action": 32}`,
+		`{"cmd": "jump"}`,
+		`not json`,
+		`{"cmd": "close"}`,
+		`{"cmd": "step", "action": 32}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := Serve(env, strings.NewReader(in), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 responses but got %d: %q", len(lines), lines)
+	}
+
+	var spec specResponse
+	if err := json.Unmarshal([]byte(lines[0]), &spec); err != nil || spec.Keys != 16 || spec.FrameSkip != DefaultFrameSkip {
+		t.Errorf("Expected the spec but got %s", lines[0])
+	}
+	var reset resetResponse
+	if err := json.Unmarshal([]byte(lines[1]), &reset); err != nil || len(reset.Obs) != 256 {
+		t.Errorf("Expected the first observation but got %s", lines[1])
+	}
+	var step stepResponse
+	if err := json.Unmarshal([]byte(lines[2]), &step); err != nil || step.Reward != 8 || step.Frame != 4 || len(step.Obs) != 256 {
+		t.Errorf("Expected a step with reward 8 at frame 4 but got %s", lines[2])
+	}
+	for _, line := range lines[3:5] {
+		if !strings.Contains(line, `"error"`) {
+			t.Errorf("Expected an error but got %s", line)
+		}
+	}
+	if lines[5] != "{}" {
+		t.Errorf("Expected {} but got %s", lines[5])
+	}
+}
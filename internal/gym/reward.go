@@ -0,0 +1,246 @@
+package gym
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/gamestate"
+)
+
+// Reward computes the reward of the frames of an episode.
+type Reward interface {
+	// Reset is called at the start of an episode, once the ROM is loaded.
+	Reset(c8 *chip8.Chip8)
+	// Reward returns the reward of the frame which ran last.
+	Reward(c8 *chip8.Chip8) float64
+}
+
+// RewardFunc is a Reward which does not depend on the previous frames.
+type RewardFunc func(c8 *chip8.Chip8) float64
+
+func (f RewardFunc) Reset(c8 *chip8.Chip8) {}
+
+func (f RewardFunc) Reward(c8 *chip8.Chip8) float64 {
+	return f(c8)
+}
+
+// FrameReward rewards every frame with value, e.g. to reward surviving.
+func FrameReward(value float64) Reward {
+	return RewardFunc(func(c8 *chip8.Chip8) float64 { return value })
+}
+
+// MemoryReward rewards the change of the byte at addr, multiplied by scale. With the address of the
+// score, the agent is rewarded for scoring. With the address of the lives and a negative scale, it is
+// punished for losing one.
+func MemoryReward(addr uint16, scale float64) Reward {
+	return &memoryReward{addr: addr, scale: scale}
+}
+
+type memoryReward struct {
+	addr  uint16
+	scale float64
+	last  uint8
+}
+
+func (m *memoryReward) Reset(c8 *chip8.Chip8) {
+	m.last = c8.Memory(m.addr)
+}
+
+func (m *memoryReward) Reward(c8 *chip8.Chip8) float64 {
+	value := c8.Memory(m.addr)
+	delta := float64(value) - float64(m.last)
+	m.last = value
+	return delta * m.scale
+}
+
+// VariableReward rewards the change of a variable of the game state, multiplied by scale, e.g. of
+// gamestate.Score. The score is read from the screen if the reader does not declare it. A frame in
+// which the variable cannot be read is not rewarded.
+func VariableReward(state *gamestate.Reader, name string, scale float64) Reward {
+	return &variableReward{state: state, name: name, scale: scale}
+}
+
+type variableReward struct {
+	state *gamestate.Reader
+	name  string
+	scale float64
+	last  int
+	known bool
+}
+
+func (v *variableReward) Reset(c8 *chip8.Chip8) {
+	v.last, v.known = v.state.Value(v.name, c8)
+}
+
+func (v *variableReward) Reward(c8 *chip8.Chip8) float64 {
+	value, ok := v.state.Value(v.name, c8)
+	if !ok {
+		return 0
+	}
+	var reward float64
+	if v.known {
+		reward = float64(value-v.last) * v.scale
+	}
+	v.last, v.known = value, true
+	return reward
+}
+
+// Sum returns a Reward which adds the given rewards.
+func Sum(rewards ...Reward) Reward {
+	return sum(rewards)
+}
+
+type sum []Reward
+
+func (s sum) Reset(c8 *chip8.Chip8) {
+	for _, r := range s {
+		r.Reset(c8)
+	}
+}
+
+func (s sum) Reward(c8 *chip8.Chip8) float64 {
+	var total float64
+	for _, r := range s {
+		total += r.Reward(c8)
+	}
+	return total
+}
+
+// Condition reports whether the emulator is in a particular state, e.g. the game is over.
+type Condition func(c8 *chip8.Chip8) bool
+
+// MemoryEquals holds if the byte at addr has the given value.
+func MemoryEquals(addr uint16, value uint8) Condition {
+	return func(c8 *chip8.Chip8) bool { return c8.Memory(addr) == value }
+}
+
+// ParseReward parses a comma-separated list of rewards which are added:
+//
+//	memory:ADDR[*SCALE]  the change of the byte at ADDR, e.g. memory:0x2F0 or memory:0x2F1*-10
+//	frame:VALUE          VALUE for every frame, e.g. frame:0.01
+//	NAME[*SCALE]         the change of a variable of state, e.g. score or lives*-10
+//
+// state declares the variables of the ROM, without it only the score is known, read from the screen.
+func ParseReward(spec string, state *gamestate.Reader) (Reward, error) {
+	if state == nil {
+		state = &gamestate.Reader{}
+	}
+	var rewards []Reward
+	for term := range strings.SplitSeq(spec, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(term), ":")
+		if name, scale, _ := strings.Cut(kind, "*"); state.Known(name) {
+			s, err := parseScale(scale)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reward %q: %w", term, err)
+			}
+			rewards = append(rewards, VariableReward(state, name, s))
+			continue
+		}
+		switch kind {
+		case "memory":
+			addr, scale, _ := strings.Cut(arg, "*")
+			a, err := parseAddress(addr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reward %q: %w", term, err)
+			}
+			s, err := parseScale(scale)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reward %q: %w", term, err)
+			}
+			rewards = append(rewards, MemoryReward(a, s))
+		case "frame":
+			value, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reward %q: invalid value", term)
+			}
+			rewards = append(rewards, FrameReward(value))
+		default:
+			return nil, fmt.Errorf("invalid reward %q: unknown kind %q", term, kind)
+		}
+	}
+	if len(rewards) == 1 {
+		return rewards[0], nil
+	}
+	return Sum(rewards...), nil
+}
+
+// ParseCondition parses a comma-separated list of conditions, of which one has to hold:
+//
+//	memory:ADDR=VALUE  the byte at ADDR has VALUE, e.g. memory:0x2F1=0
+//	NAME=VALUE         the variable of state has VALUE, e.g. lives=0
+func ParseCondition(spec string, state *gamestate.Reader) (Condition, error) {
+	if state == nil {
+		state = &gamestate.Reader{}
+	}
+	var conditions []Condition
+	for term := range strings.SplitSeq(spec, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(term), ":")
+		if name, value, found := strings.Cut(kind, "="); found && arg == "" {
+			condition, err := variableEquals(state, name, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition %q: %w", term, err)
+			}
+			conditions = append(conditions, condition)
+			continue
+		}
+		if kind != "memory" {
+			return nil, fmt.Errorf("invalid condition %q: unknown kind %q", term, kind)
+		}
+		addr, value, found := strings.Cut(arg, "=")
+		a, err := parseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", term, err)
+		}
+		v, err := strconv.ParseUint(value, 0, 8)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid condition %q: invalid value", term)
+		}
+		conditions = append(conditions, MemoryEquals(a, uint8(v)))
+	}
+	return func(c8 *chip8.Chip8) bool {
+		for _, holds := range conditions {
+			if holds(c8) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// variableEquals holds if the variable of state has the given value.
+func variableEquals(state *gamestate.Reader, name, value string) (Condition, error) {
+	if !state.Known(name) {
+		return nil, fmt.Errorf("unknown variable %q", name)
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value")
+	}
+	return func(c8 *chip8.Chip8) bool {
+		current, ok := state.Value(name, c8)
+		return ok && current == v
+	}, nil
+}
+
+// parseScale parses the factor of a reward, 1 if s is empty.
+func parseScale(s string) (float64, error) {
+	if s == "" {
+		return 1, nil
+	}
+	scale, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scale")
+	}
+	return scale, nil
+}
+
+// parseAddress parses a memory address, decimal or hexadecimal with prefix 0x.
+func parseAddress(s string) (uint16, error) {
+	addr, err := strconv.ParseUint(s, 0, 16)
+	if err != nil || addr >= 4096 {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(addr), nil
+}
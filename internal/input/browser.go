@@ -0,0 +1,148 @@
+//go:build js && wasm
+
+package input
+
+import (
+	"strconv"
+	"sync"
+	"syscall/js"
+)
+
+// codeMap holds the default layout by KeyboardEvent.code, which like the SDL scancodes denotes the
+// physical position of a key.
+var codeMap = map[string]uint8{
+	"Digit1": 0x1, "Digit2": 0x2, "Digit3": 0x3, "Digit4": 0xC,
+	"KeyQ": 0x4, "KeyW": 0x5, "KeyE": 0x6, "KeyR": 0xD,
+	"KeyA": 0x7, "KeyS": 0x8, "KeyD": 0x9, "KeyF": 0xE,
+	"KeyZ": 0xA, "KeyX": 0x0, "KeyC": 0xB, "KeyV": 0xF,
+}
+
+// buttonCodes holds the keys of the buttons named by the ROM database, the same as buttonKeys.
+var buttonCodes = map[string]string{
+	"up": "ArrowUp", "down": "ArrowDown", "left": "ArrowLeft", "right": "ArrowRight",
+	"a": "Space", "b": "ShiftLeft",
+	"player2Up": "KeyI", "player2Down": "KeyK", "player2Left": "KeyJ", "player2Right": "KeyL",
+	"player2A": "KeyO", "player2B": "KeyP",
+}
+
+// keyEvent is a key pressed or released in the browser, which is applied by the next PollKeys.
+type keyEvent struct {
+	key  uint8
+	down bool
+}
+
+// BrowserInput reads the keyboard of the page and an on-screen keypad made of elements with a data-key
+// attribute holding the hex digit of their CHIP-8 key, which can be clicked or touched.
+type BrowserInput struct {
+	mu     sync.Mutex
+	codes  map[string]uint8
+	events []keyEvent
+	held   [16]int  // Number of host keys held down per CHIP-8 key
+	remove []func() // Remove the event listeners
+}
+
+// NewBrowserInput listens to the keyboard events of the document and the pointer events of the
+// keypad elements below it.
+func NewBrowserInput(document js.Value) *BrowserInput {
+	b := &BrowserInput{codes: make(map[string]uint8, len(codeMap))}
+	for code, key := range codeMap {
+		b.codes[code] = key
+	}
+
+	b.listen(document, "keydown", b.keyboard(true))
+	b.listen(document, "keyup", b.keyboard(false))
+
+	buttons := document.Call("querySelectorAll", "[data-key]")
+	for i := range buttons.Length() {
+		button := buttons.Index(i)
+		key, err := strconv.ParseUint(button.Get("dataset").Get("key").String(), 16, 4)
+		if err != nil {
+			continue
+		}
+		down := b.pointer(button, uint8(key), true)
+		up := b.pointer(button, uint8(key), false)
+		b.listen(button, "pointerdown", down)
+		b.listen(button, "pointerup", up)
+		b.listen(button, "pointercancel", up)
+	}
+	return b
+}
+
+// BindButtons binds the keys of the named buttons (arrow keys, space and left shift for the first
+// player, IJKL, O and P for the second one) to the given CHIP-8 keys. The hex keypad stays available.
+// Unknown button names are ignored.
+func (b *BrowserInput) BindButtons(buttons map[string]uint8) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name, key := range buttons {
+		if code, found := buttonCodes[name]; found {
+			b.codes[code] = key & 0xF
+		}
+	}
+}
+
+func (b *BrowserInput) PollKeys(keyPad *[16]bool) (quit bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.events {
+		if e.down {
+			b.held[e.key]++
+		} else {
+			b.held[e.key] = max(b.held[e.key]-1, 0)
+		}
+		keyPad[e.key] = b.held[e.key] > 0
+	}
+	b.events = b.events[:0]
+	return false
+}
+
+// Cleanup removes the event listeners.
+func (b *BrowserInput) Cleanup() {
+	for _, remove := range b.remove {
+		remove()
+	}
+	b.remove = nil
+}
+
+// listen adds an event listener, which Cleanup removes.
+func (b *BrowserInput) listen(target js.Value, event string, f js.Func) {
+	target.Call("addEventListener", event, f)
+	b.remove = append(b.remove, func() {
+		target.Call("removeEventListener", event, f)
+		f.Release()
+	})
+}
+
+// keyboard returns the handler of key events, which prevents the default action of the keys bound,
+// e.g. scrolling with the arrow keys.
+func (b *BrowserInput) keyboard(down bool) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		e := args[0]
+		if e.Get("repeat").Bool() {
+			return nil
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if key, found := b.codes[e.Get("code").String()]; found {
+			e.Call("preventDefault")
+			b.events = append(b.events, keyEvent{key, down})
+		}
+		return nil
+	})
+}
+
+// pointer returns the handler of pointer events on a key of the on-screen keypad. The pointer is
+// captured on press, so that the key is released even if the finger leaves it in the meantime.
+func (b *BrowserInput) pointer(button js.Value, key uint8, down bool) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		e := args[0]
+		e.Call("preventDefault")
+		if down {
+			button.Call("setPointerCapture", e.Get("pointerId"))
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.events = append(b.events, keyEvent{key, down})
+		return nil
+	})
+}
@@ -0,0 +1,228 @@
+//go:build !js
+
+package input
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// deadZone is the distance from the centre an axis has to be moved to press the bound CHIP-8 key.
+const deadZone = 16000
+
+// axisDirection denotes one direction of a controller axis, e.g. the left stick pushed to the left.
+type axisDirection struct {
+	axis     sdl.GameControllerAxis
+	negative bool
+}
+
+// padButtons and padAxes hold the default controller layout. It follows the convention of Octo:
+// the D-pad and the left stick act as W, A, S and D, the buttons A and B as E and Q.
+var padButtons = map[sdl.GameControllerButton]uint8{
+	sdl.CONTROLLER_BUTTON_DPAD_UP: 0x5, sdl.CONTROLLER_BUTTON_DPAD_DOWN: 0x8,
+	sdl.CONTROLLER_BUTTON_DPAD_LEFT: 0x7, sdl.CONTROLLER_BUTTON_DPAD_RIGHT: 0x9,
+	sdl.CONTROLLER_BUTTON_A: 0x6, sdl.CONTROLLER_BUTTON_B: 0x4,
+}
+
+var padAxes = map[axisDirection]uint8{
+	{sdl.CONTROLLER_AXIS_LEFTY, true}: 0x5, {sdl.CONTROLLER_AXIS_LEFTY, false}: 0x8,
+	{sdl.CONTROLLER_AXIS_LEFTX, true}: 0x7, {sdl.CONTROLLER_AXIS_LEFTX, false}: 0x9,
+}
+
+// buttonControls holds the controller buttons and axes of the buttons named by the ROM database.
+// The buttons of the second player are only available on the keyboard.
+var buttonControls = map[string]struct {
+	buttons []sdl.GameControllerButton
+	axes    []axisDirection
+}{
+	"up":    {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_DPAD_UP}, []axisDirection{{sdl.CONTROLLER_AXIS_LEFTY, true}}},
+	"down":  {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_DPAD_DOWN}, []axisDirection{{sdl.CONTROLLER_AXIS_LEFTY, false}}},
+	"left":  {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_DPAD_LEFT}, []axisDirection{{sdl.CONTROLLER_AXIS_LEFTX, true}}},
+	"right": {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_DPAD_RIGHT}, []axisDirection{{sdl.CONTROLLER_AXIS_LEFTX, false}}},
+	"a":     {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_A}, nil},
+	"b":     {[]sdl.GameControllerButton{sdl.CONTROLLER_BUTTON_B}, nil},
+}
+
+// pad is an opened game controller together with the CHIP-8 keys it holds down, so that they can be
+// released when the controller is unplugged.
+type pad struct {
+	controller *sdl.GameController
+	buttons    map[sdl.GameControllerButton]uint8
+	axes       map[axisDirection]uint8
+}
+
+// BindController binds controller buttons and axes to the CHIP-8 keys 0 - F in the format of BindKeys,
+// replacing the previous bindings of these CHIP-8 keys. Buttons are given by their SDL name, e.g. "a",
+// "dpup" or "leftshoulder". Axes are given by their SDL name followed by the direction, e.g. "leftx-"
+// for the left stick pushed to the left. The triggers may be given without a direction.
+// Invalid bindings are skipped and reported by the returned error.
+func (s *SDLInput) BindController(bindings map[string][]string) error {
+	s.init()
+
+	var errs []error
+	for name, controls := range bindings {
+		key, err := strconv.ParseUint(name, 16, 4)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid CHIP-8 key: %s", name))
+			continue
+		}
+
+		for button, k := range s.buttons {
+			if k == uint8(key) {
+				delete(s.buttons, button)
+			}
+		}
+		for axis, k := range s.axes {
+			if k == uint8(key) {
+				delete(s.axes, axis)
+			}
+		}
+
+		for _, control := range controls {
+			if button := sdl.GameControllerGetButtonFromString(control); button != sdl.CONTROLLER_BUTTON_INVALID {
+				s.buttons[button] = uint8(key)
+				continue
+			}
+
+			name, negative := strings.CutSuffix(control, "-")
+			if !negative {
+				name = strings.TrimSuffix(name, "+")
+			}
+			axis := sdl.GameControllerGetAxisFromString(name)
+			if axis == sdl.CONTROLLER_AXIS_INVALID {
+				errs = append(errs, fmt.Errorf("unknown controller button or axis: %s", control))
+				continue
+			}
+			s.axes[axisDirection{axis, negative}] = uint8(key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Cleanup closes the opened game controllers.
+func (s *SDLInput) Cleanup() {
+	for id, p := range s.pads {
+		p.controller.Close()
+		delete(s.pads, id)
+	}
+}
+
+// handleController processes the events of game controllers. SDL reports the controllers which are
+// connected at start-up as added as well, so all of them are opened here.
+func (s *SDLInput) handleController(event sdl.Event, keyPad *[16]bool) {
+	switch e := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		switch e.Type {
+		case sdl.CONTROLLERDEVICEADDED:
+			s.openController(int(e.Which))
+		case sdl.CONTROLLERDEVICEREMOVED:
+			s.closeController(e.Which, keyPad)
+		}
+	case *sdl.ControllerButtonEvent:
+		p, found := s.pads[e.Which]
+		if !found {
+			return
+		}
+		button := sdl.GameControllerButton(e.Button)
+		if e.Type == sdl.CONTROLLERBUTTONDOWN {
+			if key, ok := s.lookupButton(button); ok {
+				p.buttons[button] = key
+				s.press(key, true, keyPad)
+			}
+		} else if key, ok := p.buttons[button]; ok {
+			delete(p.buttons, button)
+			s.press(key, false, keyPad)
+		}
+	case *sdl.ControllerAxisEvent:
+		p, found := s.pads[e.Which]
+		if !found {
+			return
+		}
+		axis := sdl.GameControllerAxis(e.Axis)
+		for _, dir := range []axisDirection{{axis, true}, {axis, false}} {
+			active := e.Value > deadZone
+			if dir.negative {
+				active = e.Value < -deadZone
+			}
+
+			key, held := p.axes[dir]
+			switch {
+			case active && !held:
+				if key, ok := s.lookupAxis(dir); ok {
+					p.axes[dir] = key
+					s.press(key, true, keyPad)
+				}
+			case !active && held:
+				delete(p.axes, dir)
+				s.press(key, false, keyPad)
+			}
+		}
+	}
+}
+
+// openController opens the controller with the device index, unless it is a joystick SDL has no
+// mapping for.
+func (s *SDLInput) openController(index int) {
+	if !sdl.IsGameController(index) {
+		return
+	}
+	controller := sdl.GameControllerOpen(index)
+	if controller == nil {
+		return
+	}
+
+	id := controller.Joystick().InstanceID()
+	if _, found := s.pads[id]; found {
+		controller.Close()
+		return
+	}
+	if s.pads == nil {
+		s.pads = map[sdl.JoystickID]*pad{}
+	}
+	s.pads[id] = &pad{
+		controller: controller,
+		buttons:    map[sdl.GameControllerButton]uint8{},
+		axes:       map[axisDirection]uint8{},
+	}
+}
+
+// closeController closes the unplugged controller and releases the CHIP-8 keys it held down.
+func (s *SDLInput) closeController(id sdl.JoystickID, keyPad *[16]bool) {
+	p, found := s.pads[id]
+	if !found {
+		return
+	}
+	for _, key := range p.buttons {
+		s.press(key, false, keyPad)
+	}
+	for _, key := range p.axes {
+		s.press(key, false, keyPad)
+	}
+	p.controller.Close()
+	delete(s.pads, id)
+}
+
+// lookupButton returns the CHIP-8 key bound to the controller button.
+func (s *SDLInput) lookupButton(button sdl.GameControllerButton) (uint8, bool) {
+	if s.buttons == nil {
+		key, found := padButtons[button]
+		return key, found
+	}
+	key, found := s.buttons[button]
+	return key, found
+}
+
+// lookupAxis returns the CHIP-8 key bound to the direction of the controller axis.
+func (s *SDLInput) lookupAxis(dir axisDirection) (uint8, bool) {
+	if s.axes == nil {
+		key, found := padAxes[dir]
+		return key, found
+	}
+	key, found := s.axes[dir]
+	return key, found
+}
@@ -1,15 +1,167 @@
+//go:build !js
+
 package input
 
-import "github.com/veandco/go-sdl2/sdl"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// keyMap holds the default layout. It is based on scancodes, so that the keys keep their physical
+// position on QWERTZ, AZERTY and other layouts.
+var keyMap = map[sdl.Scancode]uint8{
+	sdl.SCANCODE_1: 0x1, sdl.SCANCODE_2: 0x2, sdl.SCANCODE_3: 0x3, sdl.SCANCODE_4: 0xC,
+	sdl.SCANCODE_Q: 0x4, sdl.SCANCODE_W: 0x5, sdl.SCANCODE_E: 0x6, sdl.SCANCODE_R: 0xD,
+	sdl.SCANCODE_A: 0x7, sdl.SCANCODE_S: 0x8, sdl.SCANCODE_D: 0x9, sdl.SCANCODE_F: 0xE,
+	sdl.SCANCODE_Z: 0xA, sdl.SCANCODE_X: 0x0, sdl.SCANCODE_C: 0xB, sdl.SCANCODE_V: 0xF,
+}
+
+// buttonKeys holds the host keys of the buttons named by the ROM database.
+var buttonKeys = map[string]sdl.Scancode{
+	"up": sdl.SCANCODE_UP, "down": sdl.SCANCODE_DOWN, "left": sdl.SCANCODE_LEFT, "right": sdl.SCANCODE_RIGHT,
+	"a": sdl.SCANCODE_SPACE, "b": sdl.SCANCODE_LSHIFT,
+	"player2Up": sdl.SCANCODE_I, "player2Down": sdl.SCANCODE_K, "player2Left": sdl.SCANCODE_J, "player2Right": sdl.SCANCODE_L,
+	"player2A": sdl.SCANCODE_O, "player2B": sdl.SCANCODE_P,
+}
+
+type SDLInput struct {
+	scancodes map[sdl.Scancode]uint8 // Physical keys bound to CHIP-8 keys, the default layout if nil
+	keycodes  map[sdl.Keycode]uint8  // Keys bound to CHIP-8 keys by their meaning in the current layout
+	hotkeys   map[sdl.Keycode]func() // Host keys bound by Hotkey
+	held      [16]int                // Number of host keys held down per CHIP-8 key
+
+	buttons map[sdl.GameControllerButton]uint8 // Controller buttons bound to CHIP-8 keys, the default layout if nil
+	axes    map[axisDirection]uint8            // Controller axes bound to CHIP-8 keys
+	pads    map[sdl.JoystickID]*pad            // Opened controllers by their instance id
+
+	keypad   func(x, y int32) (uint8, bool) // Finds the key of an on-screen keypad at a point of the window
+	clicked  uint8                          // Key of the on-screen keypad which is held down by the mouse
+	clicking bool
+}
+
+// Hotkey binds a host key to an action of the emulator, e.g. switching the palette. The action is
+// called when the key is pressed. A hotkey takes precedence over the CHIP-8 keys bound to the same key.
+func (s *SDLInput) Hotkey(key sdl.Keycode, action func()) {
+	if s.hotkeys == nil {
+		s.hotkeys = map[sdl.Keycode]func(){}
+	}
+	s.hotkeys[key] = action
+}
+
+// Keypad enables clicking the keys of an on-screen keypad. key returns the CHIP-8 key at a point of
+// the window. Touches are handled as well, since SDL reports them as mouse clicks.
+func (s *SDLInput) Keypad(key func(x, y int32) (uint8, bool)) {
+	s.keypad = key
+}
+
+// BindButtons binds the host keys of the named buttons (arrow keys, space and left shift for the first
+// player, IJKL, O and P for the second one) to the given CHIP-8 keys. The buttons of the first player
+// are bound on game controllers as well, to the D-pad, the left stick and the buttons A and B.
+// The hex keypad stays available. Unknown button names are ignored.
+func (s *SDLInput) BindButtons(buttons map[string]uint8) {
+	s.init()
+	for name, key := range buttons {
+		if hostKey, found := buttonKeys[name]; found {
+			s.scancodes[hostKey] = key & 0xF
+		}
+		if controls, found := buttonControls[name]; found {
+			for _, button := range controls.buttons {
+				s.buttons[button] = key & 0xF
+			}
+			for _, axis := range controls.axes {
+				s.axes[axis] = key & 0xF
+			}
+		}
+	}
+}
+
+// BindKeys binds host keys to the CHIP-8 keys 0 - F, replacing the previous bindings of these CHIP-8
+// keys. A CHIP-8 key may be bound to several host keys. Host keys are given by their SDL scancode name,
+// e.g. "Q" or "Keypad 7", and therefore denote the physical position on the keyboard. With the prefix
+// "key:" they denote the key with that label in the current layout instead, e.g. "key:Z".
+// Invalid bindings are skipped and reported by the returned error.
+func (s *SDLInput) BindKeys(bindings map[string][]string) error {
+	s.init()
+
+	var errs []error
+	for name, hostKeys := range bindings {
+		key, err := strconv.ParseUint(name, 16, 4)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid CHIP-8 key: %s", name))
+			continue
+		}
+
+		for scancode, k := range s.scancodes {
+			if k == uint8(key) {
+				delete(s.scancodes, scancode)
+			}
+		}
+		for keycode, k := range s.keycodes {
+			if k == uint8(key) {
+				delete(s.keycodes, keycode)
+			}
+		}
+
+		for _, hostKey := range hostKeys {
+			if label, found := strings.CutPrefix(hostKey, "key:"); found {
+				keycode := sdl.GetKeyFromName(label)
+				if keycode == sdl.K_UNKNOWN {
+					errs = append(errs, fmt.Errorf("unknown key: %s", hostKey))
+					continue
+				}
+				s.keycodes[keycode] = uint8(key)
+				continue
+			}
+
+			scancode := sdl.GetScancodeFromName(hostKey)
+			if scancode == sdl.SCANCODE_UNKNOWN {
+				errs = append(errs, fmt.Errorf("unknown key: %s", hostKey))
+				continue
+			}
+			s.scancodes[scancode] = uint8(key)
+		}
+	}
 
-var keyMap = map[sdl.Keycode]uint8{
-	sdl.K_1: 0x1, sdl.K_2: 0x2, sdl.K_3: 0x3, sdl.K_4: 0xC,
-	sdl.K_q: 0x4, sdl.K_w: 0x5, sdl.K_e: 0x6, sdl.K_r: 0xD,
-	sdl.K_a: 0x7, sdl.K_s: 0x8, sdl.K_d: 0x9, sdl.K_f: 0xE,
-	sdl.K_z: 0xA, sdl.K_x: 0x0, sdl.K_c: 0xB, sdl.K_v: 0xF,
+	return errors.Join(errs...)
 }
 
-type SDLInput struct{}
+// init copies the default layout before the first binding changes it.
+func (s *SDLInput) init() {
+	if s.scancodes != nil {
+		return
+	}
+	s.scancodes = make(map[sdl.Scancode]uint8, len(keyMap))
+	for scancode, key := range keyMap {
+		s.scancodes[scancode] = key
+	}
+	s.keycodes = map[sdl.Keycode]uint8{}
+
+	s.buttons = make(map[sdl.GameControllerButton]uint8, len(padButtons))
+	for button, key := range padButtons {
+		s.buttons[button] = key
+	}
+	s.axes = make(map[axisDirection]uint8, len(padAxes))
+	for axis, key := range padAxes {
+		s.axes[axis] = key
+	}
+}
+
+// lookup returns the CHIP-8 key bound to the host key.
+func (s *SDLInput) lookup(keysym sdl.Keysym) (uint8, bool) {
+	if s.scancodes == nil {
+		key, found := keyMap[keysym.Scancode]
+		return key, found
+	}
+	if key, found := s.keycodes[keysym.Sym]; found {
+		return key, true
+	}
+	key, found := s.scancodes[keysym.Scancode]
+	return key, found
+}
 
 func (s *SDLInput) PollKeys(keyPad *[16]bool) (quit bool) {
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
@@ -17,15 +169,43 @@ func (s *SDLInput) PollKeys(keyPad *[16]bool) (quit bool) {
 		case *sdl.QuitEvent:
 			quit = true
 		case *sdl.KeyboardEvent:
-			if idx, ok := keyMap[e.Keysym.Sym]; ok {
-				switch e.Type {
-				case sdl.KEYDOWN:
-					keyPad[idx] = true
-				case sdl.KEYUP:
-					keyPad[idx] = false
+			if action, found := s.hotkeys[e.Keysym.Sym]; found {
+				if e.Type == sdl.KEYDOWN && e.Repeat == 0 {
+					action()
 				}
+				continue
+			}
+			if idx, ok := s.lookup(e.Keysym); ok && e.Repeat == 0 {
+				s.press(idx, e.Type == sdl.KEYDOWN, keyPad)
+			}
+		case *sdl.ControllerDeviceEvent, *sdl.ControllerButtonEvent, *sdl.ControllerAxisEvent:
+			s.handleController(e, keyPad)
+		case *sdl.MouseButtonEvent:
+			if s.keypad == nil || e.Button != sdl.BUTTON_LEFT {
+				continue
+			}
+			if e.Type == sdl.MOUSEBUTTONDOWN && !s.clicking {
+				if key, ok := s.keypad(e.X, e.Y); ok {
+					s.clicked, s.clicking = key, true
+					s.press(key, true, keyPad)
+				}
+			} else if e.Type == sdl.MOUSEBUTTONUP && s.clicking {
+				// The key is released even if the mouse left it in the meantime.
+				s.clicking = false
+				s.press(s.clicked, false, keyPad)
 			}
 		}
 	}
 	return quit
 }
+
+// press updates the CHIP-8 key when a host key or controller button bound to it is pressed or released.
+// A CHIP-8 key bound to several of them is released with the last one.
+func (s *SDLInput) press(idx uint8, down bool, keyPad *[16]bool) {
+	if down {
+		s.held[idx]++
+	} else {
+		s.held[idx] = max(s.held[idx]-1, 0)
+	}
+	keyPad[idx] = s.held[idx] > 0
+}
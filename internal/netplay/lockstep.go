@@ -0,0 +1,439 @@
+// Package netplay lets two emulators on different machines play the same game, e.g. the two players
+// of Pong, each with the keys of their own keyboard.
+//
+// Both emulators run in lockstep: they start from the same state with the same random seed, exchange
+// the keys held in every frame and run a frame only once they know the keys of both players, which
+// are combined as if pressed on the same keypad. The keys are sent a few frames ahead (the input
+// delay), so that the latency of the network is hidden as long as it is shorter than the delay.
+//
+// With rollback, an emulator does not wait for the keys of the peer. It predicts that the peer still
+// holds the keys it held last and runs the frame right away. Once the keys arrive and the prediction
+// was wrong, it restores the state at the start of the mispredicted frame and runs the frames since
+// then again, before the next frame is shown. The emulators only wait for each other if one of them
+// is more frames ahead than may be rolled back.
+//
+// The emulators compare the hashes of their states periodically. If they differ, the host sends its
+// state and the guest continues with it.
+package netplay
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/input"
+)
+
+const (
+	// DefaultDelay is the input delay in frames of lockstep if none is given. It hides a latency of 50 ms.
+	DefaultDelay = 3
+	// hashInterval is the number of frames between two comparisons of the states.
+	hashInterval = 60
+	// inputWindow is the number of frames the inputs are kept for, which must cover the frames the
+	// guest may run ahead of the host plus the input delay.
+	inputWindow = 256
+	// timeout is the time without a message from the peer after which it is considered gone.
+	timeout = 10 * time.Second
+)
+
+// frameInput holds the keys of a player during a frame.
+type frameInput struct {
+	frame uint64
+	keys  uint16
+	valid bool
+}
+
+// inputs holds the inputs of the most recent frames of a player.
+type inputs [inputWindow]frameInput
+
+func (in *inputs) set(frame uint64, keys uint16) {
+	in[frame%inputWindow] = frameInput{frame: frame, keys: keys, valid: true}
+}
+
+func (in *inputs) get(frame uint64) (uint16, bool) {
+	i := in[frame%inputWindow]
+	return i.keys, i.valid && i.frame == frame
+}
+
+// Options configures a session. The host chooses them, the guest uses the options of the host.
+type Options struct {
+	Delay    int    // Input delay in frames, DefaultDelay if zero in lockstep and no delay with rollback
+	Rollback int    // Maximum number of frames the input of the peer is predicted for, lockstep if zero
+	Seed     uint64 // Seed of the random number generator, chosen from the clock if zero
+}
+
+// epochHash is the hash of a state and the number of resyncs which preceded it.
+type epochHash struct {
+	epoch uint32
+	hash  uint64
+}
+
+// Session connects the emulator to the one of the peer. It is the input handler of the emulator and
+// reads the keys of the local player from the input handler given to Host or Join.
+type Session struct {
+	c8       *chip8.Chip8
+	local    input.InputHandler
+	conn     net.Conn
+	reader   *bufio.Reader
+	writer   *bufio.Writer
+	host     bool
+	delay    int
+	rollback int
+
+	incoming chan message
+	readErr  chan error
+
+	localKeys [16]bool
+	sent      inputs // Keys of the local player, by frame
+	received  inputs // Keys of the peer, by frame
+	nextSend  uint64 // Next frame whose keys have to be sent
+
+	used         inputs                    // Keys of the peer the frames ran with, predicted or received
+	lastRemote   uint16                    // Keys of the peer in the latest frame received, the prediction for the following ones
+	latest       uint64                    // Latest frame whose keys were received, plus one
+	confirmed    uint64                    // First frame which did not run with the received keys of the peer yet
+	rollbackFrom uint64                    // First frame to run again, noRollback if none
+	snapshots    [inputWindow]*chip8.State // States at the start of the recent frames
+	nextHash     uint64                    // Next frame whose state is hashed once it is confirmed
+	rollbacks    int
+
+	epoch        uint32 // Number of resyncs
+	hashes       map[uint64]epochHash
+	remoteHashes map[uint64]epochHash
+	resyncs      int
+
+	err error
+}
+
+// Host starts a session on a connection accepted from the guest. c8 must not have loaded the ROM
+// yet, it is seeded for both emulators. Its quirks and tickrate are used by the guest as well. local
+// reads the keys of the local player.
+func Host(conn net.Conn, c8 *chip8.Chip8, rom []byte, local input.InputHandler, opts Options) (*Session, error) {
+	if opts.Delay <= 0 && opts.Rollback <= 0 {
+		opts.Delay = DefaultDelay
+	}
+	s := newSession(conn, c8, local, true, max(opts.Delay, 0), min(max(opts.Rollback, 0), MaxRollback))
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	err := writeHello(s.writer, hello{
+		Version:  protocolVersion,
+		ROM:      romHash(rom),
+		Seed:     seed,
+		Delay:    s.delay,
+		Rollback: s.rollback,
+		Tickrate: c8.Tickrate,
+		Quirks:   c8.Quirks,
+	})
+	if err == nil {
+		err = s.writer.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the handshake failed: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	reply, err := readHello(s.reader)
+	if err != nil {
+		return nil, err
+	}
+	if reply.ROM != romHash(rom) {
+		return nil, fmt.Errorf("the guest loaded a different ROM")
+	}
+
+	c8.Seed(seed)
+	s.start()
+	return s, nil
+}
+
+// Join starts a session on a connection to the host. c8 must not have loaded the ROM yet, it is
+// seeded and configured with the quirks and tickrate of the host.
+func Join(conn net.Conn, c8 *chip8.Chip8, rom []byte, local input.InputHandler) (*Session, error) {
+	s := newSession(conn, c8, local, false, 0, 0)
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	h, err := readHello(s.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// The guest answers even if the ROMs differ, so that the host can report it as well.
+	err = writeHello(s.writer, hello{Version: protocolVersion, ROM: romHash(rom)})
+	if err == nil {
+		err = s.writer.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the handshake failed: %w", err)
+	}
+	if h.ROM != romHash(rom) {
+		return nil, fmt.Errorf("the host loaded a different ROM")
+	}
+
+	s.delay = h.Delay
+	s.rollback = h.Rollback
+	c8.Seed(h.Seed)
+	c8.Tickrate = h.Tickrate
+	c8.Quirks = h.Quirks
+	s.start()
+	return s, nil
+}
+
+func newSession(conn net.Conn, c8 *chip8.Chip8, local input.InputHandler, host bool, delay, rollback int) *Session {
+	return &Session{
+		c8:           c8,
+		local:        local,
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		writer:       bufio.NewWriter(conn),
+		incoming:     make(chan message, 2*inputWindow),
+		readErr:      make(chan error, 1),
+		host:         host,
+		delay:        delay,
+		rollback:     rollback,
+		rollbackFrom: noRollback,
+		hashes:       map[uint64]epochHash{},
+		remoteHashes: map[uint64]epochHash{},
+	}
+}
+
+// start reads the messages of the peer in the background.
+func (s *Session) start() {
+	s.conn.SetReadDeadline(time.Time{})
+	go func() {
+		for {
+			m, err := readMessage(s.reader)
+			if err != nil {
+				s.readErr <- err
+				close(s.incoming)
+				return
+			}
+			s.incoming <- m
+		}
+	}()
+}
+
+func romHash(rom []byte) string {
+	h := sha1.Sum(rom)
+	return hex.EncodeToString(h[:])
+}
+
+// PollKeys reads the keys of the local player, sends them to the peer and waits for the keys of the
+// peer for the current frame. It reports quit if the local player quits or the connection is lost,
+// Err tells the reason then.
+func (s *Session) PollKeys(keyPad *[16]bool) (quit bool) {
+	if s.err != nil {
+		return true
+	}
+	if s.local != nil && s.local.PollKeys(&s.localKeys) {
+		s.Close()
+		return true
+	}
+	if s.rollback > 0 {
+		return s.pollRollback(keyPad)
+	}
+
+	if frame := s.c8.Frame(); frame%hashInterval == 0 {
+		h := epochHash{s.epoch, s.c8.SaveState().Hash()}
+		if _, done := s.hashes[frame]; !done {
+			s.hashes[frame] = h
+			s.send(msgHash, hashPayload(frame, h.epoch, h.hash))
+			s.compare(frame)
+		}
+		s.dropHashes(frame)
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		frame := s.c8.Frame()
+		// After a resync the guest may have to send the keys of further frames.
+		if !s.sendKeys(frame) {
+			return true
+		}
+
+		local, _ := s.sent.get(frame)
+		if remote, ok := s.received.get(frame); ok {
+			*keyPad = unpackKeys(local | remote)
+			return false
+		}
+
+		if !s.receive(deadline.C) {
+			return true
+		}
+	}
+}
+
+// sendKeys sends the keys of the local player for the frames up to the input delay ahead of frame.
+// It reports false if the connection is lost.
+func (s *Session) sendKeys(frame uint64) bool {
+	for ; s.nextSend <= frame+uint64(s.delay); s.nextSend++ {
+		keys := packKeys(s.localKeys)
+		s.sent.set(s.nextSend, keys)
+		s.send(msgInput, inputPayload(s.nextSend, keys))
+	}
+	if s.err == nil {
+		if err := s.writer.Flush(); err != nil {
+			s.fail(fmt.Errorf("connection lost: %w", err))
+		}
+	}
+	return s.err == nil
+}
+
+// receive waits for the next message of the peer and handles it. It reports false if the connection
+// is lost or the deadline passes.
+func (s *Session) receive(deadline <-chan time.Time) bool {
+	select {
+	case m, ok := <-s.incoming:
+		if !ok {
+			s.fail(fmt.Errorf("connection lost: %w", <-s.readErr))
+			return false
+		}
+		if err := s.handle(m); err != nil {
+			s.fail(err)
+			return false
+		}
+		return true
+	case <-deadline:
+		s.fail(fmt.Errorf("connection lost: the peer did not respond for %v", timeout))
+		return false
+	}
+}
+
+// dropHashes drops the hashes the peer will not send any more, e.g. of frames skipped by a resync.
+func (s *Session) dropHashes(frame uint64) {
+	for _, hashes := range []map[uint64]epochHash{s.hashes, s.remoteHashes} {
+		for f := range hashes {
+			if f+inputWindow < frame {
+				delete(hashes, f)
+			}
+		}
+	}
+}
+
+// handle processes a message of the peer.
+func (s *Session) handle(m message) error {
+	switch m.kind {
+	case msgInput:
+		frame, keys, err := parseInput(m.payload)
+		if err != nil {
+			return err
+		}
+		s.received.set(frame, keys)
+		if frame >= s.latest {
+			s.latest, s.lastRemote = frame+1, keys
+		}
+	case msgHash:
+		frame, epoch, hash, err := parseHash(m.payload)
+		if err != nil {
+			return err
+		}
+		s.remoteHashes[frame] = epochHash{epoch, hash}
+		s.compare(frame)
+	case msgState:
+		if s.host || len(m.payload) < 4 {
+			return fmt.Errorf("unexpected state message")
+		}
+		var state chip8.State
+		if err := state.UnmarshalBinary(m.payload[4:]); err != nil {
+			return err
+		}
+		s.epoch = binary.BigEndian.Uint32(m.payload)
+		if s.rollback > 0 {
+			// The state is of a frame which already ran, the frames since then run again.
+			if !s.snapshot(&state) {
+				return fmt.Errorf("cannot resync with the state of frame %d", state.Frame())
+			}
+		} else {
+			s.c8.LoadState(&state)
+		}
+		s.resyncs++
+		clear(s.hashes)
+		slog.Info(fmt.Sprintf("netplay: resynced with the host at frame %d", state.Frame()))
+	default:
+		return fmt.Errorf("unexpected message type %d", m.kind)
+	}
+	return nil
+}
+
+// compare compares the hashes of the frame once both are known. If they differ, the host sends its
+// state. Hashes taken before the last resync are not compared.
+func (s *Session) compare(frame uint64) {
+	own, ok := s.hashes[frame]
+	remote, remoteOk := s.remoteHashes[frame]
+	if !ok || !remoteOk {
+		return
+	}
+	delete(s.hashes, frame)
+	delete(s.remoteHashes, frame)
+	if own.epoch != s.epoch || remote.epoch != s.epoch || own.hash == remote.hash {
+		return
+	}
+
+	slog.Warn(fmt.Sprintf("netplay: the emulators desynchronized at frame %d", frame))
+	if !s.host {
+		return
+	}
+	state := s.c8.SaveState()
+	if s.rollback > 0 {
+		// The current state may rest on predicted keys, the state of the frame compared does not.
+		state = s.snapshots[frame%inputWindow]
+		if state == nil || state.Frame() != frame {
+			return
+		}
+	}
+	s.epoch++
+	s.resyncs++
+	data, err := state.MarshalBinary()
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.send(msgState, append(binary.BigEndian.AppendUint32(nil, s.epoch), data...))
+}
+
+// send writes a message, unless the session failed. It is flushed before waiting for the peer.
+func (s *Session) send(kind byte, payload []byte) {
+	if s.err != nil {
+		return
+	}
+	if err := writeMessage(s.writer, kind, payload); err != nil {
+		s.fail(fmt.Errorf("connection lost: %w", err))
+	}
+}
+
+func (s *Session) fail(err error) {
+	if s.err == nil {
+		s.err = err
+		s.conn.Close()
+	}
+}
+
+// Resyncs returns the number of times the state was resynchronized.
+func (s *Session) Resyncs() int {
+	return s.resyncs
+}
+
+// Err returns the reason the session ended, nil if it did not end or the local player quit.
+func (s *Session) Err() error {
+	if errors.Is(s.err, errClosed) {
+		return nil
+	}
+	return s.err
+}
+
+var errClosed = errors.New("session closed")
+
+// Close ends the session.
+func (s *Session) Close() error {
+	s.fail(errClosed)
+	return nil
+}
@@ -0,0 +1,173 @@
+package netplay
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// randomGame draws dots at random positions and clears the screen while key 5 is held, so that the
+// display depends on the random numbers and the input.
+var randomGame = []byte{
+	0xA2, 0x10, // 0x200: LD I, 0x210
+	0xC0, 0x3F, // 0x202: RND V0, 0x3F
+	0xC1, 0x1F, // 0x204: RND V1, 0x1F
+	0xD0, 0x11, // 0x206: DRW V0, V1, 1
+	0x62, 0x05, // 0x208: LD V2, 5
+	0xE2, 0xA1, // 0x20A: SKNP V2
+	0x00, 0xE0, // 0x20C: CLS
+	0x12, 0x02, // 0x20E: JP 0x202
+	0x80, // 0x210: sprite of a single pixel
+}
+
+// scriptedInput holds the keys given by script for the current frame of the emulator.
+type scriptedInput struct {
+	c8     *chip8.Chip8
+	script func(frame uint64) [16]bool
+}
+
+func (s *scriptedInput) PollKeys(keyPad *[16]bool) bool {
+	*keyPad = s.script(s.c8.Frame())
+	return false
+}
+
+// hostKeys and guestKeys are the keys the players hold in a frame.
+func hostKeys(frame uint64) [16]bool {
+	return [16]bool{5: frame >= 100 && frame < 102}
+}
+
+func guestKeys(frame uint64) [16]bool {
+	return [16]bool{5: frame >= 50 && frame < 52}
+}
+
+// connect starts a session between two emulators connected by hostConn and guestConn.
+func connect(t *testing.T, hostConn, guestConn net.Conn, opts Options, hostROM, guestROM []byte) (host, guest *chip8.Chip8, hostSession, guestSession *Session, hostErr, guestErr error) {
+	t.Helper()
+	host = &chip8.Chip8{Tickrate: 30, Quirks: chip8.Quirks{VBlank: true}}
+	guest = &chip8.Chip8{}
+	hostInput := &scriptedInput{c8: host, script: hostKeys}
+	guestInput := &scriptedInput{c8: guest, script: guestKeys}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hostSession, hostErr = Host(hostConn, host, hostROM, hostInput, opts)
+	}()
+	guestSession, guestErr = Join(guestConn, guest, guestROM, guestInput)
+	if guestErr != nil {
+		guestConn.Close()
+	}
+	wg.Wait()
+	if hostSession != nil && guestSession != nil {
+		host.Input, guest.Input = hostSession, guestSession
+	}
+	return host, guest, hostSession, guestSession, hostErr, guestErr
+}
+
+// pipe returns the ends of a pipe which are closed at the end of the test.
+func pipe(t *testing.T) (a, b net.Conn) {
+	a, b = net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+// run runs the emulators up to the given frame. A resync may set an emulator back to an earlier frame.
+func run(t *testing.T, frames int, emulators ...*chip8.Chip8) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, c8 := range emulators {
+		if err := c8.Load(randomGame); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c8.Running() && c8.Frame() < uint64(frames) {
+				c8.Tick()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLockstep(t *testing.T) {
+	hostConn, guestConn := pipe(t)
+	host, guest, hostSession, guestSession, hostErr, guestErr := connect(t, hostConn, guestConn, Options{Delay: 2}, randomGame, randomGame)
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("unexpected errors: %v, %v", hostErr, guestErr)
+	}
+	if guest.Tickrate != 30 || !guest.Quirks.VBlank {
+		t.Errorf("Expected the guest to use the tickrate and quirks of the host")
+	}
+
+	run(t, 150, host, guest)
+
+	if host.Frame() != 150 || guest.Frame() != 150 {
+		t.Fatalf("Expected both emulators at frame 150 but got %d and %d", host.Frame(), guest.Frame())
+	}
+	if host.SaveState().Hash() != guest.SaveState().Hash() {
+		t.Errorf("Expected both emulators to have the same state")
+	}
+	if hostSession.Resyncs() != 0 || guestSession.Resyncs() != 0 {
+		t.Errorf("Expected no resync but got %d and %d", hostSession.Resyncs(), guestSession.Resyncs())
+	}
+}
+
+func TestResync(t *testing.T) {
+	hostConn, guestConn := pipe(t)
+	host, guest, hostSession, guestSession, hostErr, guestErr := connect(t, hostConn, guestConn, Options{Delay: 2}, randomGame, randomGame)
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("unexpected errors: %v, %v", hostErr, guestErr)
+	}
+	// A different seed makes the guest draw other dots.
+	guest.Seed(12345)
+
+	run(t, 150, host, guest)
+
+	if host.SaveState().Hash() != guest.SaveState().Hash() {
+		t.Errorf("Expected both emulators to have the same state after the resync")
+	}
+	if hostSession.Resyncs() != 1 || guestSession.Resyncs() != 1 {
+		t.Errorf("Expected a single resync but got %d and %d", hostSession.Resyncs(), guestSession.Resyncs())
+	}
+}
+
+func TestDifferentROM(t *testing.T) {
+	hostConn, guestConn := pipe(t)
+	_, _, _, _, hostErr, guestErr := connect(t, hostConn, guestConn, Options{Delay: 2}, randomGame, []byte{0x12, 0x00})
+	if hostErr == nil || !strings.Contains(hostErr.Error(), "different ROM") {
+		t.Errorf("Expected the host to report a different ROM but got %v", hostErr)
+	}
+	if guestErr == nil || !strings.Contains(guestErr.Error(), "different ROM") {
+		t.Errorf("Expected the guest to report a different ROM but got %v", guestErr)
+	}
+}
+
+func TestConnectionLost(t *testing.T) {
+	hostConn, guestConn := pipe(t)
+	host, guest, hostSession, guestSession, hostErr, guestErr := connect(t, hostConn, guestConn, Options{Delay: 2}, randomGame, randomGame)
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("unexpected errors: %v, %v", hostErr, guestErr)
+	}
+	run(t, 10, host, guest)
+
+	hostSession.Close()
+	if err := hostSession.Err(); err != nil {
+		t.Errorf("Expected no error after closing but got %v", err)
+	}
+
+	var keys [16]bool
+	if !guestSession.PollKeys(&keys) {
+		t.Errorf("Expected the guest to quit")
+	}
+	if guestSession.Err() == nil {
+		t.Errorf("Expected the guest to report the lost connection")
+	}
+}
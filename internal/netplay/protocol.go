@@ -0,0 +1,134 @@
+package netplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// protocolVersion is sent in the handshake, peers with different versions cannot play together.
+const protocolVersion = 1
+
+// The types of the messages. Every message is a type byte, the length of the payload as uint32 and
+// the payload.
+const (
+	msgHello = iota + 1 // JSON encoded hello
+	msgInput            // Frame (uint64) and the keys of the sender held during it (uint16, bit n is key n)
+	msgHash             // Frame (uint64), resync epoch (uint32) and hash of the state at the start of the frame (uint64)
+	msgState            // Resync epoch (uint32) and the encoded state the receiver continues with
+)
+
+// maxPayload limits the size of a message, the largest one is a save state.
+const maxPayload = 64 << 10
+
+// hello is the handshake. The host sends the settings both emulators must share, the guest answers
+// with the hash of its ROM.
+type hello struct {
+	Version  int          `json:"version"`
+	ROM      string       `json:"rom"` // SHA-1 hash of the ROM
+	Seed     uint64       `json:"seed,omitempty"`
+	Delay    int          `json:"delay,omitempty"`
+	Rollback int          `json:"rollback,omitempty"`
+	Tickrate int          `json:"tickrate,omitempty"`
+	Quirks   chip8.Quirks `json:"quirks"`
+}
+
+type message struct {
+	kind    byte
+	payload []byte
+}
+
+func writeMessage(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func readMessage(r *bufio.Reader) (message, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return message{}, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxPayload {
+		return message{}, fmt.Errorf("message of %d bytes exceeds the limit", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return message{}, err
+	}
+	return message{kind: header[0], payload: payload}, nil
+}
+
+func writeHello(w io.Writer, h hello) error {
+	payload, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, msgHello, payload)
+}
+
+func readHello(r *bufio.Reader) (hello, error) {
+	m, err := readMessage(r)
+	if err != nil {
+		return hello{}, fmt.Errorf("the handshake failed: %w", err)
+	}
+	var h hello
+	if m.kind != msgHello || json.Unmarshal(m.payload, &h) != nil {
+		return hello{}, fmt.Errorf("the handshake failed: the peer is no go-acht emulator")
+	}
+	if h.Version != protocolVersion {
+		return hello{}, fmt.Errorf("the handshake failed: the peer uses protocol version %d instead of %d", h.Version, protocolVersion)
+	}
+	return h, nil
+}
+
+func inputPayload(frame uint64, keys uint16) []byte {
+	payload := binary.BigEndian.AppendUint64(nil, frame)
+	return binary.BigEndian.AppendUint16(payload, keys)
+}
+
+func parseInput(payload []byte) (frame uint64, keys uint16, err error) {
+	if len(payload) != 10 {
+		return 0, 0, fmt.Errorf("invalid input message")
+	}
+	return binary.BigEndian.Uint64(payload), binary.BigEndian.Uint16(payload[8:]), nil
+}
+
+func hashPayload(frame uint64, epoch uint32, hash uint64) []byte {
+	payload := binary.BigEndian.AppendUint64(nil, frame)
+	payload = binary.BigEndian.AppendUint32(payload, epoch)
+	return binary.BigEndian.AppendUint64(payload, hash)
+}
+
+func parseHash(payload []byte) (frame uint64, epoch uint32, hash uint64, err error) {
+	if len(payload) != 20 {
+		return 0, 0, 0, fmt.Errorf("invalid hash message")
+	}
+	return binary.BigEndian.Uint64(payload), binary.BigEndian.Uint32(payload[8:]), binary.BigEndian.Uint64(payload[12:]), nil
+}
+
+// packKeys returns the keys as bit mask, bit n is set if key n is held.
+func packKeys(keys [16]bool) uint16 {
+	var bits uint16
+	for i, down := range keys {
+		if down {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+func unpackKeys(bits uint16) [16]bool {
+	var keys [16]bool
+	for i := range keys {
+		keys[i] = bits&(1<<i) != 0
+	}
+	return keys
+}
@@ -0,0 +1,166 @@
+package netplay
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// MaxRollback is the maximum number of frames which may be rolled back, one second.
+const MaxRollback = 60
+
+// noRollback is the value of rollbackFrom if no frame has to run again.
+const noRollback = math.MaxUint64
+
+// pollRollback sends the keys of the local player, rolls back the frames which ran with mispredicted
+// keys of the peer and returns the keys for the current frame, predicting those of the peer if they
+// did not arrive yet. It only waits for the peer if it is ahead by more than the rollback window.
+func (s *Session) pollRollback(keyPad *[16]bool) (quit bool) {
+	var deadline *time.Timer
+	for {
+		if !s.drain() {
+			return true
+		}
+		frame := s.c8.Frame()
+		if !s.sendKeys(frame) {
+			return true
+		}
+
+		s.confirm(frame)
+		if s.rollbackFrom < frame {
+			if err := s.resimulate(frame); err != nil {
+				s.fail(err)
+				return true
+			}
+		}
+		s.hashConfirmed(frame)
+
+		if frame-s.confirmed <= uint64(s.rollback) {
+			break
+		}
+
+		// Too far ahead of the peer, the emulator waits like in lockstep.
+		if deadline == nil {
+			deadline = time.NewTimer(timeout)
+			defer deadline.Stop()
+		}
+		if !s.receive(deadline.C) {
+			return true
+		}
+	}
+
+	frame := s.c8.Frame()
+	s.snapshots[frame%inputWindow] = s.c8.SaveState()
+	*keyPad = unpackKeys(s.keys(frame))
+	return false
+}
+
+// drain handles the messages which arrived so far without waiting for more. It reports false if the
+// connection is lost.
+func (s *Session) drain() bool {
+	for {
+		select {
+		case m, ok := <-s.incoming:
+			if !ok {
+				s.fail(fmt.Errorf("connection lost: %w", <-s.readErr))
+				return false
+			}
+			if err := s.handle(m); err != nil {
+				s.fail(err)
+				return false
+			}
+		default:
+			return true
+		}
+	}
+}
+
+// keys returns the keys of both players in the frame and records the keys of the peer the frame runs
+// with. The keys of the peer are predicted if they did not arrive yet.
+func (s *Session) keys(frame uint64) uint16 {
+	remote, ok := s.received.get(frame)
+	if !ok {
+		remote = s.lastRemote
+	}
+	s.used.set(frame, remote)
+	local, _ := s.sent.get(frame)
+	return local | remote
+}
+
+// confirm advances the confirmed frames over the frames before frame whose keys arrived. If a frame
+// ran with other keys than the received ones, it has to run again.
+func (s *Session) confirm(frame uint64) {
+	for ; s.confirmed < frame; s.confirmed++ {
+		remote, ok := s.received.get(s.confirmed)
+		if !ok {
+			return
+		}
+		if used, _ := s.used.get(s.confirmed); used != remote {
+			s.rollbackFrom = min(s.rollbackFrom, s.confirmed)
+		}
+	}
+}
+
+// resimulate restores the state at the start of the first frame to run again and runs the frames up to
+// frame once more, with the keys known now. Nothing is drawn meanwhile.
+func (s *Session) resimulate(frame uint64) error {
+	from := s.rollbackFrom
+	s.rollbackFrom = noRollback
+
+	state := s.snapshots[from%inputWindow]
+	if state == nil || state.Frame() != from {
+		return fmt.Errorf("cannot roll back to frame %d", from)
+	}
+	s.c8.LoadState(state)
+	for f := from; f < frame; f++ {
+		if f != from {
+			s.snapshots[f%inputWindow] = s.c8.SaveState()
+		}
+		keys := unpackKeys(s.keys(f))
+		for key, down := range keys {
+			s.c8.SetKey(uint8(key), down)
+		}
+		s.c8.StepFrame()
+	}
+	s.rollbacks++
+	return nil
+}
+
+// snapshot replaces the state at the start of a frame which already ran, e.g. by the state of the
+// host after a desync, so that the frames since then run again. It reports false if the frame is out
+// of the rollback window.
+func (s *Session) snapshot(state *chip8.State) bool {
+	frame := state.Frame()
+	if frame > s.c8.Frame() || frame+inputWindow <= s.c8.Frame() {
+		return false
+	}
+	if frame == s.c8.Frame() {
+		s.c8.LoadState(state)
+		return true
+	}
+	s.snapshots[frame%inputWindow] = state
+	s.rollbackFrom = min(s.rollbackFrom, frame)
+	return true
+}
+
+// hashConfirmed sends the hashes of the states which no longer depend on predicted keys.
+func (s *Session) hashConfirmed(frame uint64) {
+	for ; s.nextHash <= s.confirmed && s.nextHash < frame; s.nextHash += hashInterval {
+		state := s.snapshots[s.nextHash%inputWindow]
+		if state == nil || state.Frame() != s.nextHash {
+			continue
+		}
+		h := epochHash{s.epoch, state.Hash()}
+		s.hashes[s.nextHash] = h
+		s.send(msgHash, hashPayload(s.nextHash, h.epoch, h.hash))
+		s.compare(s.nextHash)
+	}
+	s.dropHashes(frame)
+}
+
+// Rollbacks returns the number of times frames ran again because the keys of the peer were mispredicted.
+func (s *Session) Rollbacks() int {
+	return s.rollbacks
+}
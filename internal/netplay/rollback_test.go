@@ -0,0 +1,106 @@
+package netplay
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+)
+
+// reference runs the game on a single emulator with the keys of both players up to the given frame.
+func reference(t *testing.T, frames int, seed uint64, config *chip8.Chip8) *chip8.Chip8 {
+	t.Helper()
+	c8 := &chip8.Chip8{Tickrate: config.Tickrate, Quirks: config.Quirks}
+	c8.Seed(seed)
+	if err := c8.Load(randomGame); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for frame := range uint64(frames) {
+		host, guest := hostKeys(frame), guestKeys(frame)
+		for key := range host {
+			c8.SetKey(uint8(key), host[key] || guest[key])
+		}
+		c8.StepFrame()
+	}
+	return c8
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		guestSeed   uint64
+		wantResyncs int
+	}{
+		{"Prediction", 0, 0},
+		{"Resync", 12345, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hostConn, guestConn := SimulatedNetwork(30*time.Millisecond, 10*time.Millisecond)
+			t.Cleanup(func() {
+				hostConn.Close()
+				guestConn.Close()
+			})
+			opts := Options{Rollback: 8, Seed: 42}
+			host, guest, hostSession, guestSession, hostErr, guestErr := connect(t, hostConn, guestConn, opts, randomGame, randomGame)
+			if hostErr != nil || guestErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", hostErr, guestErr)
+			}
+			if test.guestSeed != 0 {
+				// A different seed makes the guest draw other dots.
+				guest.Seed(test.guestSeed)
+			}
+
+			run(t, 150, host, guest)
+
+			if err := hostSession.Err(); err != nil {
+				t.Fatalf("unexpected error of the host: %v", err)
+			}
+			if err := guestSession.Err(); err != nil {
+				t.Fatalf("unexpected error of the guest: %v", err)
+			}
+			want := reference(t, 150, opts.Seed, host).SaveState().Hash()
+			if host.SaveState().Hash() != want || guest.SaveState().Hash() != want {
+				t.Errorf("Expected both emulators to have the state of a single emulator with the keys of both players")
+			}
+			if hostSession.Rollbacks() == 0 || guestSession.Rollbacks() == 0 {
+				t.Errorf("Expected both emulators to roll back but got %d and %d rollbacks", hostSession.Rollbacks(), guestSession.Rollbacks())
+			}
+			if hostSession.Resyncs() != test.wantResyncs || guestSession.Resyncs() != test.wantResyncs {
+				t.Errorf("Expected %d resyncs but got %d and %d", test.wantResyncs, hostSession.Resyncs(), guestSession.Resyncs())
+			}
+		})
+	}
+}
+
+func TestSimulatedNetwork(t *testing.T) {
+	a, b := SimulatedNetwork(20*time.Millisecond, 10*time.Millisecond)
+	defer a.Close()
+	defer b.Close()
+
+	start := time.Now()
+	go func() {
+		for i := range 100 {
+			a.Write([]byte{byte(i)})
+		}
+		a.Close()
+	}()
+
+	data, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the data to arrive after at least 10ms but got %v", elapsed)
+	}
+	if len(data) != 100 {
+		t.Fatalf("Expected 100 bytes but got %d", len(data))
+	}
+	for i, got := range data {
+		if got != byte(i) {
+			t.Fatalf("Expected byte %d to be %d but got %d", i, i, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package netplay
+
+import (
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// chunk is data on its way through a simulated network.
+type chunk struct {
+	data []byte
+	due  time.Time
+}
+
+// SimulatedNetwork returns the two ends of a connection whose data arrives after latency, give or take
+// up to jitter. The data still arrives in order, as with TCP. Closing one end closes the other once
+// the data sent before arrived. It stands in for a real network to try netplay on a single machine.
+func SimulatedNetwork(latency, jitter time.Duration) (a, b net.Conn) {
+	a, aRelay := net.Pipe()
+	bRelay, b := net.Pipe()
+	go relay(aRelay, bRelay, latency, jitter)
+	go relay(bRelay, aRelay, latency, jitter)
+	return a, b
+}
+
+// relay copies the data read from src to dst, each read delayed by latency and jitter.
+func relay(src, dst net.Conn, latency, jitter time.Duration) {
+	queue := make(chan chunk, 1024)
+	go func() {
+		defer dst.Close()
+		for c := range queue {
+			time.Sleep(time.Until(c.due))
+			if _, err := dst.Write(c.data); err != nil {
+				src.Close()
+				return
+			}
+		}
+	}()
+
+	defer close(queue)
+	var last time.Time
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if err != nil {
+			return
+		}
+		delay := latency
+		if jitter > 0 {
+			delay += time.Duration(rand.Int64N(int64(2*jitter+1))) - jitter
+		}
+		// A chunk never overtakes the one before it.
+		if due := time.Now().Add(delay); due.After(last) {
+			last = due
+		}
+		queue <- chunk{data: append([]byte(nil), buf[:n]...), due: last}
+	}
+}
@@ -0,0 +1,196 @@
+package patch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const bpsMagic = "BPS1"
+
+// The actions of a BPS patch.
+const (
+	bpsSourceRead = iota // Copies bytes of the source at the current position of the target
+	bpsTargetRead        // Copies bytes of the patch
+	bpsSourceCopy        // Copies bytes of the source at a relative offset
+	bpsTargetCopy        // Copies bytes of the target written before at a relative offset
+)
+
+var errBPSTruncated = errors.New("BPS patch is truncated")
+
+// bpsReader reads the numbers of a BPS patch.
+type bpsReader struct {
+	data []byte
+	err  error
+}
+
+// number reads a variable length number.
+func (r *bpsReader) number() uint64 {
+	var n uint64
+	shift := uint64(1)
+	for {
+		if len(r.data) == 0 {
+			r.err = errBPSTruncated
+			return 0
+		}
+		x := r.data[0]
+		r.data = r.data[1:]
+		n += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return n
+		}
+		if shift > 1<<56 {
+			r.err = errors.New("BPS patch holds a number which is too large")
+			return 0
+		}
+		shift <<= 7
+		n += shift
+	}
+}
+
+// offset reads a relative offset, a number whose lowest bit is the sign.
+func (r *bpsReader) offset() int {
+	n := r.number()
+	if n&1 != 0 {
+		return -int(n >> 1)
+	}
+	return int(n >> 1)
+}
+
+func (r *bpsReader) bytes(n uint64) []byte {
+	if uint64(len(r.data)) < n {
+		r.err = errBPSTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// ApplyBPS returns the ROM with the BPS patch applied. It reports an error if the checksum of the
+// ROM, the patched ROM or the patch does not match the one in the patch.
+func ApplyBPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic)+12 || string(patch[:len(bpsMagic)]) != bpsMagic {
+		return nil, fmt.Errorf("not a BPS patch")
+	}
+	footer := patch[len(patch)-12:]
+	sourceCRC := binary.LittleEndian.Uint32(footer[0:])
+	targetCRC := binary.LittleEndian.Uint32(footer[4:])
+	patchCRC := binary.LittleEndian.Uint32(footer[8:])
+	if crc := crc32.ChecksumIEEE(patch[:len(patch)-4]); crc != patchCRC {
+		return nil, fmt.Errorf("BPS patch is corrupt: checksum %08x, expected %08x", crc, patchCRC)
+	}
+	if crc := crc32.ChecksumIEEE(rom); crc != sourceCRC {
+		return nil, fmt.Errorf("BPS patch is for another ROM: checksum %08x, expected %08x", crc, sourceCRC)
+	}
+
+	r := &bpsReader{data: patch[len(bpsMagic) : len(patch)-12]}
+	sourceSize := r.number()
+	targetSize := r.number()
+	r.bytes(r.number()) // Metadata
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != uint64(len(rom)) {
+		return nil, fmt.Errorf("BPS patch is for a ROM of %d bytes, not %d", sourceSize, len(rom))
+	}
+	if targetSize > 1<<24 {
+		return nil, fmt.Errorf("BPS patch creates a ROM of %d bytes", targetSize)
+	}
+
+	out := make([]byte, 0, targetSize)
+	sourceOffset, targetOffset := 0, 0
+	for len(r.data) > 0 {
+		action := r.number()
+		length := int(action>>2) + 1
+		if r.err != nil {
+			return nil, r.err
+		}
+		if uint64(len(out)+length) > targetSize {
+			return nil, fmt.Errorf("BPS patch writes beyond the end of the ROM")
+		}
+
+		switch action & 3 {
+		case bpsSourceRead:
+			if len(out)+length > len(rom) {
+				return nil, fmt.Errorf("BPS patch reads beyond the end of the source ROM")
+			}
+			out = append(out, rom[len(out):len(out)+length]...)
+		case bpsTargetRead:
+			out = append(out, r.bytes(uint64(length))...)
+		case bpsSourceCopy:
+			// The offset is checked before it is added, so that a huge offset can not overflow.
+			offset := r.offset()
+			if offset < -sourceOffset || offset > len(rom)-length-sourceOffset {
+				return nil, fmt.Errorf("BPS patch copies from outside of the source ROM")
+			}
+			sourceOffset += offset
+			out = append(out, rom[sourceOffset:sourceOffset+length]...)
+			sourceOffset += length
+		case bpsTargetCopy:
+			offset := r.offset()
+			if offset < -targetOffset || offset >= len(out)-targetOffset {
+				return nil, fmt.Errorf("BPS patch copies from outside of the target ROM")
+			}
+			targetOffset += offset
+			// The copy may overlap the bytes it writes, so it is done byte by byte.
+			for range length {
+				out = append(out, out[targetOffset])
+				targetOffset++
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	if uint64(len(out)) != targetSize {
+		return nil, fmt.Errorf("BPS patch creates %d bytes instead of %d", len(out), targetSize)
+	}
+	if crc := crc32.ChecksumIEEE(out); crc != targetCRC {
+		return nil, fmt.Errorf("patched ROM does not match the BPS patch: checksum %08x, expected %08x", crc, targetCRC)
+	}
+	return out, nil
+}
+
+// CreateBPS returns a BPS patch which turns original into modified. Bytes which are unchanged are
+// read from the original, all others are stored in the patch.
+func CreateBPS(original, modified []byte) []byte {
+	patch := []byte(bpsMagic)
+	patch = appendNumber(patch, uint64(len(original)))
+	patch = appendNumber(patch, uint64(len(modified)))
+	patch = appendNumber(patch, 0) // No metadata
+
+	unchanged := func(i int) bool { return i < len(original) && original[i] == modified[i] }
+	for i := 0; i < len(modified); {
+		start := i
+		keep := unchanged(i)
+		for i < len(modified) && unchanged(i) == keep {
+			i++
+		}
+		if keep {
+			patch = appendNumber(patch, uint64(i-start-1)<<2|bpsSourceRead)
+		} else {
+			patch = appendNumber(patch, uint64(i-start-1)<<2|bpsTargetRead)
+			patch = append(patch, modified[start:i]...)
+		}
+	}
+
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(original))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(modified))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+// appendNumber appends n as variable length number.
+func appendNumber(b []byte, n uint64) []byte {
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(b, 0x80|x)
+		}
+		b = append(b, x)
+		n--
+	}
+}
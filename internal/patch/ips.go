@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	ipsMagic  = "PATCH"
+	ipsEOF    = "EOF"
+	ipsMaxLen = 0xFFFF   // Largest number of bytes of a record
+	ipsMaxOff = 0x454F46 // Offset which reads as the end marker, larger ROMs are not supported
+)
+
+var errIPSTruncated = errors.New("IPS patch is truncated")
+
+// ApplyIPS returns the ROM with the IPS patch applied. A record beyond the end of the ROM extends
+// it, the optional size after the end marker truncates it.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(ipsMagic) || string(patch[:len(ipsMagic)]) != ipsMagic {
+		return nil, fmt.Errorf("not an IPS patch")
+	}
+	out := append([]byte(nil), rom...)
+	p := patch[len(ipsMagic):]
+
+	for {
+		if len(p) < 3 {
+			return nil, errIPSTruncated
+		}
+		if string(p[:3]) == ipsEOF {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, errIPSTruncated
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(p[3])<<8 | int(p[4])
+		p = p[5:]
+
+		var data []byte
+		if size > 0 {
+			if len(p) < size {
+				return nil, errIPSTruncated
+			}
+			data, p = p[:size], p[size:]
+		} else {
+			// A record without data repeats a single byte.
+			if len(p) < 3 {
+				return nil, errIPSTruncated
+			}
+			data = make([]byte, int(p[0])<<8|int(p[1]))
+			for i := range data {
+				data[i] = p[2]
+			}
+			p = p[3:]
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	if len(p) >= 3 {
+		size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// CreateIPS returns an IPS patch which turns original into modified. A record is written for every
+// run of changed bytes; if modified is shorter than original, the patch truncates it.
+func CreateIPS(original, modified []byte) ([]byte, error) {
+	if len(modified) >= ipsMaxOff {
+		return nil, fmt.Errorf("ROM (%d bytes) is too large for an IPS patch", len(modified))
+	}
+	changed := func(i int) bool { return i >= len(original) || original[i] != modified[i] }
+
+	patch := []byte(ipsMagic)
+	for i := 0; i < len(modified); {
+		if !changed(i) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modified) && i-start < ipsMaxLen && changed(i) {
+			i++
+		}
+		patch = append(patch, byte(start>>16), byte(start>>8), byte(start), byte((i-start)>>8), byte(i-start))
+		patch = append(patch, modified[start:i]...)
+	}
+	patch = append(patch, ipsEOF...)
+
+	if len(modified) < len(original) {
+		n := len(modified)
+		patch = append(patch, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return patch, nil
+}
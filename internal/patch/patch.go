@@ -0,0 +1,76 @@
+// Package patch applies and creates IPS and BPS patches, the formats community bug fixes and
+// translations of ROMs are distributed in. IPS patches overwrite bytes at given offsets, BPS patches
+// describe the patched ROM in terms of the original one and carry CRC32 checksums of the original,
+// the patched ROM and the patch itself, so that a patch is not applied to the wrong ROM.
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the file format of a patch.
+type Format int
+
+const (
+	IPS Format = iota
+	BPS
+)
+
+func (f Format) String() string {
+	if f == IPS {
+		return "ips"
+	}
+	return "bps"
+}
+
+// ParseFormat returns the format with the given name, ips or bps.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "ips":
+		return IPS, nil
+	case "bps":
+		return BPS, nil
+	}
+	return 0, fmt.Errorf("unknown patch format %q, expected ips or bps", s)
+}
+
+// FormatOf returns the format given by the extension of the path, .ips or .bps.
+func FormatOf(path string) (Format, bool) {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	return f, err == nil
+}
+
+// Detect returns the format of the patch by its header.
+func Detect(patch []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte(ipsMagic)):
+		return IPS, nil
+	case bytes.HasPrefix(patch, []byte(bpsMagic)):
+		return BPS, nil
+	}
+	return 0, fmt.Errorf("unknown patch format, expected an IPS or BPS patch")
+}
+
+// Apply returns the ROM with the patch applied, detecting the format of the patch by its header.
+// The ROM is not modified.
+func Apply(rom, patch []byte) ([]byte, error) {
+	format, err := Detect(patch)
+	if err != nil {
+		return nil, err
+	}
+	if format == IPS {
+		return ApplyIPS(rom, patch)
+	}
+	return ApplyBPS(rom, patch)
+}
+
+// Create returns a patch in the given format which turns original into modified.
+func Create(format Format, original, modified []byte) ([]byte, error) {
+	if format == IPS {
+		return CreateIPS(original, modified)
+	}
+	return CreateBPS(original, modified), nil
+}
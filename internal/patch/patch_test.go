@@ -0,0 +1,201 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndApply(t *testing.T) {
+	pong, err := os.ReadFile("../../roms/pong.rom")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fixed := bytes.Clone(pong)
+	fixed[0x10], fixed[0x11], fixed[0xF3] = 0x12, 0x34, 0x09
+
+	tests := []struct {
+		testName string
+		original []byte
+		modified []byte
+	}{
+		{testName: "Changed bytes", original: pong, modified: fixed},
+		{testName: "Unchanged", original: pong, modified: pong},
+		{testName: "Extended", original: pong, modified: append(bytes.Clone(pong), 0x00, 0xE0, 0x00, 0x00)},
+		{testName: "Truncated", original: pong, modified: pong[:100]},
+		{testName: "Empty original", original: nil, modified: fixed},
+	}
+
+	for _, format := range []Format{IPS, BPS} {
+		for _, tt := range tests {
+			t.Run(format.String()+"/"+tt.testName, func(t *testing.T) {
+				p, err := Create(format, tt.original, tt.modified)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if f, err := Detect(p); err != nil || f != format {
+					t.Errorf("Expected the format %s but got %s (%v)", format, f, err)
+				}
+				got, err := Apply(tt.original, p)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(got, tt.modified) {
+					t.Errorf("Expected the patched ROM to equal the modified one, got %d bytes instead of %d", len(got), len(tt.modified))
+				}
+			})
+		}
+	}
+}
+
+func TestApplyIPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5}
+	tests := []struct {
+		testName string
+		patch    string
+		want     []byte
+		err      string
+	}{
+		{
+			testName: "Record",
+			patch:    "PATCH\x00\x00\x02\x00\x02\xAA\xBBEOF",
+			want:     []byte{0, 1, 0xAA, 0xBB, 4, 5},
+		},
+		{
+			testName: "Run of a byte",
+			patch:    "PATCH\x00\x00\x04\x00\x00\x00\x03\xCCEOF",
+			want:     []byte{0, 1, 2, 3, 0xCC, 0xCC, 0xCC},
+		},
+		{
+			testName: "Truncation",
+			patch:    "PATCHEOF\x00\x00\x02",
+			want:     []byte{0, 1},
+		},
+		{
+			testName: "Missing end marker",
+			patch:    "PATCH\x00\x00\x02\x00\x02\xAA\xBB",
+			err:      "truncated",
+		},
+		{
+			testName: "Missing data",
+			patch:    "PATCH\x00\x00\x02\x00\x04\xAAEOF",
+			err:      "truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got, err := ApplyIPS(rom, []byte(tt.patch))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing %q but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("Expected %v but got %v (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestApplyBPS(t *testing.T) {
+	original := []byte("the quick brown fox jumps over the lazy dog")
+	modified := []byte("the quick brown cat jumps over the lazy dog")
+	p := CreateBPS(original, modified)
+
+	t.Run("Target copy", func(t *testing.T) {
+		// Writes "ab" and copies it twice, overlapping the bytes the copy writes.
+		target := []byte("ababab")
+		p := []byte(bpsMagic)
+		p = appendNumber(p, 0)
+		p = appendNumber(p, uint64(len(target)))
+		p = appendNumber(p, 0)
+		p = appendNumber(p, 1<<2|bpsTargetRead)
+		p = append(p, "ab"...)
+		p = appendNumber(p, 3<<2|bpsTargetCopy)
+		p = appendNumber(p, 0)
+		p = append(p, 0, 0, 0, 0) // CRC32 of the empty source
+		p = append(p, crcBytes(target)...)
+		p = append(p, crcBytes(p)...)
+
+		got, err := ApplyBPS(nil, p)
+		if err != nil || !bytes.Equal(got, target) {
+			t.Errorf("Expected %q but got %q (%v)", target, got, err)
+		}
+	})
+
+	tests := []struct {
+		testName string
+		rom      []byte
+		patch    func() []byte
+		err      string
+	}{
+		{
+			testName: "Wrong ROM",
+			rom:      modified,
+			patch:    func() []byte { return p },
+			err:      "for another ROM",
+		},
+		{
+			testName: "Corrupt patch",
+			rom:      original,
+			patch: func() []byte {
+				corrupt := bytes.Clone(p)
+				corrupt[len(corrupt)-20] ^= 0xFF
+				return corrupt
+			},
+			err: "corrupt",
+		},
+		{
+			testName: "Huge source offset",
+			rom:      original,
+			patch:    func() []byte { return hostileBPS(original, bpsSourceCopy) },
+			err:      "outside of the source ROM",
+		},
+		{
+			testName: "Huge target offset",
+			rom:      original,
+			patch:    func() []byte { return hostileBPS(original, bpsTargetCopy) },
+			err:      "outside of the target ROM",
+		},
+		{
+			testName: "Not a BPS patch",
+			rom:      original,
+			patch:    func() []byte { return []byte("PATCHEOF") },
+			err:      "not a BPS patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := ApplyBPS(tt.rom, tt.patch())
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q but got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// hostileBPS returns a patch with valid checksums which writes one byte and then copies with the
+// given action from the largest positive offset, which overflows if it is added unchecked.
+func hostileBPS(source []byte, action uint64) []byte {
+	p := []byte(bpsMagic)
+	p = appendNumber(p, uint64(len(source)))
+	p = appendNumber(p, uint64(len(source)))
+	p = appendNumber(p, 0)
+	p = appendNumber(p, 0<<2|bpsSourceRead)
+	p = appendNumber(p, uint64(len(source)-2)<<2|action)
+	p = appendNumber(p, math.MaxInt64<<1)
+	p = append(p, crcBytes(source)...)
+	p = append(p, crcBytes(source)...)
+	return append(p, crcBytes(p)...)
+}
+
+func crcBytes(data []byte) []byte {
+	return binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(data))
+}
@@ -0,0 +1,71 @@
+//go:build js && wasm
+
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"syscall/js"
+)
+
+// CanvasRenderer draws the display on an HTML canvas in the browser. The canvas holds one pixel per
+// CHIP-8 pixel, it is meant to be enlarged by CSS with image-rendering: pixelated.
+type CanvasRenderer struct {
+	context   js.Value // 2D context of the canvas
+	imageData js.Value // ImageData of the canvas size
+	pixels    js.Value // Data of imageData
+	filters   Pipeline
+	frame     *image.RGBA
+	last      []byte // Pixels drawn last
+}
+
+// NewCanvasRenderer returns a renderer which draws on the canvas element. The size of the canvas is
+// set to the size of the display.
+func NewCanvasRenderer(canvas js.Value) (*CanvasRenderer, error) {
+	if canvas.IsNull() || canvas.IsUndefined() {
+		return nil, fmt.Errorf("no canvas given")
+	}
+	canvas.Set("width", displayUnits)
+	canvas.Set("height", heightUnits)
+
+	context := canvas.Call("getContext", "2d")
+	if context.IsNull() {
+		return nil, fmt.Errorf("the canvas has no 2D context")
+	}
+	imageData := context.Call("createImageData", displayUnits, heightUnits)
+
+	return &CanvasRenderer{
+		context:   context,
+		imageData: imageData,
+		pixels:    imageData.Get("data"),
+		frame:     image.NewRGBA(image.Rect(0, 0, displayUnits, heightUnits)),
+	}, nil
+}
+
+// SetFilters sets the filters the display is passed through before it is shown. The canvas is
+// resized to the size of the filtered image.
+func (r *CanvasRenderer) SetFilters(p Pipeline) {
+	r.filters = p
+	r.last = nil
+}
+
+// Draw renders the display on the canvas, unless it did not change since the last call.
+func (r *CanvasRenderer) Draw(display [32][64]bool) {
+	paint(r.frame, &display, Profile)
+	img := r.filters.Apply(r.frame)
+	if string(img.Pix) == string(r.last) {
+		return
+	}
+	r.last = append(r.last[:0], img.Pix...)
+
+	if size := img.Rect.Size(); size.X != r.imageData.Get("width").Int() || size.Y != r.imageData.Get("height").Int() {
+		canvas := r.context.Get("canvas")
+		canvas.Set("width", size.X)
+		canvas.Set("height", size.Y)
+		r.imageData = r.context.Call("createImageData", size.X, size.Y)
+		r.pixels = r.imageData.Get("data")
+	}
+
+	js.CopyBytesToJS(r.pixels, img.Pix)
+	r.context.Call("putImageData", r.imageData, 0, 0)
+}
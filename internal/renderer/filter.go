@@ -0,0 +1,227 @@
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Filter post-processes the image of the display before it is shown. Filters work on plain images,
+// so that they apply to screenshots taken without a window as well. A filter may return an image of
+// another size, e.g. a scaler, and may keep state between frames, e.g. Ghosting.
+type Filter interface {
+	Apply(src *image.RGBA) *image.RGBA
+}
+
+// Pipeline applies filters one after the other. An empty pipeline returns the image unchanged.
+type Pipeline []Filter
+
+func (p Pipeline) Apply(src *image.RGBA) *image.RGBA {
+	for _, f := range p {
+		src = f.Apply(src)
+	}
+	return src
+}
+
+// Clone returns a pipeline with the same filters but without the state they kept between frames, so
+// that it can process another sequence of frames, e.g. for a recording besides the window.
+func (p Pipeline) Clone() Pipeline {
+	var c Pipeline
+	for _, f := range p {
+		if g, ok := f.(*Ghosting); ok {
+			f = &Ghosting{Persistence: g.Persistence}
+		}
+		c = append(c, f)
+	}
+	return c
+}
+
+// ParseFilter returns the filter with the name and an optional parameter after a colon, e.g.
+// "ghosting:0.6". The filters are ghosting, scanlines, crt, scale2x and nearest.
+func ParseFilter(s string) (Filter, error) {
+	name, param, hasParam := strings.Cut(strings.TrimSpace(s), ":")
+
+	value := func(def float64) (float64, error) {
+		if !hasParam {
+			return def, nil
+		}
+		v, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid parameter of filter %s: %s", name, param)
+		}
+		return v, nil
+	}
+
+	switch name {
+	case "ghosting":
+		p, err := value(0.5)
+		return &Ghosting{Persistence: p}, err
+	case "scanlines":
+		i, err := value(0.4)
+		return Scanlines{Intensity: i}, err
+	case "crt":
+		c, err := value(0.1)
+		return CRT{Curvature: c}, err
+	case "scale2x", "epx":
+		return Scale2x{}, nil
+	case "nearest":
+		f, err := value(4)
+		return Nearest{Factor: int(f)}, err
+	}
+	return nil, fmt.Errorf("no such filter: %s", name)
+}
+
+// ParsePipeline parses the filters in the given order, see ParseFilter.
+func ParsePipeline(names []string) (Pipeline, error) {
+	var p Pipeline
+	for _, name := range names {
+		f, err := ParseFilter(name)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, f)
+	}
+	return p, nil
+}
+
+// Ghosting emulates the persistence of the phosphor of old screens: every frame is blended with the
+// previous output, so that pixels fade in and out instead of flickering, which CHIP-8 games do a lot
+// because sprites are erased by drawing them again. Persistence from 0 to 1 is the share of the
+// previous frame.
+type Ghosting struct {
+	Persistence float64
+	prev        *image.RGBA
+}
+
+func (g *Ghosting) Apply(src *image.RGBA) *image.RGBA {
+	if g.prev == nil || g.prev.Rect != src.Rect {
+		g.prev = image.NewRGBA(src.Rect)
+		copy(g.prev.Pix, src.Pix)
+		return g.prev
+	}
+
+	p := min(max(g.Persistence, 0), 1)
+	for i, c := range src.Pix {
+		g.prev.Pix[i] = uint8(math.Round(float64(c) + (float64(g.prev.Pix[i])-float64(c))*p))
+	}
+	return g.prev
+}
+
+// Scanlines darkens every other row by Intensity from 0 to 1. It is meant to follow a scaler, since
+// the rows of the unscaled image are whole CHIP-8 pixels.
+type Scanlines struct {
+	Intensity float64
+}
+
+func (s Scanlines) Apply(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+
+	keep := 1 - min(max(s.Intensity, 0), 1)
+	for y := 1; y < src.Rect.Dy(); y += 2 {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+src.Rect.Dx()*4]
+		for i := range row {
+			if i%4 != 3 {
+				row[i] = uint8(float64(row[i]) * keep)
+			}
+		}
+	}
+	return dst
+}
+
+// CRT bends the image like the curved glass of a cathode-ray tube. Curvature is the amount of bending,
+// 0 keeps the image flat. The corners outside of the screen are black.
+type CRT struct {
+	Curvature float64
+}
+
+func (c CRT) Apply(src *image.RGBA) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(src.Rect)
+
+	for y := range h {
+		v := 2*(float64(y)+0.5)/float64(h) - 1
+		for x := range w {
+			u := 2*(float64(x)+0.5)/float64(w) - 1
+
+			// The further from the centre, the further out the pixel is taken from.
+			su, sv := u*(1+c.Curvature*v*v), v*(1+c.Curvature*u*u)
+			sx, sy := int((su+1)/2*float64(w)), int((sv+1)/2*float64(h))
+
+			i := dst.PixOffset(x, y)
+			if sx < 0 || sx >= w || sy < 0 || sy >= h {
+				dst.Pix[i+3] = 255
+				continue
+			}
+			copy(dst.Pix[i:i+4], src.Pix[src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy):])
+		}
+	}
+	return dst
+}
+
+// Scale2x doubles the size of the image with the Scale2x algorithm, also known as EPX, which smooths
+// diagonal edges instead of just repeating the pixels.
+type Scale2x struct{}
+
+func (Scale2x) Apply(src *image.RGBA) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, 2*w, 2*h))
+
+	// at returns the pixel at x, y, repeating the pixels at the edges.
+	at := func(x, y int) [4]uint8 {
+		x, y = min(max(x, 0), w-1), min(max(y, 0), h-1)
+		i := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+		return [4]uint8(src.Pix[i : i+4])
+	}
+	set := func(x, y int, c [4]uint8) {
+		copy(dst.Pix[dst.PixOffset(x, y):], c[:])
+	}
+
+	for y := range h {
+		for x := range w {
+			b, d, e, f, hh := at(x, y-1), at(x-1, y), at(x, y), at(x+1, y), at(x, y+1)
+			e0, e1, e2, e3 := e, e, e, e
+			if b != hh && d != f {
+				if d == b {
+					e0 = d
+				}
+				if b == f {
+					e1 = f
+				}
+				if d == hh {
+					e2 = d
+				}
+				if hh == f {
+					e3 = f
+				}
+			}
+			set(2*x, 2*y, e0)
+			set(2*x+1, 2*y, e1)
+			set(2*x, 2*y+1, e2)
+			set(2*x+1, 2*y+1, e3)
+		}
+	}
+	return dst
+}
+
+// Nearest enlarges the image by Factor, repeating every pixel. It gives the following filters, e.g.
+// Scanlines, room to work with.
+type Nearest struct {
+	Factor int
+}
+
+func (n Nearest) Apply(src *image.RGBA) *image.RGBA {
+	f := max(n.Factor, 1)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, f*w, f*h))
+
+	for y := range f * h {
+		for x := range f * w {
+			i := src.PixOffset(src.Rect.Min.X+x/f, src.Rect.Min.Y+y/f)
+			copy(dst.Pix[dst.PixOffset(x, y):], src.Pix[i:i+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,118 @@
+package renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage returns an image with the given rows, where '#' is a white and every other character a
+// black pixel.
+func testImage(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestScale2x(t *testing.T) {
+	tests := []struct {
+		testName string
+		src      *image.RGBA
+		want     *image.RGBA
+	}{
+		{
+			testName: "Diagonal edges are smoothed",
+			src:      testImage("#.", "##"),
+			want:     testImage("##..", "###.", "####", "####"),
+		},
+		{
+			testName: "Single pixels are repeated",
+			src:      testImage("...", ".#.", "..."),
+			want:     testImage("......", "......", "..##..", "..##..", "......", "......"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := Scale2x{}.Apply(tt.src)
+			if got.Rect != tt.want.Rect || !bytes.Equal(got.Pix, tt.want.Pix) {
+				t.Errorf("Expected the image\n%v\nbut got\n%v", tt.want.Pix, got.Pix)
+			}
+		})
+	}
+}
+
+func TestGhosting(t *testing.T) {
+	g := &Ghosting{Persistence: 0.5}
+	on, off := testImage("#"), testImage(".")
+
+	tests := []struct {
+		testName string
+		src      *image.RGBA
+		want     uint8
+	}{
+		{testName: "The first frame is shown as is", src: on, want: 255},
+		{testName: "A pixel fades out", src: off, want: 128},
+		{testName: "A pixel keeps fading out", src: off, want: 64},
+		{testName: "A pixel fades in", src: on, want: 160},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := g.Apply(tt.src)
+			if got.Pix[0] != tt.want || got.Pix[3] != 255 {
+				t.Errorf("Expected the red channel %d but got %v", tt.want, got.Pix[:4])
+			}
+		})
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		testName string
+		filters  []string
+		size     image.Point
+		err      bool
+	}{
+		{testName: "No filters", size: image.Pt(64, 32)},
+		{testName: "Scalers", filters: []string{"scale2x", "nearest:3", "scanlines", "crt:0.2"}, size: image.Pt(384, 192)},
+		{testName: "Parameter of ghosting", filters: []string{"ghosting:0.8"}, size: image.Pt(64, 32)},
+		{testName: "Unknown filter", filters: []string{"blur"}, err: true},
+		{testName: "Invalid parameter", filters: []string{"crt:strong"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			p, err := ParsePipeline(tt.filters)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %t but got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if size := p.Apply(Frame([32][64]bool{})).Rect.Size(); size != tt.size {
+				t.Errorf("Expected an image of %v but got %v", tt.size, size)
+			}
+		})
+	}
+}
+
+func TestPipelineClone(t *testing.T) {
+	p := Pipeline{&Ghosting{Persistence: 0.5}, Scale2x{}}
+	c := p.Clone()
+
+	p.Apply(testImage("#"))
+	p.Apply(testImage("."))
+	if got := c.Apply(testImage(".")); got.Pix[0] != 0 || got.Rect.Size() != image.Pt(2, 2) {
+		t.Errorf("Expected the clone to apply the filters without the state of the original but got %v", got.Pix[:4])
+	}
+}
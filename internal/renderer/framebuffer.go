@@ -0,0 +1,27 @@
+package renderer
+
+import "image"
+
+// Frame returns the display as a 64x32 image in the colors of the current palette.
+func Frame(display [32][64]bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	paint(img, &display, Profile)
+	return img
+}
+
+// paint writes the display into img, which has to be 64x32, in the colors of p.
+func paint(img *image.RGBA, display *[32][64]bool, p Palette) {
+	fg := [4]uint8{p.Foreground.R, p.Foreground.G, p.Foreground.B, p.Foreground.A}
+	bg := [4]uint8{p.Background.R, p.Background.G, p.Background.B, p.Background.A}
+
+	for y := range 32 {
+		row := img.Pix[y*img.Stride : y*img.Stride+64*4]
+		for x := range 64 {
+			if display[y][x] {
+				copy(row[x*4:], fg[:])
+			} else {
+				copy(row[x*4:], bg[:])
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package renderer
+
+// KeypadRenderer is implemented by renderers which can show the hex keypad next to the display.
+// SetKeypad is called before every Draw with the keys which are held down and the keys the ROM
+// polled during the last frame.
+type KeypadRenderer interface {
+	Renderer
+	SetKeypad(pressed, polled [16]bool)
+}
+
+// ForwardKeypad passes the state of the keys on to r, if it shows a keypad. Renderers which wrap
+// another one use it to implement KeypadRenderer.
+func ForwardKeypad(r Renderer, pressed, polled [16]bool) {
+	if k, ok := r.(KeypadRenderer); ok {
+		k.SetKeypad(pressed, polled)
+	}
+}
+
+// keypadLayout holds the keys of the COSMAC VIP keypad row by row.
+var keypadLayout = [4][4]uint8{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// Keypad is the position of the on-screen keypad in window coordinates. It consists of 4x4 square
+// keys with the edge length Size, starting at X, Y.
+type Keypad struct {
+	X, Y, Size int32
+}
+
+// Key returns the key at the point x, y of the window.
+func (k Keypad) Key(x, y int32) (uint8, bool) {
+	if k.Size <= 0 || x < k.X || y < k.Y {
+		return 0, false
+	}
+	col, row := (x-k.X)/k.Size, (y-k.Y)/k.Size
+	if col >= 4 || row >= 4 {
+		return 0, false
+	}
+	return keypadLayout[row][col], true
+}
+
+// Cell returns the position of the key in window coordinates.
+func (k Keypad) Cell(key uint8) (x, y int32) {
+	for row, keys := range keypadLayout {
+		for col, kk := range keys {
+			if kk == key&0xF {
+				return k.X + int32(col)*k.Size, k.Y + int32(row)*k.Size
+			}
+		}
+	}
+	return k.X, k.Y
+}
@@ -0,0 +1,66 @@
+package renderer
+
+import "testing"
+
+func TestKeypad(t *testing.T) {
+	k := Keypad{X: 640, Y: 0, Size: 80}
+
+	tests := []struct {
+		testName string
+		x, y     int32
+		key      uint8
+		found    bool
+	}{
+		{testName: "Top left key", x: 640, y: 0, key: 0x1, found: true},
+		{testName: "Bottom right key", x: 959, y: 319, key: 0xF, found: true},
+		{testName: "Second key of the last row", x: 730, y: 250, key: 0x0, found: true},
+		{testName: "Left of the keypad", x: 639, y: 10},
+		{testName: "Below the keypad", x: 700, y: 320},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			key, found := k.Key(tt.x, tt.y)
+			if found != tt.found || key != tt.key {
+				t.Errorf("Expected key %X (%t) but got %X (%t)", tt.key, tt.found, key, found)
+			}
+			if !found {
+				return
+			}
+			if x, y := k.Cell(key); tt.x-x >= k.Size || tt.y-y >= k.Size || tt.x < x || tt.y < y {
+				t.Errorf("Expected the cell of key %X to contain %d, %d but it starts at %d, %d", key, tt.x, tt.y, x, y)
+			}
+		})
+	}
+}
+
+// keypadSpy is a renderer with a keypad which keeps the state of the keys it is given.
+type keypadSpy struct {
+	pressed, polled [16]bool
+}
+
+func (s *keypadSpy) Draw(display [32][64]bool) {}
+
+func (s *keypadSpy) SetKeypad(pressed, polled [16]bool) {
+	s.pressed, s.polled = pressed, polled
+}
+
+// plainRenderer is a renderer without a keypad.
+type plainRenderer struct{}
+
+func (plainRenderer) Draw(display [32][64]bool) {}
+
+func TestForwardKeypad(t *testing.T) {
+	var pressed, polled [16]bool
+	pressed[0x5], polled[0xA] = true, true
+
+	spy := &keypadSpy{}
+	ForwardKeypad(spy, pressed, polled)
+	if spy.pressed != pressed || spy.polled != polled {
+		t.Errorf("Expected the keys to be passed on but got %v and %v", spy.pressed, spy.polled)
+	}
+
+	// Renderers without a keypad and missing renderers are skipped.
+	ForwardKeypad(plainRenderer{}, pressed, polled)
+	ForwardKeypad(nil, pressed, polled)
+}
@@ -0,0 +1,39 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// The window is laid out in units of CHIP-8 pixels: the display is 64x32 units, the on-screen keypad
+// is 32 units wide, i.e. each key is 8x8 units.
+const (
+	displayUnits = 64
+	keypadUnits  = 32
+	heightUnits  = 32
+)
+
+// layout places the display and, if shown, the keypad in a window of w x h pixels. The content is
+// scaled to the largest size that fits with the aspect ratio kept, or to a whole multiple of the CHIP-8
+// pixels if integer is set. The remaining space is left as bars around the centred content.
+func layout(w, h int32, keypad, integer bool) (image.Rectangle, Keypad) {
+	widthUnits := displayUnits
+	if keypad {
+		widthUnits += keypadUnits
+	}
+
+	scale := min(float64(w)/float64(widthUnits), float64(h)/heightUnits)
+	if integer {
+		scale = max(math.Floor(scale), 1)
+	}
+
+	x := (float64(w) - scale*float64(widthUnits)) / 2
+	y := (float64(h) - scale*heightUnits) / 2
+	display := image.Rect(int(x), int(y), int(x+scale*displayUnits), int(y+scale*heightUnits))
+
+	var pad Keypad
+	if keypad {
+		pad = Keypad{X: int32(display.Max.X), Y: int32(display.Min.Y), Size: int32(scale * keypadUnits / 4)}
+	}
+	return display, pad
+}
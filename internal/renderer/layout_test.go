@@ -0,0 +1,71 @@
+package renderer
+
+import (
+	"image"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		testName string
+		w, h     int32
+		keypad   bool
+		integer  bool
+		display  image.Rectangle
+		pad      Keypad
+	}{
+		{
+			testName: "Window of the display size",
+			w:        640, h: 320,
+			display: image.Rect(0, 0, 640, 320),
+		},
+		{
+			testName: "Letterbox above and below",
+			w:        640, h: 480,
+			display: image.Rect(0, 80, 640, 400),
+		},
+		{
+			testName: "Pillarbox left and right",
+			w:        1000, h: 320,
+			display: image.Rect(180, 0, 820, 320),
+		},
+		{
+			testName: "Integer scaling",
+			w:        700, h: 400,
+			integer: true,
+			display: image.Rect(30, 40, 670, 360),
+		},
+		{
+			testName: "Integer scaling never scales below one",
+			w:        32, h: 16,
+			integer: true,
+			display: image.Rect(-16, -8, 48, 24),
+		},
+		{
+			testName: "Keypad right of the display",
+			w:        960, h: 320,
+			keypad:  true,
+			display: image.Rect(0, 0, 640, 320),
+			pad:     Keypad{X: 640, Y: 0, Size: 80},
+		},
+		{
+			testName: "Keypad with aspect scaling",
+			w:        480, h: 480,
+			keypad:  true,
+			display: image.Rect(0, 160, 320, 320),
+			pad:     Keypad{X: 320, Y: 160, Size: 40},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			display, pad := layout(tt.w, tt.h, tt.keypad, tt.integer)
+			if display != tt.display {
+				t.Errorf("Expected the display at %v but got %v", tt.display, display)
+			}
+			if pad != tt.pad {
+				t.Errorf("Expected the keypad at %+v but got %+v", tt.pad, pad)
+			}
+		})
+	}
+}
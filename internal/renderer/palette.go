@@ -0,0 +1,180 @@
+package renderer
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Palette holds the colors the display is drawn with. Multi-plane modes use all four colors,
+// the other modes only the background and the foreground.
+type Palette struct {
+	Foreground  color.RGBA // Pixels set in the first plane
+	Background  color.RGBA // Pixels set in no plane
+	Foreground2 color.RGBA // Pixels set in the second plane only
+	Blend       color.RGBA // Pixels set in both planes
+}
+
+// Profiles holds the palettes by their name. Use Register to add a palette.
+var Profiles = map[string]Palette{
+	"black-white": NewPalette(color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}),
+	"night-sky":   NewPalette(color.RGBA{255, 255, 204, 255}, color.RGBA{0, 0, 68, 255}),
+	"console":     NewPalette(color.RGBA{0, 0, 0, 255}, color.RGBA{34, 238, 34, 255}),
+	"honey":       NewPalette(color.RGBA{153, 102, 0, 255}, color.RGBA{255, 204, 0, 255}),
+	"paper":       NewPalette(color.RGBA{34, 34, 34, 255}, color.RGBA{255, 250, 240, 255}),
+}
+
+// Profile is the palette the renderers draw with.
+var Profile Palette
+
+// profileName is the name of the palette selected by Use, empty for an unregistered palette.
+var profileName string
+
+// NewPalette returns a two-color palette. The colors of the second plane default to the foreground.
+func NewPalette(foreground, background color.RGBA) Palette {
+	return Palette{Foreground: foreground, Background: background, Foreground2: foreground, Blend: foreground}
+}
+
+// Register adds a palette under the given name, replacing a palette with the same name.
+func Register(name string, p Palette) {
+	Profiles[name] = p
+}
+
+// Names returns the names of the registered palettes in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Use selects the registered palette with the given name. It reports false if there is no such palette.
+func Use(name string) bool {
+	p, found := Profiles[name]
+	if !found {
+		return false
+	}
+	Profile, profileName = p, name
+	return true
+}
+
+// SetProfile selects a palette which is not registered.
+func SetProfile(p Palette) {
+	Profile, profileName = p, ""
+}
+
+// Cycle selects the registered palette following the current one in alphabetical order and returns its name.
+func Cycle() string {
+	names := Names()
+	if len(names) == 0 {
+		return ""
+	}
+
+	next := names[0]
+	if i := slices.Index(names, profileName); i >= 0 {
+		next = names[(i+1)%len(names)]
+	}
+	Use(next)
+	return next
+}
+
+// ParseColor parses a color given as hex string: #RGB, #RRGGBB or #RRGGBBAA. The # is optional.
+func ParseColor(s string) (color.RGBA, error) {
+	hexa := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(hexa, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	switch len(hexa) {
+	case 3:
+		r, g, b := uint8(v>>8&0xF), uint8(v>>4&0xF), uint8(v&0xF)
+		return color.RGBA{r * 0x11, g * 0x11, b * 0x11, 255}, nil
+	case 6:
+		return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+	case 8:
+		return color.RGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+	}
+	return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+}
+
+// FormatColor returns the color as #RRGGBB.
+func FormatColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// ParsePalette parses two colors (background and foreground) or four colors (background, foreground,
+// second foreground and blend) given as hex strings.
+func ParsePalette(colors []string) (Palette, error) {
+	if len(colors) != 2 && len(colors) != 4 {
+		return Palette{}, fmt.Errorf("a palette needs 2 or 4 colors but got %d", len(colors))
+	}
+
+	parsed := make([]color.RGBA, len(colors))
+	for i, s := range colors {
+		c, err := ParseColor(s)
+		if err != nil {
+			return Palette{}, err
+		}
+		parsed[i] = c
+	}
+
+	p := NewPalette(parsed[1], parsed[0])
+	if len(parsed) == 4 {
+		p.Foreground2, p.Blend = parsed[2], parsed[3]
+	}
+	return p, nil
+}
+
+// LoadPalette reads a palette file, which holds one hex color per line in the order of ParsePalette
+// like the .hex files of Lospec. Empty lines and lines starting with ; are ignored.
+func LoadPalette(path string) (Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Palette{}, fmt.Errorf("could not open palette: %w", err)
+	}
+	defer f.Close()
+
+	var colors []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		colors = append(colors, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return Palette{}, fmt.Errorf("could not read palette: %w", err)
+	}
+
+	p, err := ParsePalette(colors)
+	if err != nil {
+		return Palette{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// LoadPalettes registers all palette files with the extension .hex in dir under their base name.
+func LoadPalettes(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.hex"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		p, err := LoadPalette(path)
+		if err != nil {
+			return err
+		}
+		Register(strings.TrimSuffix(filepath.Base(path), ".hex"), p)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package renderer
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  color.RGBA
+		err   bool
+	}{
+		{input: "#FF8000", want: color.RGBA{255, 128, 0, 255}},
+		{input: "ff8000", want: color.RGBA{255, 128, 0, 255}},
+		{input: "#F80", want: color.RGBA{255, 136, 0, 255}},
+		{input: "#FF800080", want: color.RGBA{255, 128, 0, 128}},
+		{input: "#FF80", err: true},
+		{input: "#GG8000", err: true},
+		{input: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, err := ParseColor(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %t but got %v", tt.err, err)
+			}
+			if c != tt.want {
+				t.Errorf("Expected %v but got %v", tt.want, c)
+			}
+		})
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+
+	tests := []struct {
+		testName string
+		colors   []string
+		want     Palette
+		err      bool
+	}{
+		{
+			testName: "Two colors",
+			colors:   []string{"#000000", "#FFFFFF"},
+			want:     Palette{Foreground: white, Background: black, Foreground2: white, Blend: white},
+		},
+		{
+			testName: "Four colors",
+			colors:   []string{"#000000", "#FFFFFF", "#FF0000", "#00FF00"},
+			want:     Palette{Foreground: white, Background: black, Foreground2: red, Blend: green},
+		},
+		{
+			testName: "Three colors",
+			colors:   []string{"#000000", "#FFFFFF", "#FF0000"},
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			p, err := ParsePalette(tt.colors)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %t but got %v", tt.err, err)
+			}
+			if p != tt.want {
+				t.Errorf("Expected %v but got %v", tt.want, p)
+			}
+		})
+	}
+}
+
+func TestLoadPalettes(t *testing.T) {
+	dir := t.TempDir()
+	data := "; four colors\n1a1c2c\n\nf4f4f4\n#b13e53\n#38b764\n"
+	if err := os.WriteFile(filepath.Join(dir, "sweetie.hex"), []byte(data), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { delete(Profiles, "sweetie") })
+
+	if err := LoadPalettes(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p, found := Profiles["sweetie"]
+	if !found {
+		t.Fatalf("Expected the palette to be registered under the name of the file")
+	}
+	if p.Background != (color.RGBA{0x1a, 0x1c, 0x2c, 255}) || p.Blend != (color.RGBA{0x38, 0xb7, 0x64, 255}) {
+		t.Errorf("Expected the colors of the file but got %v", p)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	Register("zzz", NewPalette(color.RGBA{1, 2, 3, 255}, color.RGBA{}))
+	t.Cleanup(func() { delete(Profiles, "zzz") })
+
+	if !Use("paper") {
+		t.Fatalf("Expected the palette paper to exist")
+	}
+	if name := Cycle(); name != "zzz" || Profile != Profiles["zzz"] {
+		t.Errorf("Expected the registered palette zzz to follow paper but got %s", name)
+	}
+	if name := Cycle(); name != Names()[0] {
+		t.Errorf("Expected the cycle to wrap around to %s but got %s", Names()[0], name)
+	}
+
+	SetProfile(Palette{})
+	if name := Cycle(); name != Names()[0] {
+		t.Errorf("Expected an unregistered palette to be followed by %s but got %s", Names()[0], name)
+	}
+}
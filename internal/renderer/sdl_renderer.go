@@ -1,40 +1,216 @@
+//go:build !js
+
 package renderer
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"unsafe"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/waldgaenger/go-acht/internal/font"
 )
 
 type SDLRenderer struct {
 	Renderer *sdl.Renderer // TODO: Should not be exported
 	Window   *sdl.Window   // TODO: Should not be exported
+
+	texture    *sdl.Texture // Streaming texture the display is uploaded to
+	size       image.Point  // Size of the texture
+	frame      *image.RGBA  // The display in the colors of the palette
+	filters    Pipeline     // Filters applied to the frame before it is uploaded
+	uploaded   []byte       // Pixels uploaded to the texture last
+	integer    bool         // Scales the display by whole multiples only
+	keypad     bool         // Shows the on-screen keypad
+	fullscreen bool
+	pressed    [16]bool // Keys which are held down, as passed to SetKeypad
+	polled     [16]bool // Keys the ROM polled during the last frame
+	toast      string   // Text shown at the bottom of the display, empty if none
+	toastLeft  int      // Number of frames the toast is still shown
+	last       *screen  // What was presented last, nil if the window has to be redrawn
+}
+
+// screen holds everything the content of the window depends on besides the pixels of the texture.
+type screen struct {
+	w, h            int32
+	keypad          bool
+	pressed, polled [16]bool
+	toast           string
 }
 
 // Draw renders the CHIP-8 display buffer to the window.
 //
-// Each 'true' value in the display buffer ([32][64]bool) is drawn as a filled rectangle
-// using the current foreground color; all other pixels use the background color.
-// The display is cleared and redrawn on every call.
-func (r SDLRenderer) Draw(display [32][64]bool) {
-	// PERF: Could be optimized. No redraw neccessary
-	r.Renderer.SetDrawColor(Profile.Background.R, Profile.Background.G, Profile.Background.B, Profile.Background.A)
+// Each 'true' value in the display buffer ([32][64]bool) is drawn using the current foreground
+// color; all other pixels use the background color. The display is passed through the filters and
+// uploaded into a texture, which is scaled to the size of the window, the space which is not covered
+// is black. Nothing is drawn if neither the filtered display nor the window size or the keypad changed
+// since the last call.
+func (r *SDLRenderer) Draw(display [32][64]bool) {
+	w, h, err := r.Renderer.GetOutputSize()
+	if err != nil {
+		return
+	}
+
+	paint(r.frame, &display, Profile)
+	img := r.filters.Apply(r.frame)
+
+	if r.toastLeft > 0 {
+		r.toastLeft--
+		if r.toastLeft == 0 {
+			r.toast = ""
+		}
+	}
+
+	current := screen{w: w, h: h, keypad: r.keypad, pressed: r.pressed, polled: r.polled, toast: r.toast}
+	if r.last != nil && *r.last == current && bytes.Equal(img.Pix, r.uploaded) {
+		return
+	}
+	r.last = &current
+	r.uploaded = append(r.uploaded[:0], img.Pix...)
+
+	if size := img.Rect.Size(); size != r.size {
+		texture, err := r.Renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, int32(size.X), int32(size.Y))
+		if err != nil {
+			return
+		}
+		r.texture.Destroy()
+		r.texture, r.size = texture, size
+	}
+
+	area, keypad := layout(w, h, r.keypad, r.integer)
+
+	r.Renderer.SetDrawColor(0, 0, 0, 255)
 	r.Renderer.Clear()
-	r.Renderer.SetDrawColor(Profile.Foreground.R, Profile.Foreground.G, Profile.Foreground.B, Profile.Foreground.A)
-	for y := range 32 {
-		for x := range 64 {
-			if display[y][x] {
-				// TODO: Provide a scaling factor
-				rect := sdl.Rect{X: int32(x * 10), Y: int32(y * 10), W: int32(10), H: int32(10)}
-				r.Renderer.FillRect(&rect)
+
+	r.texture.Update(nil, unsafe.Pointer(&img.Pix[0]), img.Stride)
+	r.Renderer.Copy(r.texture, nil, &sdl.Rect{X: int32(area.Min.X), Y: int32(area.Min.Y), W: int32(area.Dx()), H: int32(area.Dy())})
+
+	if r.keypad {
+		r.Renderer.SetDrawColor(Profile.Background.R, Profile.Background.G, Profile.Background.B, Profile.Background.A)
+		r.Renderer.FillRect(&sdl.Rect{X: keypad.X, Y: keypad.Y, W: 4 * keypad.Size, H: 4 * keypad.Size})
+		r.drawKeypad(keypad)
+	}
+	if r.toast != "" {
+		r.drawToast(area)
+	}
+	r.Renderer.Present()
+}
+
+// ShowToast shows the text at the bottom of the display for ToastFrames frames, replacing the toast
+// shown before.
+func (r *SDLRenderer) ShowToast(text string) {
+	r.toast, r.toastLeft = text, ToastFrames
+}
+
+// drawToast draws the toast in the foreground color on a box in the background color with a border.
+func (r *SDLRenderer) drawToast(area image.Rectangle) {
+	box, pixel := toastLayout(area, len([]rune(r.toast)))
+	fg, bg := Profile.Foreground, Profile.Background
+	rect := sdl.Rect{X: int32(box.Min.X), Y: int32(box.Min.Y), W: int32(box.Dx()), H: int32(box.Dy())}
+	r.Renderer.SetDrawColor(bg.R, bg.G, bg.B, bg.A)
+	r.Renderer.FillRect(&rect)
+	r.Renderer.SetDrawColor(fg.R, fg.G, fg.B, fg.A)
+	r.Renderer.DrawRect(&rect)
+
+	var rects []sdl.Rect
+	for _, p := range textPixels(r.toast) {
+		x, y := box.Min.X+(p.X+1)*pixel, box.Min.Y+(p.Y+1)*pixel
+		rects = append(rects, sdl.Rect{X: int32(x), Y: int32(y), W: int32(pixel), H: int32(pixel)})
+	}
+	r.Renderer.FillRects(rects)
+}
+
+// SetFilters sets the filters the display is passed through before it is shown.
+func (r *SDLRenderer) SetFilters(p Pipeline) {
+	r.filters = p
+	r.last = nil
+}
+
+// ShowKeypad widens the window and shows the hex keypad to the right of the display.
+// The keys can be clicked or touched, see KeypadKey, and are highlighted when the ROM polls them.
+func (r *SDLRenderer) ShowKeypad() {
+	r.keypad = true
+	w, h := r.Window.GetSize()
+	r.Window.SetSize(w*(displayUnits+keypadUnits)/displayUnits, h)
+	r.Window.SetMinimumSize(displayUnits+keypadUnits, heightUnits)
+}
+
+// KeypadKey returns the key of the on-screen keypad at the point x, y of the window.
+func (r *SDLRenderer) KeypadKey(x, y int32) (uint8, bool) {
+	if !r.keypad {
+		return 0, false
+	}
+	w, h := r.Window.GetSize()
+	_, keypad := layout(w, h, true, r.integer)
+	return keypad.Key(x, y)
+}
+
+// ToggleFullscreen switches between the window and borderless fullscreen at the resolution of the desktop.
+func (r *SDLRenderer) ToggleFullscreen() error {
+	var flags uint32
+	if !r.fullscreen {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	if err := r.Window.SetFullscreen(flags); err != nil {
+		return fmt.Errorf("failed to switch fullscreen mode: %w", err)
+	}
+	r.fullscreen = !r.fullscreen
+	return nil
+}
+
+// SetKeypad sets the state of the keys shown by the on-screen keypad.
+func (r *SDLRenderer) SetKeypad(pressed, polled [16]bool) {
+	r.pressed, r.polled = pressed, polled
+}
+
+// drawKeypad draws the on-screen keypad. A key which is held down is filled with the foreground color,
+// a key the ROM polls gets a thick border.
+func (r *SDLRenderer) drawKeypad(keypad Keypad) {
+	gap, pixel := keypad.Size/20, max(keypad.Size/10, 1)
+	size := keypad.Size - 2*gap
+	fg, bg := Profile.Foreground, Profile.Background
+
+	for key := range uint8(16) {
+		x, y := keypad.Cell(key)
+		cell := sdl.Rect{X: x + gap, Y: y + gap, W: size, H: size}
+
+		glyphColor := fg
+		r.Renderer.SetDrawColor(fg.R, fg.G, fg.B, fg.A)
+		if r.pressed[key] {
+			r.Renderer.FillRect(&cell)
+			glyphColor = bg
+		} else {
+			border := max(keypad.Size/80, 1)
+			if r.polled[key] {
+				border *= 3
+			}
+			for i := range border {
+				rect := sdl.Rect{X: cell.X + i, Y: cell.Y + i, W: cell.W - 2*i, H: cell.H - 2*i}
+				r.Renderer.DrawRect(&rect)
 			}
 		}
+
+		// The label is centred in the key.
+		var rects []sdl.Rect
+		left, top := cell.X+(size-4*pixel)/2, cell.Y+(size-5*pixel)/2
+		for row, bits := range font.Sprite(key) {
+			for col := range 4 {
+				if bits&(0x80>>col) != 0 {
+					rects = append(rects, sdl.Rect{X: left + int32(col)*pixel, Y: top + int32(row)*pixel, W: pixel, H: pixel})
+				}
+			}
+		}
+		r.Renderer.SetDrawColor(glyphColor.R, glyphColor.G, glyphColor.B, glyphColor.A)
+		r.Renderer.FillRects(rects)
 	}
-	r.Renderer.Present()
 }
 
-// NewSDLRenderer initializes SDL, creates a window and renderer, and returns an SDLRenderer.
-func NewSDLRenderer() (*SDLRenderer, error) {
+// NewSDLRenderer initializes SDL, creates a resizable window with the CHIP-8 pixels scaled by scale and
+// a renderer, and returns an SDLRenderer. If integer is set, the display is only scaled by whole
+// multiples when the window is resized, otherwise it fills the window as far as the aspect ratio allows.
+func NewSDLRenderer(scale int, integer bool) (*SDLRenderer, error) {
+	scale = max(scale, 1)
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		return nil, fmt.Errorf("failed to initialize SDL: %w", err)
 	}
@@ -42,7 +218,7 @@ func NewSDLRenderer() (*SDLRenderer, error) {
 	window, err := sdl.CreateWindow(
 		"CHIP8 EMULATOR",
 		sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		640, 320, sdl.WINDOW_SHOWN,
+		int32(displayUnits*scale), int32(heightUnits*scale), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE,
 	)
 	if err != nil {
 		sdl.Quit()
@@ -56,11 +232,40 @@ func NewSDLRenderer() (*SDLRenderer, error) {
 		return nil, fmt.Errorf("failed to create renderer: %w", err)
 	}
 
-	return &SDLRenderer{Renderer: renderer, Window: window}, nil
+	window.SetMinimumSize(displayUnits, heightUnits)
+
+	r, err := newSDLRenderer(renderer, window, integer)
+	if err != nil {
+		renderer.Destroy()
+		window.Destroy()
+		sdl.Quit()
+		return nil, err
+	}
+	return r, nil
+}
+
+// newSDLRenderer creates the texture of the display for the renderer.
+func newSDLRenderer(renderer *sdl.Renderer, window *sdl.Window, integer bool) (*SDLRenderer, error) {
+	texture, err := renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, displayUnits, heightUnits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create texture: %w", err)
+	}
+
+	return &SDLRenderer{
+		Renderer: renderer,
+		Window:   window,
+		texture:  texture,
+		size:     image.Pt(displayUnits, heightUnits),
+		frame:    image.NewRGBA(image.Rect(0, 0, displayUnits, heightUnits)),
+		integer:  integer,
+	}, nil
 }
 
 // Cleanup releases SDL resources.
 func (r *SDLRenderer) Cleanup() {
+	if r.texture != nil {
+		r.texture.Destroy()
+	}
 	if r.Renderer != nil {
 		r.Renderer.Destroy()
 	}
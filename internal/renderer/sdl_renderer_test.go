@@ -0,0 +1,120 @@
+//go:build !js
+
+package renderer
+
+import (
+	"image"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// newSoftwareRenderer returns an SDLRenderer which draws into a 640x320 surface instead of a window,
+// so that no display is needed.
+func newSoftwareRenderer(b testing.TB) *SDLRenderer {
+	surface, err := sdl.CreateRGBSurfaceWithFormat(0, 640, 320, 32, uint32(sdl.PIXELFORMAT_RGBA32))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.Cleanup(surface.Free)
+
+	renderer, err := sdl.CreateSoftwareRenderer(surface)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	r, err := newSDLRenderer(renderer, nil, false)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.Cleanup(func() {
+		r.texture.Destroy()
+		renderer.Destroy()
+	})
+	return r
+}
+
+// checkerboard returns a display with every other pixel set, which is the worst case for drawing
+// the pixels as rectangles.
+func checkerboard() [32][64]bool {
+	var display [32][64]bool
+	for y := range 32 {
+		for x := range 64 {
+			display[y][x] = (x+y)%2 == 0
+		}
+	}
+	return display
+}
+
+func TestDrawFilterSize(t *testing.T) {
+	r := newSoftwareRenderer(t)
+	display := checkerboard()
+
+	tests := []struct {
+		testName string
+		filters  Pipeline
+		size     image.Point
+	}{
+		{testName: "Without filters", size: image.Pt(64, 32)},
+		{testName: "Scale2x doubles the texture", filters: Pipeline{Scale2x{}}, size: image.Pt(128, 64)},
+		{testName: "Twice Scale2x", filters: Pipeline{Scale2x{}, Scale2x{}}, size: image.Pt(256, 128)},
+		{testName: "Back to the display size", size: image.Pt(64, 32)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r.SetFilters(tt.filters)
+			r.Draw(display)
+			_, _, w, h, err := r.texture.Query()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := image.Pt(int(w), int(h)); got != tt.size || r.size != tt.size {
+				t.Errorf("Expected a texture of %v but got %v", tt.size, got)
+			}
+		})
+	}
+}
+
+// BenchmarkDrawRects measures the previous way of drawing: clearing the window and filling a
+// rectangle for every pixel which is set, on every frame.
+func BenchmarkDrawRects(b *testing.B) {
+	r := newSoftwareRenderer(b)
+	display := checkerboard()
+
+	for b.Loop() {
+		r.Renderer.SetDrawColor(Profile.Background.R, Profile.Background.G, Profile.Background.B, Profile.Background.A)
+		r.Renderer.Clear()
+		r.Renderer.SetDrawColor(Profile.Foreground.R, Profile.Foreground.G, Profile.Foreground.B, Profile.Foreground.A)
+		for y := range 32 {
+			for x := range 64 {
+				if display[y][x] {
+					rect := sdl.Rect{X: int32(x * 10), Y: int32(y * 10), W: 10, H: 10}
+					r.Renderer.FillRect(&rect)
+				}
+			}
+		}
+		r.Renderer.Present()
+	}
+}
+
+// BenchmarkDraw measures drawing a display which changes on every frame.
+func BenchmarkDraw(b *testing.B) {
+	r := newSoftwareRenderer(b)
+	display := checkerboard()
+
+	for b.Loop() {
+		display[0][0] = !display[0][0]
+		r.Draw(display)
+	}
+}
+
+// BenchmarkDrawUnchanged measures drawing a display which does not change, which most frames of
+// most games do not.
+func BenchmarkDrawUnchanged(b *testing.B) {
+	r := newSoftwareRenderer(b)
+	display := checkerboard()
+
+	for b.Loop() {
+		r.Draw(display)
+	}
+}
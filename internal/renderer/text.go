@@ -0,0 +1,72 @@
+package renderer
+
+import (
+	"image"
+	"unicode"
+)
+
+// ToastFrames is the number of frames a toast is shown, three seconds.
+const ToastFrames = 180
+
+// textGlyphs holds the characters of toasts as 3x5 pixel glyphs, the top three bits of a byte being
+// a row. Lower case letters are shown in upper case, unknown characters as question mark.
+var textGlyphs = map[rune][5]uint8{
+	'A': {0x40, 0xA0, 0xE0, 0xA0, 0xA0}, 'B': {0xC0, 0xA0, 0xC0, 0xA0, 0xC0},
+	'C': {0x60, 0x80, 0x80, 0x80, 0x60}, 'D': {0xC0, 0xA0, 0xA0, 0xA0, 0xC0},
+	'E': {0xE0, 0x80, 0xC0, 0x80, 0xE0}, 'F': {0xE0, 0x80, 0xC0, 0x80, 0x80},
+	'G': {0x60, 0x80, 0xA0, 0xA0, 0x60}, 'H': {0xA0, 0xA0, 0xE0, 0xA0, 0xA0},
+	'I': {0xE0, 0x40, 0x40, 0x40, 0xE0}, 'J': {0x20, 0x20, 0x20, 0xA0, 0x40},
+	'K': {0xA0, 0xA0, 0xC0, 0xA0, 0xA0}, 'L': {0x80, 0x80, 0x80, 0x80, 0xE0},
+	'M': {0xA0, 0xE0, 0xE0, 0xA0, 0xA0}, 'N': {0xC0, 0xA0, 0xA0, 0xA0, 0xA0},
+	'O': {0x40, 0xA0, 0xA0, 0xA0, 0x40}, 'P': {0xC0, 0xA0, 0xC0, 0x80, 0x80},
+	'Q': {0x40, 0xA0, 0xA0, 0xC0, 0x60}, 'R': {0xC0, 0xA0, 0xC0, 0xA0, 0xA0},
+	'S': {0x60, 0x80, 0x40, 0x20, 0xC0}, 'T': {0xE0, 0x40, 0x40, 0x40, 0x40},
+	'U': {0xA0, 0xA0, 0xA0, 0xA0, 0xE0}, 'V': {0xA0, 0xA0, 0xA0, 0xA0, 0x40},
+	'W': {0xA0, 0xA0, 0xE0, 0xE0, 0xA0}, 'X': {0xA0, 0xA0, 0x40, 0xA0, 0xA0},
+	'Y': {0xA0, 0xA0, 0x40, 0x40, 0x40}, 'Z': {0xE0, 0x20, 0x40, 0x80, 0xE0},
+	'0': {0xE0, 0xA0, 0xA0, 0xA0, 0xE0}, '1': {0x40, 0xC0, 0x40, 0x40, 0xE0},
+	'2': {0xC0, 0x20, 0x40, 0x80, 0xE0}, '3': {0xC0, 0x20, 0x40, 0x20, 0xC0},
+	'4': {0xA0, 0xA0, 0xE0, 0x20, 0x20}, '5': {0xE0, 0x80, 0xC0, 0x20, 0xC0},
+	'6': {0x60, 0x80, 0xC0, 0xA0, 0x40}, '7': {0xE0, 0x20, 0x40, 0x40, 0x40},
+	'8': {0x40, 0xA0, 0x40, 0xA0, 0x40}, '9': {0x40, 0xA0, 0x60, 0x20, 0xC0},
+	' ': {}, '!': {0x40, 0x40, 0x40, 0x00, 0x40},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x40}, ',': {0x00, 0x00, 0x00, 0x40, 0x80},
+	':': {0x00, 0x40, 0x00, 0x40, 0x00}, '-': {0x00, 0x00, 0xE0, 0x00, 0x00},
+	'\'': {0x40, 0x40, 0x00, 0x00, 0x00}, '/': {0x20, 0x20, 0x40, 0x80, 0x80},
+	'?': {0xC0, 0x20, 0x40, 0x00, 0x40},
+}
+
+// glyph returns the glyph of the character.
+func glyph(c rune) [5]uint8 {
+	if g, ok := textGlyphs[unicode.ToUpper(c)]; ok {
+		return g
+	}
+	return textGlyphs['?']
+}
+
+// textPixels returns the pixels of the text in units of a glyph pixel, starting at 0, 0. The
+// characters are 4 units apart.
+func textPixels(text string) []image.Point {
+	var pixels []image.Point
+	for i, c := range []rune(text) {
+		for y, bits := range glyph(c) {
+			for x := range 3 {
+				if bits&(0x80>>x) != 0 {
+					pixels = append(pixels, image.Pt(4*i+x, y))
+				}
+			}
+		}
+	}
+	return pixels
+}
+
+// toastLayout returns the box of a toast with n characters at the bottom of the display area and
+// the size of a glyph pixel, at most a quarter of a CHIP-8 pixel. The box leaves a margin of a glyph
+// pixel around the text and is a glyph pixel above the bottom of the area.
+func toastLayout(area image.Rectangle, n int) (box image.Rectangle, pixel int) {
+	units := image.Pt(4*n+1, 7)
+	pixel = max(min(area.Dy()/(32*4), area.Dx()/(units.X+2)), 1)
+	size := units.Mul(pixel)
+	origin := image.Pt(area.Min.X+(area.Dx()-size.X)/2, area.Max.Y-size.Y-pixel)
+	return image.Rectangle{Min: origin, Max: origin.Add(size)}, pixel
+}
@@ -0,0 +1,78 @@
+package renderer
+
+import (
+	"image"
+	"testing"
+)
+
+func TestTextPixels(t *testing.T) {
+	tests := []struct {
+		testName string
+		text     string
+		pixels   int
+		right    int // Rightmost column of a pixel
+	}{
+		{testName: "Single character", text: "I", pixels: 9, right: 2},
+		{testName: "Lower case", text: "i", pixels: 9, right: 2},
+		{testName: "Characters are 4 units apart", text: "-I", pixels: 12, right: 6},
+		{testName: "Space", text: " ", pixels: 0},
+		{testName: "Unknown character", text: "€", pixels: 5, right: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			pixels := textPixels(tt.text)
+			if len(pixels) != tt.pixels {
+				t.Fatalf("Expected %d pixels but got %d", tt.pixels, len(pixels))
+			}
+			right := 0
+			for _, p := range pixels {
+				right = max(right, p.X)
+			}
+			if right != tt.right {
+				t.Errorf("Expected the rightmost pixel in column %d but got %d", tt.right, right)
+			}
+		})
+	}
+}
+
+func TestToastLayout(t *testing.T) {
+	tests := []struct {
+		testName string
+		area     image.Rectangle
+		n        int
+		box      image.Rectangle
+		pixel    int
+	}{
+		{
+			testName: "Quarter of a CHIP-8 pixel",
+			area:     image.Rect(0, 0, 1280, 640),
+			n:        4,
+			box:      image.Rect(597, 600, 682, 635),
+			pixel:    5,
+		},
+		{
+			testName: "Long text is smaller",
+			area:     image.Rect(0, 0, 1280, 640),
+			n:        100,
+			box:      image.Rect(38, 616, 1241, 637),
+			pixel:    3,
+		},
+		{
+			testName: "At least one pixel",
+			area:     image.Rect(10, 20, 74, 52),
+			n:        2,
+			box:      image.Rect(37, 44, 46, 51),
+			pixel:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			box, pixel := toastLayout(tt.area, tt.n)
+			if box != tt.box || pixel != tt.pixel {
+				t.Errorf("Expected %v with pixel %d but got %v with pixel %d", tt.box, tt.pixel, box, pixel)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package romdb looks up ROMs by the SHA-1 hash of their contents and provides the settings they are
+// known to run best with.
+//
+// The database uses the layout of the community CHIP-8 database (https://github.com/chip-8/chip-8-database):
+// programs.json holds the programs together with their ROMs, sha1-hashes.json maps the hashes to the index
+// of a program and platforms.json describes the quirks and the default tickrate of every platform.
+// A small database covering the bundled ROMs is embedded, the full community database can be loaded with Load.
+package romdb
+
+import (
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+
+	"github.com/waldgaenger/go-acht/internal/achievements"
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/gamestate"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+//go:embed database/*.json
+var embedded embed.FS
+
+// Program is a CHIP-8 program, which may have been released as several ROMs.
+type Program struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Release     string          `json:"release,omitempty"`
+	Authors     []string        `json:"authors,omitempty"`
+	ROMs        map[string]*ROM `json:"roms"` // Keyed by the SHA-1 hash of the ROM
+}
+
+// ROM holds the settings a single ROM is known to run best with.
+type ROM struct {
+	File            string                        `json:"file,omitempty"`
+	EmbeddedTitle   string                        `json:"embeddedTitle,omitempty"`
+	Description     string                        `json:"description,omitempty"`
+	Platforms       []string                      `json:"platforms"`                 // Supported platforms, the preferred one first
+	QuirkyPlatforms map[string]QuirkSet           `json:"quirkyPlatforms,omitempty"` // Deviations from the quirks of a platform
+	Tickrate        int                           `json:"tickrate,omitempty"`
+	Keys            map[string]uint8              `json:"keys,omitempty"` // CHIP-8 keys of the buttons up, down, left, right, a, b, player2Up, ...
+	Colors          *Colors                       `json:"colors,omitempty"`
+	Variables       map[string]gamestate.Variable `json:"variables,omitempty"` // Where the values of the game state are kept, e.g. score or lives
+	Achievements    []achievements.Achievement    `json:"achievements,omitempty"`
+}
+
+// Colors holds the recommended colours as hex strings.
+type Colors struct {
+	Pixels  []string `json:"pixels,omitempty"` // Colours of the pixel values, the first one is the background
+	Buzzer  string   `json:"buzzer,omitempty"`
+	Silence string   `json:"silence,omitempty"`
+}
+
+// Platform is an interpreter the ROMs were written for.
+type Platform struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	DefaultTickrate int      `json:"defaultTickrate"`
+	Quirks          QuirkSet `json:"quirks"`
+}
+
+// QuirkSet holds the quirks by the names used by the community database: shift, memoryIncrementByX,
+// memoryLeaveIUnchanged, wrap, jump, vblank and logic.
+type QuirkSet map[string]bool
+
+// Quirks converts the quirk set into the quirks of the emulator.
+func (qs QuirkSet) Quirks() chip8.Quirks {
+	q := chip8.Quirks{
+		VFReset: qs["logic"],
+		ShiftVY: !qs["shift"],
+		Memory:  chip8.MemoryIncrement,
+		JumpVX:  qs["jump"],
+		Clip:    !qs["wrap"],
+		VBlank:  qs["vblank"],
+	}
+	switch {
+	case qs["memoryLeaveIUnchanged"]:
+		q.Memory = chip8.MemoryUnchanged
+	case qs["memoryIncrementByX"]:
+		q.Memory = chip8.MemoryIncrementByX
+	}
+	return q
+}
+
+// Database holds the known programs.
+type Database struct {
+	programs  []*Program
+	hashes    map[string]int
+	platforms map[string]*Platform
+}
+
+// Entry is the result of a successful lookup.
+type Entry struct {
+	Hash     string
+	Program  *Program
+	ROM      *ROM
+	Platform *Platform // The preferred platform of the ROM, nil if the database does not know any of them
+}
+
+// Default returns the embedded database.
+func Default() (*Database, error) {
+	fsys, err := fs.Sub(embedded, "database")
+	if err != nil {
+		return nil, err
+	}
+	return Load(fsys)
+}
+
+// Load reads a database in the layout of the community CHIP-8 database from fsys.
+func Load(fsys fs.FS) (*Database, error) {
+	db := &Database{}
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"programs.json", &db.programs},
+		{"sha1-hashes.json", &db.hashes},
+	}
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f.name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the ROM database: %w", err)
+		}
+		if err := json.Unmarshal(data, f.v); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", f.name, err)
+		}
+	}
+
+	data, err := fs.ReadFile(fsys, "platforms.json")
+	if err != nil {
+		return nil, fmt.Errorf("could not read the ROM database: %w", err)
+	}
+	var platforms []*Platform
+	if err := json.Unmarshal(data, &platforms); err != nil {
+		return nil, fmt.Errorf("could not parse platforms.json: %w", err)
+	}
+	db.platforms = make(map[string]*Platform, len(platforms))
+	for _, p := range platforms {
+		db.platforms[p.ID] = p
+	}
+
+	for hash, index := range db.hashes {
+		if index < 0 || index >= len(db.programs) {
+			return nil, fmt.Errorf("hash %s refers to the unknown program %d", hash, index)
+		}
+	}
+	for _, p := range db.programs {
+		for hash, rom := range p.ROMs {
+			if _, err := gamestate.NewReader(rom.Variables); err != nil {
+				return nil, fmt.Errorf("ROM %s: %w", hash, err)
+			}
+			for _, a := range rom.Achievements {
+				if err := a.Validate(); err != nil {
+					return nil, fmt.Errorf("ROM %s: %w", hash, err)
+				}
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// Hash returns the SHA-1 hash of the ROM as it is used as the key of the database.
+func Hash(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup searches the database for the ROM.
+func (db *Database) Lookup(rom []byte) (*Entry, bool) {
+	hash := Hash(rom)
+	index, found := db.hashes[hash]
+	if !found {
+		return nil, false
+	}
+
+	program := db.programs[index]
+	e := &Entry{Hash: hash, Program: program, ROM: program.ROMs[hash]}
+	if e.ROM == nil {
+		e.ROM = &ROM{}
+	}
+	for _, id := range e.ROM.Platforms {
+		if p, found := db.platforms[id]; found {
+			e.Platform = p
+			break
+		}
+	}
+
+	return e, true
+}
+
+// Quirks returns the recommended quirks, which are the quirks of the preferred platform including
+// the deviations of the ROM. The second result is false if the platform is unknown.
+func (e *Entry) Quirks() (chip8.Quirks, bool) {
+	if e.Platform == nil {
+		return chip8.Quirks{}, false
+	}
+
+	qs := QuirkSet{}
+	for name, value := range e.Platform.Quirks {
+		qs[name] = value
+	}
+	for name, value := range e.ROM.QuirkyPlatforms[e.Platform.ID] {
+		qs[name] = value
+	}
+
+	return qs.Quirks(), true
+}
+
+// Tickrate returns the recommended number of instructions per frame or zero if it is unknown.
+func (e *Entry) Tickrate() int {
+	if e.ROM.Tickrate > 0 {
+		return e.ROM.Tickrate
+	}
+	if e.Platform != nil {
+		return e.Platform.DefaultTickrate
+	}
+	return 0
+}
+
+// Palette returns the recommended palette, nil if there is none.
+func (e *Entry) Palette() (*renderer.Palette, error) {
+	if e.ROM.Colors == nil || len(e.ROM.Colors.Pixels) == 0 {
+		return nil, nil
+	}
+
+	p, err := renderer.ParsePalette(e.ROM.Colors.Pixels)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,158 @@
+package romdb
+
+import (
+	"image/color"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/waldgaenger/go-acht/internal/chip8"
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+var testDatabase = fstest.MapFS{
+	"programs.json": {Data: []byte(`[
+		{"title": "First", "authors": ["Someone"], "roms": {
+			"a9993e364706816aba3e25717850c26c9cd0d89d": {
+				"platforms": ["unknownPlatform", "superchip"],
+				"quirkyPlatforms": {"superchip": {"wrap": true}},
+				"colors": {"pixels": ["#000000", "#FF8000"]}
+			}
+		}},
+		{"title": "Second", "roms": {
+			"4e1243bd22c66e76c2ba9eddc1f91394e57f9f83": {"platforms": ["originalChip8"], "tickrate": 7, "keys": {"up": 5}}
+		}}
+	]`)},
+	"sha1-hashes.json": {Data: []byte(`{
+		"a9993e364706816aba3e25717850c26c9cd0d89d": 0,
+		"4e1243bd22c66e76c2ba9eddc1f91394e57f9f83": 1
+	}`)},
+	"platforms.json": {Data: []byte(`[
+		{"id": "originalChip8", "name": "Cosmac VIP CHIP-8", "defaultTickrate": 15,
+			"quirks": {"shift": false, "memoryIncrementByX": false, "memoryLeaveIUnchanged": false, "wrap": false, "jump": false, "vblank": true, "logic": true}},
+		{"id": "superchip", "name": "SUPER-CHIP 1.1", "defaultTickrate": 30,
+			"quirks": {"shift": true, "memoryIncrementByX": false, "memoryLeaveIUnchanged": true, "wrap": false, "jump": true, "vblank": false, "logic": false}}
+	]`)},
+}
+
+func TestLookup(t *testing.T) {
+	db, err := Load(testDatabase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		testName string
+		rom      string
+		found    bool
+		title    string
+		platform string
+		quirks   chip8.Quirks
+		tickrate int
+	}{
+		{
+			testName: "Platform and quirky platform",
+			rom:      "abc",
+			found:    true,
+			title:    "First",
+			platform: "superchip",
+			quirks:   chip8.Quirks{Memory: chip8.MemoryUnchanged, JumpVX: true},
+			tickrate: 30,
+		},
+		{
+			testName: "Tickrate of the ROM",
+			rom:      "test\n",
+			found:    true,
+			title:    "Second",
+			platform: "originalChip8",
+			quirks:   chip8.QuirkProfiles["chip8"],
+			tickrate: 7,
+		},
+		{
+			testName: "Unknown ROM",
+			rom:      "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			e, found := db.Lookup([]byte(tt.rom))
+			if found != tt.found {
+				t.Fatalf("Expected found to be %t but got %t", tt.found, found)
+			}
+			if !found {
+				return
+			}
+
+			if e.Program.Title != tt.title || e.Platform.ID != tt.platform {
+				t.Errorf("Expected %s on %s but got %s on %s", tt.title, tt.platform, e.Program.Title, e.Platform.ID)
+			}
+			if quirks, _ := e.Quirks(); quirks != tt.quirks {
+				t.Errorf("Expected the quirks %+v but got %+v", tt.quirks, quirks)
+			}
+			if e.Tickrate() != tt.tickrate {
+				t.Errorf("Expected the tickrate %d but got %d", tt.tickrate, e.Tickrate())
+			}
+		})
+	}
+}
+
+func TestPalette(t *testing.T) {
+	db, err := Load(testDatabase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e, _ := db.Lookup([]byte("abc"))
+	palette, err := e.Palette()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := renderer.NewPalette(color.RGBA{255, 128, 0, 255}, color.RGBA{0, 0, 0, 255})
+	if palette == nil || *palette != want {
+		t.Errorf("Expected the palette %v but got %v", want, palette)
+	}
+
+	e.ROM.Colors.Pixels = []string{"#12345"}
+	if _, err := e.Palette(); err == nil {
+		t.Errorf("Expected an error for an invalid colour")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	db, err := Default()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rom, err := os.ReadFile("../../roms/pong.rom")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e, found := db.Lookup(rom)
+	if !found || e.ROM.Keys["up"] != 1 {
+		t.Errorf("Expected the bundled ROM to be in the database with its keys")
+	}
+	if found && e.ROM.Variables["score"].Address != 0x2F3 {
+		t.Errorf("Expected the score of the bundled ROM at 0x2F3")
+	}
+	if found && len(e.ROM.Achievements) != 3 {
+		t.Errorf("Expected 3 achievements of the bundled ROM but got %d", len(e.ROM.Achievements))
+	}
+}
+
+func TestInvalidVariables(t *testing.T) {
+	db := fstest.MapFS{
+		"programs.json": {Data: []byte(`[{"title": "Invalid", "roms": {"a9993e364706816aba3e25717850c26c9cd0d89d": {
+			"platforms": ["superchip"], "variables": {"score": {"address": 512, "register": 3}}
+		}}}]`)},
+		"sha1-hashes.json": {Data: []byte(`{"a9993e364706816aba3e25717850c26c9cd0d89d": 0}`)},
+		"platforms.json":   testDatabase["platforms.json"],
+	}
+
+	if _, err := Load(db); err == nil {
+		t.Errorf("Expected an error for a variable with two locations")
+	}
+}
@@ -0,0 +1,51 @@
+package stream
+
+import "fmt"
+
+// EncodeDisplay run-length encodes the display row by row. The runs alternate between unset and set
+// pixels, starting with unset ones, and each run is a byte. A run longer than 255 pixels is split by
+// a run of length 0 of the other kind. A blank display takes 17 bytes instead of 256 as bitmap.
+func EncodeDisplay(display *[32][64]bool) []byte {
+	var runs []byte
+	set := false
+	run := 0
+	for y := range display {
+		for _, pixel := range display[y] {
+			if pixel != set {
+				runs = appendRun(runs, run)
+				set, run = pixel, 0
+			}
+			run++
+		}
+	}
+	return appendRun(runs, run)
+}
+
+// appendRun appends a run of any length.
+func appendRun(runs []byte, run int) []byte {
+	for run > 255 {
+		runs = append(runs, 255, 0)
+		run -= 255
+	}
+	return append(runs, byte(run))
+}
+
+// DecodeDisplay decodes a display encoded by EncodeDisplay.
+func DecodeDisplay(runs []byte) ([32][64]bool, error) {
+	var display [32][64]bool
+	i := 0
+	for n, run := range runs {
+		set := n%2 == 1
+		if i+int(run) > 32*64 {
+			return display, fmt.Errorf("the runs exceed the display")
+		}
+		for range run {
+			display[i/64][i%64] = set
+			i++
+		}
+	}
+	if i != 32*64 {
+		return display, fmt.Errorf("the runs cover %d of %d pixels", i, 32*64)
+	}
+	return display, nil
+}
@@ -0,0 +1,60 @@
+package stream
+
+import "testing"
+
+func TestEncodeDisplay(t *testing.T) {
+	var blank, full, pixel, checkerboard [32][64]bool
+	for y := range 32 {
+		for x := range 64 {
+			full[y][x] = true
+			checkerboard[y][x] = (x+y)%2 == 0
+		}
+	}
+	pixel[1][2] = true
+
+	tests := []struct {
+		name    string
+		display [32][64]bool
+		size    int
+	}{
+		{"blank", blank, 17},
+		{"full", full, 18},
+		{"single pixel", pixel, 17},
+		{"checkerboard", checkerboard, 1 + 2048 - 31}, // The runs at the ends of the rows join
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := EncodeDisplay(&tt.display)
+			if len(runs) != tt.size {
+				t.Errorf("Expected %d bytes but got %d", tt.size, len(runs))
+			}
+			display, err := DecodeDisplay(runs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if display != tt.display {
+				t.Errorf("The decoded display differs from the encoded one")
+			}
+		})
+	}
+}
+
+func TestDecodeDisplayInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []byte
+	}{
+		{"empty", nil},
+		{"too short", []byte{255, 0, 10}},
+		{"too long", []byte{255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeDisplay(tt.runs); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Package stream lets remote browsers watch and play a running emulator. The Server is the renderer,
+// beeper and input of the emulator and streams its frames over WebSocket to the bundled web client.
+// The first client to connect controls the game, the others watch. When the player leaves, the
+// client which has been watching the longest takes over.
+//
+// Frames are sent as binary messages: a byte of flags (bit 0: the sound is on) followed by the display
+// encoded by EncodeDisplay. They are only sent when the display or the sound changed. The role of a
+// client is sent as text message whenever it or the number of clients changes, together with the
+// colors and the tone to use:
+//
+//	{"role": "player", "spectators": 2, "foreground": "#ffffff", "background": "#000000", "frequency": 440, "volume": 0.5}
+//
+// The player sends its keys as text messages {"key": 5, "down": true}.
+package stream
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+//go:embed client.html
+var clientPage []byte
+
+// flagSound is set in the first byte of a frame message while the sound is on.
+const flagSound = 0x01
+
+// status is the text message telling a client its role.
+type status struct {
+	Role       string  `json:"role"` // player or spectator
+	Spectators int     `json:"spectators"`
+	Foreground string  `json:"foreground"`
+	Background string  `json:"background"`
+	Frequency  int     `json:"frequency"` // Of the tone in Hz
+	Volume     float64 `json:"volume"`
+}
+
+// keyEvent is the text message of the player pressing or releasing a key.
+type keyEvent struct {
+	Key  uint8 `json:"key"`
+	Down bool  `json:"down"`
+}
+
+// client is a connected browser. Only the latest frame and status are kept for it, so a slow client
+// skips frames instead of delaying the others.
+type client struct {
+	conn   *Conn
+	notify chan struct{} // Signals the writer that frame or status changed
+	frame  []byte        // Frame not sent yet, guarded by Server.mu
+	status []byte        // Status not sent yet, guarded by Server.mu
+}
+
+// Server streams the emulator to the connected clients. It implements renderer.Renderer,
+// audio.Beeper and input.InputHandler.
+type Server struct {
+	// AllowedOrigins holds the hosts (host:port) or origins of web pages which may connect besides the
+	// client served by the server itself, "*" allows every page. Browsers send the page which opens a
+	// WebSocket as Origin, checking it keeps other web sites from taking over the game.
+	AllowedOrigins []string
+
+	mu      sync.Mutex
+	clients []*client // In the order they connected, the first one is the player
+	keys    [16]bool  // Keys held by the player
+	frame   []byte    // Latest frame message
+	sound   bool
+	drawn   bool
+	display [32][64]bool
+	closed  bool
+
+	frequency int // Of the tone the clients play
+	volume    float64
+}
+
+// NewServer returns a server without clients. The clients play the tone with the given frequency in Hz
+// and volume from 0 to 1.
+func NewServer(frequency int, volume float64) *Server {
+	return &Server{frequency: frequency, volume: volume}
+}
+
+// ServeHTTP serves the web client at / and the WebSocket connections of the clients at /ws.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(clientPage)
+	case "/ws":
+		if !s.originAllowed(r) {
+			slog.Debug("rejected a connection from " + r.Header.Get("Origin"))
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			slog.Debug("could not upgrade the connection: " + err.Error())
+			return
+		}
+		s.serve(conn)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// originAllowed reports whether the web page which opens the WebSocket may connect. Pages of the host
+// the client is served from are allowed, like clients which are no browsers and send no Origin.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range s.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, u.Host) || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// serve handles a client until it disconnects.
+func (s *Server) serve(conn *Conn) {
+	c := &client{conn: conn, notify: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		conn.Close()
+		s.leave(c)
+	}()
+
+	s.join(c)
+	go s.write(c, done)
+
+	for {
+		opcode, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if opcode != OpText {
+			continue
+		}
+		var e keyEvent
+		if err := json.Unmarshal(data, &e); err != nil || e.Key > 0xF {
+			continue
+		}
+		s.press(c, e)
+	}
+}
+
+// write sends the frames and status changes to the client until done is closed.
+func (s *Server) write(c *client, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-c.notify:
+		}
+
+		s.mu.Lock()
+		frame, status := c.frame, c.status
+		c.frame, c.status = nil, nil
+		s.mu.Unlock()
+
+		if status != nil {
+			if err := c.conn.WriteMessage(OpText, status); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+		if frame != nil {
+			if err := c.conn.WriteMessage(OpBinary, frame); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// join adds the client and sends it the current frame.
+func (s *Server) join(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, c)
+	c.frame = s.frame
+	s.updateStatus()
+}
+
+// leave removes the client. If it was the player, its keys are released and the next client takes over.
+func (s *Server) leave(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, other := range s.clients {
+		if other == c {
+			if i == 0 {
+				s.keys = [16]bool{}
+			}
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			break
+		}
+	}
+	s.updateStatus()
+}
+
+// press applies the key event of a client, if it is the player.
+func (s *Server) press(c *client, e keyEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) > 0 && s.clients[0] == c {
+		s.keys[e.Key] = e.Down
+	}
+}
+
+// updateStatus sends every client its role. It must be called with s.mu held.
+func (s *Server) updateStatus() {
+	for i, c := range s.clients {
+		st := status{
+			Role:       "spectator",
+			Spectators: len(s.clients) - 1,
+			Foreground: hexColor(renderer.Profile.Foreground),
+			Background: hexColor(renderer.Profile.Background),
+			Frequency:  s.frequency,
+			Volume:     s.volume,
+		}
+		if i == 0 {
+			st.Role = "player"
+		}
+		c.status, _ = json.Marshal(st)
+		signal(c)
+	}
+}
+
+// signal wakes the writer of the client up, unless it is already signalled.
+func signal(c *client) {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Draw sends the display to the clients if it changed.
+func (s *Server) Draw(display [32][64]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.drawn && display == s.display {
+		return
+	}
+	s.display, s.drawn = display, true
+	s.broadcast()
+}
+
+// Beep sends the sound state to the clients if it changed. The clients play the tone themselves.
+func (s *Server) Beep(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if on == s.sound {
+		return
+	}
+	s.sound = on
+	if s.drawn {
+		s.broadcast()
+	}
+}
+
+// broadcast sends the current frame to all clients. It must be called with s.mu held.
+func (s *Server) broadcast() {
+	var flags byte
+	if s.sound {
+		flags |= flagSound
+	}
+	s.frame = append([]byte{flags}, EncodeDisplay(&s.display)...)
+	for _, c := range s.clients {
+		c.frame = s.frame
+		signal(c)
+	}
+}
+
+// PollKeys copies the keys held by the player. It reports quit once the server is closed.
+func (s *Server) PollKeys(keyPad *[16]bool) (quit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*keyPad = s.keys
+	return s.closed
+}
+
+// Close disconnects all clients and stops the emulator at its next frame.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, c := range s.clients {
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,245 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waldgaenger/go-acht/internal/renderer"
+)
+
+// testClient is the client side of a WebSocket connection, just enough to talk to the server.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, url string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", key)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101 but got %d", resp.StatusCode)
+	}
+	// The accept key of the example in RFC 6455.
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected the accept key s3pPLMBiTxaQ9kYGzzhZRbK+xOo= but got %s", accept)
+	}
+	return &testClient{t: t, conn: conn, reader: reader}
+}
+
+// send writes a masked text message split into two frames.
+func (c *testClient) send(message string) {
+	mask := []byte{1, 2, 3, 4}
+	half := len(message) / 2
+	for i, part := range []string{message[:half], message[half:]} {
+		header := []byte{OpText, 0x80 | byte(len(part))}
+		if i == 1 {
+			header[0] = 0x80 | opContinuation
+		}
+		payload := []byte(part)
+		for j := range payload {
+			payload[j] ^= mask[j%4]
+		}
+		c.conn.Write(append(append(header, mask...), payload...))
+	}
+}
+
+// read returns the next message of the server.
+func (c *testClient) read() (byte, []byte) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		c.t.Fatalf("unexpected error: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		c.t.Fatalf("Expected an unmasked frame")
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatalf("unexpected error: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// readStatus returns the role of the next message, which has to be a status.
+func (c *testClient) readStatus() status {
+	c.t.Helper()
+	opcode, data := c.read()
+	if opcode != OpText {
+		c.t.Fatalf("Expected a status but got opcode %d", opcode)
+	}
+	var st status
+	if err := json.Unmarshal(data, &st); err != nil {
+		c.t.Fatalf("unexpected error: %v", err)
+	}
+	return st
+}
+
+// readFrame returns the display of the next message, which has to be a frame.
+func (c *testClient) readFrame() (byte, [32][64]bool) {
+	c.t.Helper()
+	opcode, data := c.read()
+	if opcode != OpBinary {
+		c.t.Fatalf("Expected a frame but got opcode %d", opcode)
+	}
+	display, err := DecodeDisplay(data[1:])
+	if err != nil {
+		c.t.Fatalf("unexpected error: %v", err)
+	}
+	return data[0], display
+}
+
+// waitKeys polls the keys until the key has the expected state.
+func waitKeys(t *testing.T, s *Server, key int, down bool) {
+	t.Helper()
+	var keys [16]bool
+	for range 100 {
+		s.PollKeys(&keys)
+		if keys[key] == down {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected key %X to be down=%t", key, down)
+}
+
+func TestServer(t *testing.T) {
+	renderer.Use("black-white")
+	s := NewServer(440, 0.5)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	defer s.Close()
+
+	var display [32][64]bool
+	display[3][4] = true
+	s.Draw(display)
+
+	player := dial(t, ts.URL)
+	if st := player.readStatus(); st.Role != "player" || st.Spectators != 0 || st.Foreground != "#ffffff" {
+		t.Errorf("Expected the first client to be the player but got %+v", st)
+	}
+	if _, got := player.readFrame(); got != display {
+		t.Errorf("Expected the current frame after connecting")
+	}
+
+	spectator := dial(t, ts.URL)
+	if st := spectator.readStatus(); st.Role != "spectator" || st.Spectators != 1 {
+		t.Errorf("Expected the second client to be a spectator but got %+v", st)
+	}
+	spectator.readFrame()
+	if st := player.readStatus(); st.Spectators != 1 {
+		t.Errorf("Expected the player to be told about the spectator but got %+v", st)
+	}
+
+	display[5][6] = true
+	s.Draw(display)
+	s.Draw(display)
+	s.Beep(true)
+	for _, c := range []*testClient{player, spectator} {
+		// The frame without sound is skipped if the frame with sound follows before it is sent.
+		flags, got := c.readFrame()
+		if flags != flagSound {
+			flags, got = c.readFrame()
+		}
+		if got != display {
+			t.Errorf("Expected the changed frame")
+		}
+		if flags != flagSound {
+			t.Errorf("Expected a frame with sound but got flags %d", flags)
+		}
+	}
+
+	spectator.send(`{"key": 3, "down": true}`)
+	player.send(`{"key": 5, "down": true}`)
+	waitKeys(t, s, 5, true)
+	var keys [16]bool
+	s.PollKeys(&keys)
+	if keys[3] {
+		t.Errorf("Expected the keys of the spectator to be ignored")
+	}
+
+	player.conn.Close()
+	if st := spectator.readStatus(); st.Role != "player" {
+		t.Errorf("Expected the spectator to take over but got %+v", st)
+	}
+	waitKeys(t, s, 5, false)
+	spectator.send(`{"key": 3, "down": true}`)
+	waitKeys(t, s, 3, true)
+}
+
+func TestOrigin(t *testing.T) {
+	tests := []struct {
+		testName string
+		origin   string
+		allowed  []string
+		want     int
+	}{
+		{testName: "No origin", want: http.StatusSwitchingProtocols},
+		{testName: "Page of the server", origin: "http://{host}", want: http.StatusSwitchingProtocols},
+		{testName: "Other page", origin: "https://evil.example", want: http.StatusForbidden},
+		{testName: "Other port", origin: "http://127.0.0.1:1", want: http.StatusForbidden},
+		{testName: "Allowed host", origin: "https://good.example", allowed: []string{"good.example"}, want: http.StatusSwitchingProtocols},
+		{testName: "Allowed origin", origin: "https://good.example", allowed: []string{"https://good.example"}, want: http.StatusSwitchingProtocols},
+		{testName: "Every page allowed", origin: "https://evil.example", allowed: []string{"*"}, want: http.StatusSwitchingProtocols},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s := NewServer(440, 0.5)
+			s.AllowedOrigins = tt.allowed
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+			defer s.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/ws", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			if tt.origin != "" {
+				req.Header.Set("Origin", strings.ReplaceAll(tt.origin, "{host}", req.Host))
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("Expected status %d but got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The opcodes of the WebSocket frames (RFC 6455, section 5.2).
+const (
+	opContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// maxMessageSize limits the messages read from a client, which only sends small key events.
+const maxMessageSize = 4096
+
+// websocketGUID is appended to the key of the client to compute the accept header of the handshake.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned by ReadMessage after the client closed the connection.
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is the server side of a WebSocket connection. ReadMessage must be called from one goroutine
+// only, WriteMessage may be called concurrently.
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// Upgrade performs the opening handshake of a WebSocket connection on the HTTP request and takes
+// over its connection.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported WebSocket version: %s", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "the connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("could not take over the connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not complete the handshake: %w", err)
+	}
+
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+// acceptKey returns the value of the Sec-WebSocket-Accept header for the key of the client.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains reports whether one of the comma separated values of the header is token,
+// ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message of the client. Fragmented messages are joined,
+// pings are answered. ErrClosed is returned once the client closed the connection.
+func (c *Conn) ReadMessage() (opcode byte, data []byte, err error) {
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// The close frame is echoed as required, the status code of the client is sent back.
+			c.writeFrame(opClose, payload[:min(len(payload), 2)])
+			return 0, nil, ErrClosed
+		case opContinuation:
+			if opcode == 0 {
+				return 0, nil, fmt.Errorf("unexpected continuation frame")
+			}
+		case OpText, OpBinary:
+			if opcode != 0 {
+				return 0, nil, fmt.Errorf("expected a continuation frame")
+			}
+			opcode = op
+		default:
+			return 0, nil, fmt.Errorf("unknown opcode: %#x", op)
+		}
+
+		if len(message)+len(payload) > maxMessageSize {
+			return 0, nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame. Frames of clients must be masked.
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("unexpected reserved bits")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("unmasked frame from the client")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, fmt.Errorf("frame exceeds %d bytes", maxMessageSize)
+	}
+	if opcode >= opClose && (!fin || length > 125) {
+		return false, 0, nil, fmt.Errorf("invalid control frame")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends a text or binary message in a single frame.
+func (c *Conn) WriteMessage(opcode byte, data []byte) error {
+	return c.writeFrame(opcode, data)
+}
+
+// writeFrame sends an unmasked frame, as the server must.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10+len(payload))
+	header[0] = 0x80 | opcode
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// Close closes the connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}